@@ -3,6 +3,7 @@ package main
 import (
 	"bplustree/pkg/bplustree"
 	"fmt"
+	"log"
 	"math/rand"
 	"time"
 )
@@ -122,4 +123,48 @@ func main() {
 			queryTime, float64(numQueries)/queryTime.Seconds())
 		fmt.Printf("  Hits: %d (%.2f%%)\n", hits, float64(hits*100)/float64(numQueries))
 	}
+
+	// Compare searches inside nodes on random uint64 keys
+	fmt.Println("\nTesting in-node search on random uint64 keys...")
+
+	randomKeys := make([]uint64, numKeys)
+	for i := range randomKeys {
+		randomKeys[i] = rand.Uint64()
+	}
+	randomQueries := make([]uint64, numQueries)
+	for i := range randomQueries {
+		randomQueries[i] = randomKeys[rand.Intn(len(randomKeys))]
+	}
+
+	for _, bf := range []int{256, 1024} {
+		for _, interpolation := range []bool{false, true} {
+			// Every query hits, so both searches pay the same bloom filter check
+			tree := bplustree.NewOrderedTree[uint64](bf)
+			if err := bplustree.SetInterpolationSearch(tree, interpolation); err != nil {
+				log.Fatalf("SetInterpolationSearch failed: %v", err)
+			}
+			for _, key := range randomKeys {
+				tree.Insert(key)
+			}
+
+			startTime := time.Now()
+			hits := 0
+			for _, key := range randomQueries {
+				if tree.Contains(key) {
+					hits++
+				}
+			}
+			queryTime := time.Since(startTime)
+
+			search := "binary"
+			if interpolation {
+				search = "interpolation"
+			}
+			fmt.Printf("Branching Factor: %d, Search: %s\n", bf, search)
+			fmt.Printf("  Query Time: %v (%.2f queries/sec, %.1f ns/query)\n",
+				queryTime, float64(numQueries)/queryTime.Seconds(),
+				float64(queryTime.Nanoseconds())/float64(numQueries))
+			fmt.Printf("  Hits: %d (%.2f%%)\n", hits, float64(hits*100)/float64(numQueries))
+		}
+	}
 }
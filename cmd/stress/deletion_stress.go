@@ -2,6 +2,7 @@ package main
 
 import (
 	"bplustree/pkg/bplustree"
+	"cmp"
 	"flag"
 	"fmt"
 	"math/rand"
@@ -22,6 +23,9 @@ var (
 	memProfile      = flag.String("memprofile", "", "Write memory profile to file")
 	testPatterns    = flag.Bool("patterns", true, "Test different deletion patterns")
 	verifyResults   = flag.Bool("verify", true, "Verify correctness after operations")
+	churnRounds     = flag.Int("churn", 3, "Rounds of deleting and re-inserting half of the keys before the deletion test")
+	bufferSize      = flag.Int("buffer", 0, "Messages a branch buffers before flushing, 0 for an unbuffered tree")
+	shuffleInserts  = flag.Bool("shuffle", false, "Insert keys in random order instead of ascending order")
 )
 
 // DeletionPattern defines how keys are selected for deletion
@@ -98,6 +102,9 @@ func main() {
 	fmt.Printf("Random Seed: %d\n", *randomSeed)
 	fmt.Printf("Verify Results: %v\n", *verifyResults)
 	fmt.Printf("Test Patterns: %v\n", *testPatterns)
+	fmt.Printf("Churn Rounds: %d\n", *churnRounds)
+	fmt.Printf("Buffer Size: %d\n", *bufferSize)
+	fmt.Printf("Shuffle Inserts: %v\n", *shuffleInserts)
 	fmt.Printf("\n")
 
 	// Generate keys
@@ -163,23 +170,43 @@ func runDeletionTest(keys []uint64, pattern DeletionPattern) TestResult {
 	fmt.Printf("\nRunning deletion test with pattern: %s\n", pattern)
 	fmt.Printf("---------------------------------------\n")
 
-	// Create a new tree with the generic implementation
-	tree := bplustree.NewGenericBPlusTree[uint64](
-		*branchingFactor,
-		func(a, b uint64) bool { return a < b },
-		func(a, b uint64) bool { return a == b },
-		func(v uint64) uint64 { return v },
-	)
+	tree := newTree()
 
-	// Insert all keys
+	// Insert all keys, in random order if requested
+	insertKeys := keys
+	if *shuffleInserts {
+		insertKeys = prepareDeleteKeys(keys, DeleteRandom)
+	}
 	fmt.Printf("Inserting %d keys...\n", len(keys))
+	insertMallocs := mallocs()
 	insertStart := time.Now()
-	for _, key := range keys {
+	for _, key := range insertKeys {
 		tree.Insert(key)
 	}
 	insertTime := time.Since(insertStart)
-	fmt.Printf("Insertion completed in %s (%.2f keys/sec)\n",
-		insertTime, float64(len(keys))/insertTime.Seconds())
+	insertMallocs = mallocs() - insertMallocs
+	fmt.Printf("Insertion completed in %s (%.2f keys/sec, %d allocations)\n",
+		insertTime, float64(len(keys))/insertTime.Seconds(), insertMallocs)
+
+	// Churn the tree by deleting and re-inserting a random half of the keys
+	if *churnRounds > 0 {
+		churnKeys := prepareDeleteKeys(keys, DeleteRandom)[:len(keys)/2]
+		fmt.Printf("Churning %d keys for %d rounds...\n", len(churnKeys), *churnRounds)
+		churnMallocs := mallocs()
+		churnStart := time.Now()
+		for round := 0; round < *churnRounds; round++ {
+			for _, key := range churnKeys {
+				tree.Delete(key)
+			}
+			for _, key := range churnKeys {
+				tree.Insert(key)
+			}
+		}
+		churnTime := time.Since(churnStart)
+		churnMallocs = mallocs() - churnMallocs
+		fmt.Printf("Churn completed in %s (%.2f keys/sec, %d allocations)\n",
+			churnTime, float64(2*len(churnKeys)**churnRounds)/churnTime.Seconds(), churnMallocs)
+	}
 
 	// Prepare keys for deletion based on the pattern
 	deleteKeys := prepareDeleteKeys(keys, pattern)
@@ -192,6 +219,7 @@ func runDeletionTest(keys []uint64, pattern DeletionPattern) TestResult {
 
 	// Delete keys in batches
 	fmt.Printf("Deleting %d keys with pattern %s...\n", len(deleteKeys), pattern)
+	deleteMallocs := mallocs()
 	deleteStart := time.Now()
 	deletedCount := 0
 	batchCount := 0
@@ -234,8 +262,9 @@ func runDeletionTest(keys []uint64, pattern DeletionPattern) TestResult {
 	}
 
 	deleteTime := time.Since(deleteStart)
-	fmt.Printf("Deletion completed in %s (%.2f keys/sec)\n",
-		deleteTime, float64(deletedCount)/deleteTime.Seconds())
+	deleteMallocs = mallocs() - deleteMallocs
+	fmt.Printf("Deletion completed in %s (%.2f keys/sec, %d allocations)\n",
+		deleteTime, float64(deletedCount)/deleteTime.Seconds(), deleteMallocs)
 
 	// Measure memory usage after deletion
 	runtime.GC()
@@ -243,7 +272,7 @@ func runDeletionTest(keys []uint64, pattern DeletionPattern) TestResult {
 	memAfter := memStats.Alloc
 	fmt.Printf("Memory usage: %.2f MB before, %.2f MB after, %.2f MB difference\n",
 		float64(memBefore)/(1024*1024), float64(memAfter)/(1024*1024),
-		float64(memAfter-memBefore)/(1024*1024))
+		(float64(memAfter)-float64(memBefore))/(1024*1024))
 
 	// Reset the size counter to the actual number of keys in the tree
 	tree.ResetSize()
@@ -379,6 +408,29 @@ func runDeletionTest(keys []uint64, pattern DeletionPattern) TestResult {
 	}
 }
 
+// newTree creates the tree under test, buffered if a buffer size is given
+func newTree() *bplustree.GenericBPlusTree[uint64] {
+	hash := func(v uint64) uint64 { return v }
+	if *bufferSize > 0 {
+		return bplustree.NewBufferedTree[uint64](*branchingFactor, *bufferSize, cmp.Compare[uint64], hash)
+	}
+
+	// Create a new tree with the generic implementation
+	return bplustree.NewGenericBPlusTree[uint64](
+		*branchingFactor,
+		func(a, b uint64) bool { return a < b },
+		func(a, b uint64) bool { return a == b },
+		hash,
+	)
+}
+
+// mallocs returns the number of heap objects allocated so far
+func mallocs() uint64 {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	return memStats.Mallocs
+}
+
 // prepareDeleteKeys prepares keys for deletion based on the pattern
 func prepareDeleteKeys(keys []uint64, pattern DeletionPattern) []uint64 {
 	result := make([]uint64, len(keys))
@@ -20,6 +20,13 @@ for bf in 16 64 256 1024; do
     go run deletion_stress.go -keys=100000 -bf=$bf -patterns=true -verify=true > "results/bf_${bf}.txt"
 done
 
+# Compare unbuffered and buffered trees on keys inserted in random order
+echo "Testing buffered trees..."
+for buffer in 0 1024; do
+    echo "Running test with buffer size $buffer..."
+    go run deletion_stress.go -keys=1000000 -bf=32 -shuffle -buffer=$buffer -patterns=true -verify=true > "results/buffer_${buffer}.txt"
+done
+
 # Run a CPU profile test
 echo "Running CPU profile test..."
 go run deletion_stress.go -keys=100000 -cpuprofile=results/cpu.prof -patterns=false -verify=true > "results/cpu_profile.txt"
@@ -1,7 +1,9 @@
 package bplustree
 
 import (
+	"fmt"
 	"math/rand"
+	"slices"
 	"testing"
 )
 
@@ -32,6 +34,30 @@ func BenchmarkGenericBPlusTreeInsert(b *testing.B) {
 	}
 }
 
+// BenchmarkGenericBPlusTreeAppend benchmarks inserting ascending keys, which go
+// to the rightmost leaf without a descent, under each split policy
+func BenchmarkGenericBPlusTreeAppend(b *testing.B) {
+	policies := []struct {
+		name   string
+		policy SplitPolicy
+	}{
+		{"Midpoint", SplitMidpoint},
+		{"RightHeavy", SplitRightHeavy},
+		{"Adaptive", SplitAdaptive},
+	}
+	for _, p := range policies {
+		b.Run(p.name, func(b *testing.B) {
+			tree := NewBPlusTreeWithOptions(256, false)
+			tree.SetSplitPolicy(p.policy)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				tree.Insert(uint64(i))
+			}
+		})
+	}
+}
+
 // BenchmarkOriginalBPlusTreeContains benchmarks the lookup of keys in the original B+ tree
 func BenchmarkOriginalBPlusTreeContains(b *testing.B) {
 	tree := NewBPlusTree(256)
@@ -144,6 +170,62 @@ func BenchmarkGenericBPlusTreeRangeQuery(b *testing.B) {
 	}
 }
 
+// benchmarkBranchingFactors are the branching factors the in-node search benchmarks run at
+var benchmarkBranchingFactors = []int{16, 64, 256, 1024}
+
+// BenchmarkGenericBPlusTreeContainsByBranchingFactor benchmarks lookups of present keys
+// without a Bloom filter, so the search inside each node dominates, at several branching factors
+func BenchmarkGenericBPlusTreeContainsByBranchingFactor(b *testing.B) {
+	benchmarkContainsByBranchingFactor(b, NewBPlusTree)
+}
+
+// BenchmarkOrderedTreeContainsByBranchingFactor benchmarks lookups of present keys
+// in trees built by NewOrderedTree, which search nodes without calling compare
+func BenchmarkOrderedTreeContainsByBranchingFactor(b *testing.B) {
+	benchmarkContainsByBranchingFactor(b, NewOrderedTree[uint64])
+}
+
+// benchmarkContainsByBranchingFactor benchmarks lookups of present keys in trees
+// made by newTree at each of the benchmark branching factors
+func benchmarkContainsByBranchingFactor(b *testing.B, newTree func(int) *GenericBPlusTree[uint64]) {
+	for _, bf := range benchmarkBranchingFactors {
+		b.Run(fmt.Sprintf("bf=%d", bf), func(b *testing.B) {
+			tree := newTree(bf)
+			for i := 0; i < 100000; i++ {
+				tree.Insert(uint64(i))
+			}
+
+			// Measure the descent alone; hashing for the Bloom filter would dominate it
+			tree.bloomFilter = NewNullBloomFilter()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				tree.Contains(uint64(rand.Intn(100000)))
+			}
+		})
+	}
+}
+
+// BenchmarkGenericBPlusTreeDeleteByBranchingFactor benchmarks deleting and reinserting
+// present keys at several branching factors
+func BenchmarkGenericBPlusTreeDeleteByBranchingFactor(b *testing.B) {
+	for _, bf := range benchmarkBranchingFactors {
+		b.Run(fmt.Sprintf("bf=%d", bf), func(b *testing.B) {
+			tree := NewBPlusTree(bf)
+			for i := 0; i < 100000; i++ {
+				tree.Insert(uint64(i))
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				key := uint64(rand.Intn(100000))
+				tree.Delete(key)
+				tree.Insert(key)
+			}
+		})
+	}
+}
+
 // BenchmarkGenericSetWithStrings benchmarks the generic set with string values
 func BenchmarkGenericSetWithStrings(b *testing.B) {
 	set := NewStringSet(256)
@@ -169,3 +251,105 @@ func randomString(length int) string {
 	}
 	return string(result)
 }
+
+// BenchmarkGenericBPlusTreeContainsClustered benchmarks lookups that walk
+// through the keys in order, with and without the finger
+func BenchmarkGenericBPlusTreeContainsClustered(b *testing.B) {
+	for _, finger := range []bool{false, true} {
+		b.Run(fmt.Sprintf("finger=%v", finger), func(b *testing.B) {
+			tree := NewBPlusTreeWithOptions(256, false)
+			for i := 0; i < 1000000; i++ {
+				tree.Insert(uint64(i))
+			}
+			tree.SetFinger(finger)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				tree.Contains(uint64(i % 1000000))
+			}
+		})
+	}
+}
+
+// BenchmarkInNodeSearch benchmarks binary and interpolation search inside one
+// node of random uint64 keys, by node size
+func BenchmarkInNodeSearch(b *testing.B) {
+	searches := []struct {
+		name   string
+		search searchFunc[uint64]
+	}{
+		{"Binary", slices.BinarySearch[[]uint64, uint64]},
+		{"Interpolation", interpolationSearch[uint64]},
+	}
+	for _, size := range []int{256, 1024} {
+		keys := make([]uint64, size)
+		for i := range keys {
+			keys[i] = rand.Uint64()
+		}
+		slices.Sort(keys)
+		queries := make([]uint64, 4096)
+		for i := range queries {
+			queries[i] = keys[rand.Intn(size)]
+		}
+
+		for _, s := range searches {
+			b.Run(fmt.Sprintf("%s/keys=%d", s.name, size), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					s.search(keys, queries[i%len(queries)])
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkLearnedIndexContains benchmarks lookups of random uint64 keys in a
+// tree and in learned indexes frozen from it, by maximum error
+func BenchmarkLearnedIndexContains(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	tree := NewOrderedTree[uint64](256)
+	keys := make([]uint64, 1000000)
+	for i := range keys {
+		keys[i] = r.Uint64()
+		tree.Insert(keys[i])
+	}
+	tree.bloomFilter = NewNullBloomFilter()
+	queries := make([]uint64, 4096)
+	for i := range queries {
+		queries[i] = keys[r.Intn(len(keys))]
+	}
+
+	b.Run("Tree", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			tree.Contains(queries[i%len(queries)])
+		}
+	})
+	for _, maxError := range []int{8, 64} {
+		idx := Freeze(tree, maxError)
+		b.Run(fmt.Sprintf("maxError=%d", maxError), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				idx.Contains(queries[i%len(queries)])
+			}
+		})
+	}
+}
+
+// BenchmarkBufferedTreeInsert benchmarks inserting random uint64 keys into
+// plain and buffered trees, by branching factor
+func BenchmarkBufferedTreeInsert(b *testing.B) {
+	for _, bf := range []int{32, 256} {
+		for _, bufferSize := range []int{0, 1024} {
+			b.Run(fmt.Sprintf("bf=%d/buffer=%d", bf, bufferSize), func(b *testing.B) {
+				tree := NewOrderedTree[uint64](bf)
+				if bufferSize > 0 {
+					tree = NewBufferedOrderedTree[uint64](bf, bufferSize)
+				}
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					tree.Insert(rand.Uint64())
+				}
+				tree.Flush()
+			})
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package bplustree
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Blocked bloom filter layout.
+const (
+	bloomBlockWords = 8                    // 64-bit words per block, one 64-byte cache line
+	bloomBlockBits  = bloomBlockWords * 64 // Bits per block
+)
+
+// BlockedBloomFilter is a Bloom filter packed into a []uint64 bitset and split into
+// blocks of one cache line. Each key hashes to a single block and sets all of its
+// bits there, so Add and Contains touch one cache line instead of k random ones.
+// The bit positions come from one 64-bit mix of the key by double hashing rather
+// than from k separate hash computations.
+//
+// Confining a key to one block raises the false-positive rate compared to a
+// BloomFilter of the same size: about 1.3 times the target at 1% and 3 times at 0.1%.
+type BlockedBloomFilter struct {
+	words         []uint64 // The bitset, bloomBlockWords words per block
+	blocks        uint64   // Number of blocks
+	hashFunctions int      // Number of bits set per key
+	valid         bool     // Whether the filter is valid
+}
+
+// NewBlockedBloomFilter creates a new blocked Bloom filter with the given size and number of hash functions.
+// Parameters:
+//   - size: The number of bits. It is rounded up to a whole number of 512-bit blocks.
+//   - hashFunctions: The number of bits set per key.
+//
+// Returns a new blocked Bloom filter initialized to empty (all bits clear).
+func NewBlockedBloomFilter(size int, hashFunctions int) *BlockedBloomFilter {
+	blocks := (size + bloomBlockBits - 1) / bloomBlockBits
+	if blocks < 1 {
+		blocks = 1
+	}
+	if hashFunctions < 1 {
+		hashFunctions = 1
+	}
+
+	return &BlockedBloomFilter{
+		words:         make([]uint64, blocks*bloomBlockWords),
+		blocks:        uint64(blocks),
+		hashFunctions: hashFunctions,
+		valid:         false,
+	}
+}
+
+// Add adds a key to the filter by setting its bits in its block.
+// Time complexity: O(k) where k is the number of hash functions.
+func (bf *BlockedBloomFilter) Add(key uint64) {
+	block, h1, h2 := bf.locate(key)
+	for i := 0; i < bf.hashFunctions; i++ {
+		bit := h1 % bloomBlockBits
+		block[bit/64] |= 1 << (bit % 64)
+		h1 += h2
+	}
+}
+
+// Contains returns true if the key might be in the set, false if it's definitely not in the set.
+// Time complexity: O(k) where k is the number of hash functions.
+func (bf *BlockedBloomFilter) Contains(key uint64) bool {
+	block, h1, h2 := bf.locate(key)
+	for i := 0; i < bf.hashFunctions; i++ {
+		bit := h1 % bloomBlockBits
+		if block[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+		h1 += h2
+	}
+	return true
+}
+
+// Clear resets all bits and marks the filter as invalid.
+// Time complexity: O(m) where m is the size of the bitset.
+func (bf *BlockedBloomFilter) Clear() {
+	clear(bf.words)
+	bf.valid = false
+}
+
+// SetValid marks the filter as valid.
+// Time complexity: O(1)
+func (bf *BlockedBloomFilter) SetValid() {
+	bf.valid = true
+}
+
+// IsValid returns true if the filter is valid.
+// Time complexity: O(1)
+func (bf *BlockedBloomFilter) IsValid() bool {
+	return bf.valid
+}
+
+// Capacity returns the number of keys for which the filter's size and number of
+// hash functions are optimal, the inverse of OptimalBloomFilterSize.
+// Time complexity: O(1)
+func (bf *BlockedBloomFilter) Capacity() int {
+	return int(float64(len(bf.words)*64) * math.Ln2 / float64(bf.hashFunctions))
+}
+
+// EstimatedFalsePositiveRate estimates the filter's current false-positive rate
+// from the fraction of bits that are set. Blocks fill unevenly, so the true rate
+// is somewhat higher than this estimate.
+// Time complexity: O(m) where m is the size of the bitset.
+func (bf *BlockedBloomFilter) EstimatedFalsePositiveRate() float64 {
+	set := 0
+	for _, word := range bf.words {
+		set += bits.OnesCount64(word)
+	}
+	return math.Pow(float64(set)/float64(len(bf.words)*64), float64(bf.hashFunctions))
+}
+
+// locate returns the block of a key and the two hashes that generate its bit positions.
+// Time complexity: O(1)
+func (bf *BlockedBloomFilter) locate(key uint64) (block []uint64, h1, h2 uint64) {
+	h := mix64(key)
+
+	// Map the hash onto the blocks without a division
+	index, _ := bits.Mul64(h, bf.blocks)
+	start := index * bloomBlockWords
+
+	// Derive the bit positions from a second scramble of the same hash,
+	// so they are independent of the block choice
+	g := h * 0x9e3779b97f4a7c15
+	h1 = g >> 32
+	h2 = g&0xffffffff | 1 // Odd, so successive positions differ
+	return bf.words[start : start+bloomBlockWords], h1, h2
+}
@@ -0,0 +1,131 @@
+package bplustree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestBlockedBloomFilterFalsePositiveRates tests the blocked filter against the target rates
+func TestBlockedBloomFilterFalsePositiveRates(t *testing.T) {
+	const n = 100000
+	const queries = 200000
+
+	for _, target := range []float64{0.1, 0.01, 0.001} {
+		size, hashFunctions := OptimalBloomFilterSize(n, target)
+		filter := NewBlockedBloomFilter(size, hashFunctions)
+		rate := measureFalsePositiveRate(t, filter, n, queries)
+		t.Logf("Target %.4f: blocked bloom %.5f, estimated %.5f", target, rate, filter.EstimatedFalsePositiveRate())
+
+		// Blocking costs some accuracy, most at low rates: about three times
+		// the target at 0.1%
+		if rate > target*4 {
+			t.Errorf("Blocked bloom filter rate %.5f is far above the target %.4f", rate, target)
+		}
+	}
+}
+
+// TestBlockedBloomFilterClear tests that Clear empties the filter and marks it invalid
+func TestBlockedBloomFilterClear(t *testing.T) {
+	filter := NewBlockedBloomFilter(1, 3)
+	if len(filter.words) != bloomBlockWords {
+		t.Errorf("Expected one block, got %d words", len(filter.words))
+	}
+
+	filter.Add(42)
+	filter.SetValid()
+	if !filter.Contains(42) || !filter.IsValid() {
+		t.Errorf("Expected a valid filter containing 42")
+	}
+
+	filter.Clear()
+	if filter.Contains(42) || filter.IsValid() {
+		t.Errorf("Expected Clear to empty the filter and invalidate it")
+	}
+}
+
+// TestTreeWithBlockedBloomFilter tests that a tree keeps a blocked filter as it grows
+func TestTreeWithBlockedBloomFilter(t *testing.T) {
+	tree := NewGenericBPlusTreeWithBloomFilter[uint64](32,
+		func(a, b uint64) bool { return a < b },
+		func(a, b uint64) bool { return a == b },
+		func(k uint64) uint64 { return k },
+		NewBlockedBloomFilter(1000, 7),
+	)
+	for key := uint64(0); key < 10000; key++ {
+		tree.Insert(key)
+	}
+	tree.Delete(5000)
+
+	if _, ok := tree.bloomFilter.(*BlockedBloomFilter); !ok {
+		t.Fatalf("Expected the tree to keep a blocked filter, got %T", tree.bloomFilter)
+	}
+	for key := uint64(0); key < 10000; key++ {
+		if got, want := tree.Contains(key), key != 5000; got != want {
+			t.Errorf("Contains(%d) = %v, expected %v", key, got, want)
+		}
+	}
+}
+
+// benchmarkFilterContains measures Contains on a filter holding one million keys,
+// with half of the lookups for absent keys
+func benchmarkFilterContains(b *testing.B, filter BloomFilterInterface) {
+	const n = 1000000
+	for key := uint64(0); key < n; key++ {
+		filter.Add(key)
+	}
+
+	queries := make([]uint64, 1024)
+	for i := range queries {
+		queries[i] = uint64(rand.Intn(2 * n))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		filter.Contains(queries[i%len(queries)])
+	}
+}
+
+// BenchmarkBloomFilterContains benchmarks the []bool bloom filter
+func BenchmarkBloomFilterContains(b *testing.B) {
+	size, hashFunctions := OptimalBloomFilterSize(1000000, 0.01)
+	benchmarkFilterContains(b, NewBloomFilter(size, hashFunctions))
+}
+
+// BenchmarkCountingBloomFilterContains benchmarks the counting bloom filter, the default of a tree
+func BenchmarkCountingBloomFilterContains(b *testing.B) {
+	size, hashFunctions := OptimalBloomFilterSize(1000000, 0.01)
+	benchmarkFilterContains(b, NewCountingBloomFilter(size, hashFunctions))
+}
+
+// BenchmarkBlockedBloomFilterContains benchmarks the blocked bloom filter
+func BenchmarkBlockedBloomFilterContains(b *testing.B) {
+	size, hashFunctions := OptimalBloomFilterSize(1000000, 0.01)
+	benchmarkFilterContains(b, NewBlockedBloomFilter(size, hashFunctions))
+}
+
+// BenchmarkBloomFilterAdd benchmarks adding keys to the []bool bloom filter
+func BenchmarkBloomFilterAdd(b *testing.B) {
+	size, hashFunctions := OptimalBloomFilterSize(1000000, 0.01)
+	filter := NewBloomFilter(size, hashFunctions)
+	for i := 0; i < b.N; i++ {
+		filter.Add(uint64(i))
+	}
+}
+
+// BenchmarkCountingBloomFilterAdd benchmarks adding keys to the counting bloom filter
+func BenchmarkCountingBloomFilterAdd(b *testing.B) {
+	size, hashFunctions := OptimalBloomFilterSize(1000000, 0.01)
+	filter := NewCountingBloomFilter(size, hashFunctions)
+	for i := 0; i < b.N; i++ {
+		filter.Add(uint64(i))
+	}
+}
+
+// BenchmarkBlockedBloomFilterAdd benchmarks adding keys to the blocked bloom filter
+func BenchmarkBlockedBloomFilterAdd(b *testing.B) {
+	size, hashFunctions := OptimalBloomFilterSize(1000000, 0.01)
+	filter := NewBlockedBloomFilter(size, hashFunctions)
+	for i := 0; i < b.N; i++ {
+		filter.Add(uint64(i))
+	}
+}
@@ -2,15 +2,30 @@
 //
 // A B+ tree is a self-balancing tree data structure that maintains sorted data
 // and allows searches, sequential access, insertions, and deletions in logarithmic time.
-// This implementation is generic and can work with any comparable type.
+// This implementation is generic and can work with any key type that can be ordered,
+// including non-comparable types such as []byte.
 //
 // The B+ tree is optimized with a bloom filter for faster lookups of non-existent keys.
 package bplustree
 
 import (
-	"hash"
-	"hash/fnv"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"math"
+	"math/bits"
+)
+
+// filterEncodingVersion is the version of the binary encoding of bloom filters.
+const filterEncodingVersion = 1
+
+// ErrFilterMismatch is returned by Union when two filters have different parameters.
+var ErrFilterMismatch = errors.New("bplustree: filters have different size or hash functions")
+
+// Default bloom filter parameters, used for new trees and in place of invalid arguments.
+const (
+	defaultExpectedElements  = 1000 // Keys a new tree's filter is sized for
+	defaultFalsePositiveRate = 0.01 // Target false-positive rate
 )
 
 // BloomFilterInterface defines the interface for a Bloom filter.
@@ -66,12 +81,11 @@ func NewBloomFilter(size int, hashFunctions int) *BloomFilter {
 // Add adds a key to the Bloom filter by setting bits at positions determined by the hash functions.
 // Time complexity: O(k) where k is the number of hash functions.
 func (bf *BloomFilter) Add(key uint64) {
+	position, step := filterProbe(key, bf.size)
 	for i := 0; i < bf.hashFunctions; i++ {
-		// Calculate bit position using the i-th hash function
-		position := bf.hash(key, i) % uint64(bf.size)
-
-		// Set the bit at that position
+		// Set the bit at the i-th position
 		bf.bits[position] = true
+		position = nextProbe(position, step, bf.size)
 	}
 }
 
@@ -80,14 +94,13 @@ func (bf *BloomFilter) Add(key uint64) {
 // but it will never return false negatives (false when the key is actually in the set).
 // Time complexity: O(k) where k is the number of hash functions.
 func (bf *BloomFilter) Contains(key uint64) bool {
+	position, step := filterProbe(key, bf.size)
 	for i := 0; i < bf.hashFunctions; i++ {
-		// Calculate bit position using the i-th hash function
-		position := bf.hash(key, i) % uint64(bf.size)
-
 		// If any bit is not set, the key is definitely not in the set
 		if !bf.bits[position] {
 			return false
 		}
+		position = nextProbe(position, step, bf.size)
 	}
 
 	// All bits are set, so the key might be in the set
@@ -114,36 +127,140 @@ func (bf *BloomFilter) IsValid() bool {
 	return bf.valid
 }
 
-// hash generates a hash value for a key using the FNV-1a hash function
-// with a seed based on the hash function index.
-// Time complexity: O(1)
-func (bf *BloomFilter) hash(key uint64, index int) uint64 {
-	return hashWithSeed(key, uint64(index+1))
+// MarshalBinary encodes the filter's size, number of hash functions, validity
+// and bit array, packed eight bits to a byte.
+// Time complexity: O(m) where m is the size of the bit array.
+func (bf *BloomFilter) MarshalBinary() ([]byte, error) {
+	data := appendFilterHeader(nil, bf.size, bf.hashFunctions, bf.valid)
+	packed := make([]byte, (bf.size+7)/8)
+	for i, bit := range bf.bits {
+		if bit {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+	return append(data, packed...), nil
+}
+
+// UnmarshalBinary replaces the filter with one encoded by MarshalBinary.
+// A filter that was valid when encoded is valid again after decoding and is
+// trusted without being recomputed. Malformed data is reported as ErrCorrupt.
+// Time complexity: O(m) where m is the size of the bit array.
+func (bf *BloomFilter) UnmarshalBinary(data []byte) error {
+	size, hashFunctions, valid, packed, err := readFilterHeader(data)
+	if err != nil {
+		return err
+	}
+	if len(packed) != (size+7)/8 {
+		return fmt.Errorf("%w: bloom filter has %d bytes of bits, expected %d", ErrCorrupt, len(packed), (size+7)/8)
+	}
+
+	bits := make([]bool, size)
+	for i := range bits {
+		bits[i] = packed[i/8]&(1<<(i%8)) != 0
+	}
+	*bf = BloomFilter{bits: bits, size: size, hashFunctions: hashFunctions, valid: valid}
+	return nil
+}
+
+// Union adds every key of other to the filter, so it answers "maybe" for keys
+// added to either. Both filters must have the same size and number of hash
+// functions, otherwise ErrFilterMismatch is returned and the filter is unchanged.
+// The result is valid only if both filters were valid.
+// Time complexity: O(m) where m is the size of the bit array.
+func (bf *BloomFilter) Union(other *BloomFilter) error {
+	if bf.size != other.size || bf.hashFunctions != other.hashFunctions {
+		return ErrFilterMismatch
+	}
+	for i, bit := range other.bits {
+		bf.bits[i] = bf.bits[i] || bit
+	}
+	bf.valid = bf.valid && other.valid
+	return nil
 }
 
-// hashWithSeed generates a hash value for a key with a given seed using FNV-1a.
+// Capacity returns the number of keys for which the filter's size and number of
+// hash functions are optimal, the inverse of OptimalBloomFilterSize.
 // Time complexity: O(1)
-func hashWithSeed(key uint64, seed uint64) uint64 {
-	h := fnv.New64a()
+func (bf *BloomFilter) Capacity() int {
+	return int(float64(bf.size) * math.Ln2 / float64(bf.hashFunctions))
+}
 
-	// Write seed bytes to the hash
-	writeUint64ToHash(h, seed)
+// EstimatedFalsePositiveRate estimates the filter's current false-positive rate
+// from the fraction of bits that are set.
+// Time complexity: O(m) where m is the size of the bit array.
+func (bf *BloomFilter) EstimatedFalsePositiveRate() float64 {
+	set := 0
+	for _, bit := range bf.bits {
+		if bit {
+			set++
+		}
+	}
+	return math.Pow(float64(set)/float64(bf.size), float64(bf.hashFunctions))
+}
 
-	// Write key bytes to the hash
-	writeUint64ToHash(h, key)
+// appendFilterHeader appends the fields shared by the binary encodings of bloom filters.
+func appendFilterHeader(dst []byte, size, hashFunctions int, valid bool) []byte {
+	dst = append(dst, filterEncodingVersion)
+	dst = binary.AppendUvarint(dst, uint64(size))
+	dst = binary.AppendUvarint(dst, uint64(hashFunctions))
+	if valid {
+		return append(dst, 1)
+	}
+	return append(dst, 0)
+}
+
+// readFilterHeader parses the fields written by appendFilterHeader and returns the rest of data.
+func readFilterHeader(data []byte) (size, hashFunctions int, valid bool, rest []byte, err error) {
+	if len(data) == 0 || data[0] != filterEncodingVersion {
+		return 0, 0, false, nil, fmt.Errorf("%w: unsupported bloom filter encoding", ErrCorrupt)
+	}
+	rest = data[1:]
 
-	// Return the 64-bit hash value
-	return h.Sum64()
+	n, k := binary.Uvarint(rest)
+	if k <= 0 || n == 0 || n > uint64(len(rest))*8 {
+		return 0, 0, false, nil, fmt.Errorf("%w: bad bloom filter size", ErrCorrupt)
+	}
+	rest = rest[k:]
+	h, k := binary.Uvarint(rest)
+	if k <= 0 || h == 0 || h > 64 {
+		return 0, 0, false, nil, fmt.Errorf("%w: bad bloom filter hash count", ErrCorrupt)
+	}
+	rest = rest[k:]
+	if len(rest) == 0 || rest[0] > 1 {
+		return 0, 0, false, nil, fmt.Errorf("%w: bad bloom filter flags", ErrCorrupt)
+	}
+	return int(n), int(h), rest[0] == 1, rest[1:], nil
+}
+
+// filterProbe returns the first of the positions of key in a filter of size
+// positions, and the step from each position to the next.
+// All k positions come from one mixed 64-bit hash by double hashing
+// (Kirsch and Mitzenmacher), which keeps the false-positive rate of k
+// independent hashes for the cost of one.
+// Time complexity: O(1)
+func filterProbe(key uint64, size int) (position, step uint64) {
+	h := mix64(key)
+
+	// Map the hash onto the positions without a division
+	position, _ = bits.Mul64(h, uint64(size))
+
+	// Derive the step from a second scramble of the same hash; a step in
+	// [1, size) never stays on the same position
+	if size > 1 {
+		step, _ = bits.Mul64(h*0x9e3779b97f4a7c15, uint64(size-1))
+		step++
+	}
+	return position, step
 }
 
-// writeUint64ToHash writes a uint64 value to a hash.
+// nextProbe returns the position step after position in a filter of size positions.
 // Time complexity: O(1)
-func writeUint64ToHash(h hash.Hash, value uint64) {
-	bytes := make([]byte, 8)
-	for i := 0; i < 8; i++ {
-		bytes[i] = byte(value >> (i * 8))
+func nextProbe(position, step uint64, size int) uint64 {
+	position += step
+	if position >= uint64(size) {
+		position -= uint64(size)
 	}
-	h.Write(bytes)
+	return position
 }
 
 // OptimalBloomFilterSize calculates the optimal size and number of hash functions
@@ -161,12 +278,12 @@ func writeUint64ToHash(h hash.Hash, value uint64) {
 func OptimalBloomFilterSize(expectedElements int, falsePositiveRate float64) (size int, hashFunctions int) {
 	// Handle edge cases
 	if expectedElements <= 0 {
-		// For zero or negative elements, return a small default size
-		return 10, 3
+		// For zero or negative elements, size the filter like a new tree's
+		expectedElements = defaultExpectedElements
 	}
 
 	if falsePositiveRate <= 0 {
-		falsePositiveRate = 0.01 // Default to 1% false positive rate
+		falsePositiveRate = defaultFalsePositiveRate // Default to 1% false positive rate
 	} else if falsePositiveRate >= 1 {
 		falsePositiveRate = 0.99 // Cap at 99% false positive rate
 	}
@@ -238,3 +355,9 @@ func (bf *NullBloomFilter) SetValid() {
 func (bf *NullBloomFilter) IsValid() bool {
 	return true
 }
+
+// EstimatedFalsePositiveRate always returns 1, since every lookup answers "maybe".
+// Time complexity: O(1)
+func (bf *NullBloomFilter) EstimatedFalsePositiveRate() float64 {
+	return 1
+}
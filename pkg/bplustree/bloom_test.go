@@ -1,6 +1,7 @@
 package bplustree
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -54,3 +55,139 @@ func TestNewBPlusTreeWithOptions(t *testing.T) {
 		t.Errorf("Tree should not contain key 40")
 	}
 }
+
+// TestBloomFilterGrowsWithTree tests that the filter keeps its false-positive rate as the tree grows
+func TestBloomFilterGrowsWithTree(t *testing.T) {
+	tree := NewBPlusTree(64)
+
+	for key := uint64(0); key < 100000; key++ {
+		tree.Insert(key * 2)
+	}
+
+	filter := tree.bloomFilter.(*CountingBloomFilter)
+	if filter.Capacity() < tree.Size() {
+		t.Errorf("Expected the filter to hold %d keys, capacity is %d", tree.Size(), filter.Capacity())
+	}
+
+	rate := tree.EstimatedFalsePositiveRate()
+	if rate > 0.02 {
+		t.Errorf("Expected an estimated false-positive rate near 1%%, got %.4f", rate)
+	}
+
+	// Odd keys are absent, so every "maybe" from the filter is a false positive
+	falsePositives := 0
+	for key := uint64(1); key < 200000; key += 2 {
+		if tree.bloomFilter.Contains(key) {
+			falsePositives++
+		}
+	}
+	if measured := float64(falsePositives) / 100000; measured > 0.03 {
+		t.Errorf("Expected a measured false-positive rate near 1%%, got %.4f", measured)
+	}
+}
+
+// TestEstimatedFalsePositiveRate tests the estimate for each kind of filter
+func TestEstimatedFalsePositiveRate(t *testing.T) {
+	tree := NewBPlusTreeWithOptions(16, false)
+	tree.Insert(1)
+	if rate := tree.EstimatedFalsePositiveRate(); rate != 1 {
+		t.Errorf("Expected 1 for a tree without a bloom filter, got %f", rate)
+	}
+
+	// A larger target rate is kept when the filter grows
+	tree = NewBPlusTree(16)
+	tree.ResizeBloomFilter(100, 0.1)
+	for key := uint64(0); key < 10000; key++ {
+		tree.Insert(key)
+	}
+	if rate := tree.EstimatedFalsePositiveRate(); rate < 0.02 || rate > 0.2 {
+		t.Errorf("Expected an estimated rate near 10%%, got %.4f", rate)
+	}
+
+	cuckoo := NewCuckooFilter(1000)
+	if rate := cuckoo.EstimatedFalsePositiveRate(); rate != 0 {
+		t.Errorf("Expected 0 for an empty cuckoo filter, got %f", rate)
+	}
+	for key := uint64(0); key < 1000; key++ {
+		cuckoo.Add(key)
+	}
+	if rate := cuckoo.EstimatedFalsePositiveRate(); rate <= 0 || rate > 0.0002 {
+		t.Errorf("Expected a small positive rate for a loaded cuckoo filter, got %f", rate)
+	}
+}
+
+// TestBloomFilterMarshalBinary tests that a filter survives an encode and decode
+func TestBloomFilterMarshalBinary(t *testing.T) {
+	filter := NewBloomFilter(1001, 5)
+	for key := uint64(0); key < 100; key++ {
+		filter.Add(key)
+	}
+	filter.SetValid()
+
+	data, err := filter.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	decoded := &BloomFilter{}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+
+	if decoded.size != 1001 || decoded.hashFunctions != 5 || !decoded.IsValid() {
+		t.Errorf("Expected size 1001, 5 hash functions and a valid filter, got %d, %d, %v",
+			decoded.size, decoded.hashFunctions, decoded.IsValid())
+	}
+	for i := range filter.bits {
+		if filter.bits[i] != decoded.bits[i] {
+			t.Fatalf("Bit %d differs after decoding", i)
+		}
+	}
+
+	// Truncated or damaged encodings are rejected
+	for _, damaged := range [][]byte{nil, data[:len(data)-1], append([]byte{9}, data[1:]...)} {
+		if err := (&BloomFilter{}).UnmarshalBinary(damaged); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("Expected ErrCorrupt, got %v", err)
+		}
+	}
+}
+
+// TestBloomFilterUnion tests that a union answers "maybe" for keys of both filters
+func TestBloomFilterUnion(t *testing.T) {
+	a := NewBloomFilter(10000, 4)
+	b := NewBloomFilter(10000, 4)
+	for key := uint64(0); key < 500; key++ {
+		a.Add(key)
+		b.Add(key + 500)
+	}
+	a.SetValid()
+	b.SetValid()
+
+	if err := a.Union(b); err != nil {
+		t.Fatalf("Union failed: %v", err)
+	}
+	for key := uint64(0); key < 1000; key++ {
+		if !a.Contains(key) {
+			t.Errorf("Expected union to contain %d", key)
+		}
+	}
+	if !a.IsValid() {
+		t.Errorf("Expected the union of valid filters to be valid")
+	}
+
+	if err := a.Union(NewBloomFilter(10000, 3)); !errors.Is(err, ErrFilterMismatch) {
+		t.Errorf("Expected ErrFilterMismatch, got %v", err)
+	}
+
+	// Counting filters add their counters, so removing a key from one side keeps the other
+	c := NewCountingBloomFilter(10000, 4)
+	d := NewCountingBloomFilter(10000, 4)
+	c.Add(7)
+	d.Add(7)
+	if err := c.Union(d); err != nil {
+		t.Fatalf("Union failed: %v", err)
+	}
+	c.Remove(7)
+	if !c.Contains(7) {
+		t.Errorf("Expected 7 to remain after removing one of its two copies")
+	}
+}
@@ -0,0 +1,406 @@
+package bplustree
+
+import (
+	"cmp"
+	"slices"
+)
+
+// defaultBufferSize is the number of messages a branch of a buffered tree holds
+// before it is flushed, unless the tree is created with another size.
+const defaultBufferSize = 1024
+
+// messageBuffer holds the pending inserts and deletes of a branch in a
+// buffered tree, sorted by key. It holds at most one message per key.
+type messageBuffer[K any] struct {
+	keys    []K    // Keys of the messages, in sorted order
+	deletes []bool // Whether each message deletes its key rather than inserting it
+}
+
+// len returns the number of messages in the buffer.
+func (b *messageBuffer[K]) len() int {
+	return len(b.keys)
+}
+
+// insert inserts a message at pos.
+// Time complexity: O(b) where b is the number of messages in the buffer.
+func (b *messageBuffer[K]) insert(pos int, key K, deleted bool) {
+	b.keys = insertAt(b.keys, pos, key)
+	b.deletes = insertAt(b.deletes, pos, deleted)
+}
+
+// push appends a message.
+// Time complexity: O(1) amortized.
+func (b *messageBuffer[K]) push(key K, deleted bool) {
+	b.keys = append(b.keys, key)
+	b.deletes = append(b.deletes, deleted)
+}
+
+// insertRange inserts the messages src[lo:hi] at pos.
+// Time complexity: O(b) where b is the number of messages in both buffers.
+func (b *messageBuffer[K]) insertRange(pos int, src *messageBuffer[K], lo, hi int) {
+	b.keys = slices.Insert(b.keys, pos, src.keys[lo:hi]...)
+	b.deletes = slices.Insert(b.deletes, pos, src.deletes[lo:hi]...)
+}
+
+// appendRange appends the messages src[lo:hi].
+// Time complexity: O(hi-lo) amortized.
+func (b *messageBuffer[K]) appendRange(src *messageBuffer[K], lo, hi int) {
+	b.insertRange(b.len(), src, lo, hi)
+}
+
+// remove removes the messages b[lo:hi].
+// Time complexity: O(b) where b is the number of messages in the buffer.
+func (b *messageBuffer[K]) remove(lo, hi int) {
+	b.keys = slices.Delete(b.keys, lo, hi)
+	b.deletes = slices.Delete(b.deletes, lo, hi)
+}
+
+// reset removes all messages, keeping the space for new ones.
+// Time complexity: O(b) where b is the number of messages in the buffer.
+func (b *messageBuffer[K]) reset() {
+	b.keys = truncate(b.keys, 0)
+	b.deletes = truncate(b.deletes, 0)
+}
+
+// NewBufferedTree creates a write-optimized B+ tree, also known as a B-epsilon tree,
+// ordered by compare and hashed by hashFunc like NewGenericBPlusTreeWithCompare.
+//
+// Each branch of a buffered tree has a buffer of pending inserts and deletes.
+// Insert and Delete add a message to the root's buffer instead of changing a leaf.
+// A buffer holding more than bufferSize messages is flushed: its messages move
+// to the buffers of the children in one batch, and those that reach the leaves
+// are applied there, splitting and merging nodes as usual. A batch of random keys
+// then touches each leaf once, in key order, instead of once per key in random order.
+//
+// Insert and Delete look the key up first, in the bloom filter and then in the
+// buffers and the leaf on the way down, and queue a message only if it changes
+// the tree, so they return whether the key was inserted or deleted like in
+// other trees. The size and the filters count a message when it is queued.
+// A message replaces an older one for the same key. Contains consults the
+// buffers on the way down, where newer messages are higher up than older ones.
+// RangeQuery and CountRange first apply the messages for keys in their range,
+// and functions that visit every key, such as GetAllKeys, apply all messages,
+// see Flush. The finger is not used while there are branches.
+//
+// Parameters:
+//   - branchingFactor: The maximum number of children per node. Must be at least 3.
+//   - bufferSize: The number of messages a branch holds before it is flushed.
+//     Values below 1 are replaced by a default of 1024.
+//   - compare: The function that orders keys, like cmp.Compare.
+//   - hashFunc: A function that converts a key of type K to a uint64 for bloom filter usage.
+//
+// Returns a new empty buffered B+ tree with a counting bloom filter.
+func NewBufferedTree[K any](
+	branchingFactor int,
+	bufferSize int,
+	compare func(a, b K) int,
+	hashFunc func(K) uint64,
+) *GenericBPlusTree[K] {
+	t := NewGenericBPlusTreeWithCompare(branchingFactor, compare, hashFunc)
+	t.setBufferSize(bufferSize)
+	return t
+}
+
+// NewBufferedOrderedTree creates a buffered tree for any ordered key type,
+// see NewBufferedTree and NewOrderedTree.
+func NewBufferedOrderedTree[K cmp.Ordered](branchingFactor, bufferSize int) *GenericBPlusTree[K] {
+	t := NewOrderedTree[K](branchingFactor)
+	t.setBufferSize(bufferSize)
+	return t
+}
+
+// setBufferSize makes t a buffered tree, see NewBufferedTree.
+func (t *GenericBPlusTree[K]) setBufferSize(bufferSize int) {
+	if bufferSize < 1 {
+		bufferSize = defaultBufferSize
+	}
+	t.bufferSize = bufferSize
+}
+
+// BufferSize returns the number of messages a branch holds before it is flushed,
+// or 0 if the tree is not buffered.
+// Time complexity: O(1)
+func (t *GenericBPlusTree[K]) BufferSize() int {
+	return t.bufferSize
+}
+
+// Flush applies all pending inserts and deletes of a buffered tree to the leaves.
+// Trees that are not buffered have nothing to flush.
+// Time complexity: O(n/B + m log n) where n is the number of keys in the tree,
+// B is the branching factor and m is the number of pending messages.
+func (t *GenericBPlusTree[K]) Flush() {
+	if t.buffered() {
+		var zero K
+		t.collectMessages(t.root, zero, zero, false)
+		t.applyPending()
+	}
+}
+
+// flushRange applies the pending inserts and deletes for keys in [start, end] to
+// the leaves, so that they can be scanned.
+// Time complexity: O(log n + m log n) where n is the number of keys in the tree
+// and m is the number of pending messages in the range.
+func (t *GenericBPlusTree[K]) flushRange(start, end K) {
+	if t.buffered() && t.compare(start, end) <= 0 {
+		t.collectMessages(t.root, start, end, true)
+		t.applyPending()
+	}
+}
+
+// buffered returns true if t is a buffered tree with branches, whose buffers may
+// hold messages. A buffered tree of a single leaf changes the leaf directly.
+// Time complexity: O(1)
+func (t *GenericBPlusTree[K]) buffered() bool {
+	return t.bufferSize > 0 && t.root.kind == Branch
+}
+
+// mayContainBuffered returns true if key is in a buffered tree, asking the
+// bloom filter first so that most missing keys need no descent. The filter is
+// recomputed if it is invalid, which happens again only once it has grown.
+// Time complexity: O(k) if the bloom filter rules the key out, where k is the
+// number of hash functions, O(log n) otherwise, plus O(1) amortized for recomputing.
+func (t *GenericBPlusTree[K]) mayContainBuffered(key K) bool {
+	if !t.bloomFilter.IsValid() {
+		t.recomputeBloomFilter()
+	}
+	if !t.bloomFilter.Contains(t.hashFunc(key)) {
+		return false
+	}
+	return t.containsBuffered(key)
+}
+
+// containsBuffered returns true if key is in a buffered tree. The first message
+// for key on the way down is the newest and decides; without one, the leaf does.
+// Time complexity: O(log n) where n is the number of keys in the tree.
+func (t *GenericBPlusTree[K]) containsBuffered(key K) bool {
+	n := t.root
+	for n.kind == Branch {
+		if pos, found := t.search(n.buffer.keys, key); found {
+			return !n.buffer.deletes[pos]
+		}
+		n = n.children[n.FindChildIndex(key, t.search)]
+	}
+	return n.Contains(key, t.search)
+}
+
+// bufferMessage adds an insert or delete of key to the root's buffer, and flushes
+// the buffer if it overflows. The message replaces an older one for key in the buffer.
+// Time complexity: O(b) where b is the buffer size, plus the flush, which is
+// O(log n) amortized per message where n is the number of keys in the tree.
+func (t *GenericBPlusTree[K]) bufferMessage(key K, deleted bool) {
+	root := t.root
+	pos, found := t.search(root.buffer.keys, key)
+	if found {
+		root.buffer.deletes[pos] = deleted
+		return
+	}
+
+	root.buffer.insert(pos, key, deleted)
+	if root.buffer.len() > t.bufferSize {
+		t.flush(root)
+		t.applyPending()
+	}
+}
+
+// flush moves all messages out of the buffer of branch n, each to the child
+// whose keys it belongs to. Messages for a branch join its buffer, which is
+// flushed in turn if it overflows; messages for a leaf are queued in pending.
+// Flushing does not change the shape of the tree, so it can recurse safely.
+// Time complexity: O(B + b) where B is the branching factor and b is the buffer
+// size, plus the flushes of the children.
+func (t *GenericBPlusTree[K]) flush(n *node[K]) {
+	start := 0
+	for i, child := range n.children {
+		// Messages for keys below the separator to the right belong to this child
+		end := n.buffer.len()
+		if i < len(n.keys) {
+			count, _ := t.search(n.buffer.keys[start:], n.keys[i])
+			end = start + count
+		}
+		if start == end {
+			continue
+		}
+
+		if child.kind == Leaf {
+			t.pending.appendRange(&n.buffer, start, end)
+		} else {
+			t.mergeMessages(&child.buffer, &n.buffer, start, end)
+			if child.buffer.len() > t.bufferSize {
+				t.flush(child)
+			}
+		}
+		start = end
+	}
+	n.buffer.reset()
+}
+
+// mergeMessages merges the messages src[lo:hi] into dst. They are newer than
+// the messages in dst, which belong to a node below, so they replace the
+// messages in dst for the same keys.
+// Time complexity: O(b) where b is the number of messages in both buffers.
+func (t *GenericBPlusTree[K]) mergeMessages(dst, src *messageBuffer[K], lo, hi int) {
+	merged := &t.scratch
+	merged.reset()
+
+	i, j := 0, lo
+	for i < dst.len() && j < hi {
+		switch c := t.compare(dst.keys[i], src.keys[j]); {
+		case c < 0:
+			merged.push(dst.keys[i], dst.deletes[i])
+			i++
+		case c > 0:
+			merged.push(src.keys[j], src.deletes[j])
+			j++
+		default:
+			merged.push(src.keys[j], src.deletes[j])
+			i++
+			j++
+		}
+	}
+	merged.appendRange(dst, i, dst.len())
+	merged.appendRange(src, j, hi)
+
+	// Keep the merged messages and reuse the old space for the next merge
+	*dst, *merged = *merged, *dst
+}
+
+// collectMessages moves the messages for keys in [start, end] out of the buffers
+// of the subtree rooted at n into pending, or all of its messages if not bounded.
+// Messages deeper down are older than those above them, so they are collected first.
+// Time complexity: O(s log b + m) where s is the number of branches in the range,
+// b is the buffer size and m is the number of messages collected.
+func (t *GenericBPlusTree[K]) collectMessages(n *node[K], start, end K, bounded bool) {
+	first, last := 0, len(n.children)-1
+	lo, hi := 0, n.buffer.len()
+	if bounded {
+		first, last = n.FindChildIndex(start, t.search), n.FindChildIndex(end, t.search)
+		lo, _ = t.search(n.buffer.keys, start)
+		hi = t.countUpTo(n.buffer.keys, end)
+	}
+
+	for _, child := range n.children[first : last+1] {
+		if child.kind == Branch {
+			t.collectMessages(child, start, end, bounded)
+		}
+	}
+	t.pending.appendRange(&n.buffer, lo, hi)
+	n.buffer.remove(lo, hi)
+}
+
+// applyPending applies the messages queued in pending to the leaves, in order,
+// splitting and rebalancing nodes like unbuffered inserts and deletes do.
+// Messages for the same key are applied oldest first. The size and the filters
+// already count the messages, see Insert and Delete.
+// A run of messages for the same leaf shares one descent, until one of them
+// changes the shape of the tree. Collapsing the root may queue more messages,
+// which are applied as well.
+// Time complexity: O(m log n) where m is the number of messages and n is the number of keys in the tree.
+func (t *GenericBPlusTree[K]) applyPending() {
+	for i := 0; i < t.pending.len(); {
+		path := t.descend(t.pending.keys[i])
+		if path == nil {
+			i++
+			continue
+		}
+		for end := t.leafRun(path, i); i < end; {
+			reshaped := t.applyMessage(path, t.pending.keys[i], t.pending.deletes[i])
+			i++
+			if reshaped {
+				break
+			}
+		}
+	}
+	t.pending.reset()
+}
+
+// leafRun returns the end of the run of pending messages from index from on
+// whose keys belong to the leaf at the end of path. The nearest separators on
+// the way down bound the keys of the leaf.
+// Time complexity: O(h + r) where h is the height of the tree and r is the length of the run.
+func (t *GenericBPlusTree[K]) leafRun(path *treePath[K], from int) int {
+	var lower, upper *K
+	for i := len(path.frames) - 1; i >= 0 && (lower == nil || upper == nil); i-- {
+		frame := path.frames[i]
+		if lower == nil && frame.index > 0 {
+			lower = &frame.node.keys[frame.index-1]
+		}
+		if upper == nil && frame.index < len(frame.node.keys) {
+			upper = &frame.node.keys[frame.index]
+		}
+	}
+
+	end := from + 1
+	for ; end < t.pending.len(); end++ {
+		key := t.pending.keys[end]
+		if lower != nil && t.compare(key, *lower) < 0 || upper != nil && t.compare(key, *upper) >= 0 {
+			break
+		}
+	}
+	return end
+}
+
+// applyMessage applies an insert or delete of key to the leaf at the end of
+// path. Returns true if the leaf was split or rebalanced, which changes the
+// shape of the tree and leaves path out of date.
+// Time complexity: O(B) where B is the branching factor, plus O(B log n) for
+// a split or a rebalance, where n is the number of keys in the tree.
+func (t *GenericBPlusTree[K]) applyMessage(path *treePath[K], key K, deleted bool) bool {
+	leaf := path.leaf
+	if deleted {
+		if !leaf.DeleteKey(key, t.search) {
+			return false
+		}
+		t.markModified(leaf)
+		if !leaf.IsUnderflow(t.branchingFactor) {
+			return false
+		}
+		t.rebalance(path)
+		t.handleRootUnderflow()
+		return true
+	}
+
+	if !leaf.InsertKey(key, t.search) {
+		return false
+	}
+	t.markModified(leaf)
+	if !leaf.overflows(t.branchingFactor) {
+		return false
+	}
+	t.splitOverflowing(path)
+	return true
+}
+
+// moveMessagesRight moves the messages of left for keys from separator on to the
+// front of the buffer of right, after a split or a borrow moved the separator
+// between the two branches to the left.
+// Time complexity: O(b) where b is the number of messages in both buffers.
+func (t *GenericBPlusTree[K]) moveMessagesRight(left, right *node[K], separator K) {
+	pos, _ := t.search(left.buffer.keys, separator)
+	right.buffer.insertRange(0, &left.buffer, pos, left.buffer.len())
+	left.buffer.remove(pos, left.buffer.len())
+}
+
+// moveMessagesLeft moves the messages of right for keys below separator to the
+// end of the buffer of left, after a borrow moved the separator between the two
+// branches to the right.
+// Time complexity: O(b) where b is the number of messages in both buffers.
+func (t *GenericBPlusTree[K]) moveMessagesLeft(left, right *node[K], separator K) {
+	pos, _ := t.search(right.buffer.keys, separator)
+	left.buffer.appendRange(&right.buffer, 0, pos)
+	right.buffer.remove(0, pos)
+}
+
+// inheritMessages hands the messages of a root that is being removed to its only
+// child, which becomes the root. A leaf gets them through pending.
+// Time complexity: O(b) where b is the number of messages in both buffers.
+func (t *GenericBPlusTree[K]) inheritMessages(oldRoot, child *node[K]) {
+	if oldRoot.buffer.len() == 0 {
+		return
+	}
+	if child.kind == Leaf {
+		t.pending.appendRange(&oldRoot.buffer, 0, oldRoot.buffer.len())
+	} else {
+		t.mergeMessages(&child.buffer, &oldRoot.buffer, 0, oldRoot.buffer.len())
+	}
+	oldRoot.buffer.reset()
+}
@@ -0,0 +1,261 @@
+package bplustree
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestBufferedTreeMatchesMap tests random inserts, deletes and lookups on
+// buffered trees against a map, with buffers small enough to flush often
+func TestBufferedTreeMatchesMap(t *testing.T) {
+	for _, bf := range []int{3, 4, 5, 8, 32} {
+		for _, bufferSize := range []int{1, 4, 16, 100} {
+			t.Run(fmt.Sprintf("bf=%d/buffer=%d", bf, bufferSize), func(t *testing.T) {
+				tree := NewBufferedOrderedTree[int](bf, bufferSize)
+				present := make(map[int]bool)
+				r := rand.New(rand.NewSource(int64(bf*1000 + bufferSize)))
+
+				for i := 0; i < 20000; i++ {
+					key := r.Intn(3000)
+					switch r.Intn(8) {
+					case 0, 1:
+						if got, want := tree.Delete(key), present[key]; got != want {
+							t.Fatalf("Expected Delete(%d) = %v, got %v", key, want, got)
+						}
+						delete(present, key)
+					case 2:
+						if got, want := tree.Contains(key), present[key]; got != want {
+							t.Fatalf("Expected Contains(%d) = %v, got %v", key, want, got)
+						}
+					default:
+						if got, want := tree.Insert(key), !present[key]; got != want {
+							t.Fatalf("Expected Insert(%d) = %v, got %v", key, want, got)
+						}
+						present[key] = true
+					}
+
+					if i%500 == 0 {
+						checkTreeInvariants(t, tree)
+						start := r.Intn(3000)
+						end := start + r.Intn(200)
+						want := 0
+						for key := start; key <= end; key++ {
+							if present[key] {
+								want++
+							}
+						}
+						if got := tree.CountRange(start, end); got != want {
+							t.Fatalf("Expected CountRange(%d, %d) = %d, got %d", start, end, want, got)
+						}
+						checkTreeInvariants(t, tree)
+					}
+				}
+
+				if tree.Size() != len(present) {
+					t.Errorf("Expected size %d, got %d", len(present), tree.Size())
+				}
+				want := make([]int, 0, len(present))
+				for key := range present {
+					want = append(want, key)
+				}
+				slices.Sort(want)
+				if got := tree.RangeQuery(0, 3000); !slices.Equal(got, want) {
+					t.Errorf("Expected RangeQuery to return %d keys, got %d", len(want), len(got))
+				}
+
+				tree.Flush()
+				checkTreeInvariants(t, tree)
+				if got := tree.CountKeys(); got != len(present) {
+					t.Errorf("Expected %d keys in the leaves after Flush, got %d", len(present), got)
+				}
+			})
+		}
+	}
+}
+
+// TestBufferedTreeDefersChanges tests that inserts and deletes wait in the
+// root's buffer until it overflows, and that newer messages replace older ones
+func TestBufferedTreeDefersChanges(t *testing.T) {
+	tree := NewBufferedOrderedTree[int](4, 8)
+	for i := 0; i < 100; i += 2 {
+		tree.Insert(i)
+	}
+	tree.Flush()
+	if tree.BufferSize() != 8 || tree.Height() < 2 {
+		t.Fatalf("Expected a buffered tree with branches, got buffer size %d and height %d", tree.BufferSize(), tree.Height())
+	}
+	if got := NewBufferedOrderedTree[int](4, 0).BufferSize(); got != defaultBufferSize {
+		t.Errorf("Expected the default buffer size %d, got %d", defaultBufferSize, got)
+	}
+
+	// Inserts and deletes stay in the root's buffer
+	tree.Insert(1)
+	tree.Insert(3)
+	tree.Delete(10)
+	if got := tree.root.buffer.len(); got != 3 {
+		t.Fatalf("Expected 3 buffered messages, got %d", got)
+	}
+	if !tree.Contains(1) || !tree.Contains(3) || tree.Contains(10) {
+		t.Fatalf("Expected lookups to include buffered messages")
+	}
+	if got := tree.Size(); got != 51 || tree.root.buffer.len() != 3 {
+		t.Fatalf("Expected Size to count the buffered messages without applying them, got %d", got)
+	}
+	checkTreeInvariants(t, tree)
+
+	// Deleting a key with a buffered insert, and inserting one with a
+	// buffered delete, replace the older messages
+	tree.Delete(1)
+	tree.Insert(10)
+	tree.Insert(3)
+	if got := tree.root.buffer.len(); got != 3 {
+		t.Fatalf("Expected newer messages to replace older ones, leaving 3, got %d", got)
+	}
+	if tree.Contains(1) || !tree.Contains(3) || !tree.Contains(10) {
+		t.Fatalf("Expected lookups to find the newest messages")
+	}
+
+	// A range query applies the messages in its range only
+	if got := tree.RangeQuery(0, 4); !slices.Equal(got, []int{0, 2, 3, 4}) {
+		t.Fatalf("Expected [0 2 3 4], got %v", got)
+	}
+	if got := tree.root.buffer.len(); got != 1 {
+		t.Fatalf("Expected the range query to leave only the message for 10, got %d messages", got)
+	}
+
+	// Overflowing the root's buffer flushes it
+	for i := 101; i < 120; i += 2 {
+		tree.Insert(i)
+	}
+	if got := tree.root.buffer.len(); got > tree.BufferSize() {
+		t.Fatalf("Expected at most %d messages at the root, got %d", tree.BufferSize(), got)
+	}
+	checkTreeInvariants(t, tree)
+	if got := PrintTree(tree); !strings.Contains(got, "Internal") {
+		t.Errorf("Expected PrintTree to print the branches, got %q", got)
+	}
+
+	if got := tree.Size(); got != 61 {
+		t.Errorf("Expected 61 keys, got %d", got)
+	}
+	tree.Flush()
+	if got := tree.CountKeys(); got != 61 {
+		t.Errorf("Expected 61 keys in the leaves after Flush, got %d", got)
+	}
+	checkTreeInvariants(t, tree)
+}
+
+// TestBufferedTreeDuplicates tests that inserting present keys and deleting
+// missing ones leaves the buffers and the filters unchanged
+func TestBufferedTreeDuplicates(t *testing.T) {
+	tree := NewBufferedOrderedTree[int](4, 64)
+	filter := NewCuckooFilter(200)
+	tree.bloomFilter = filter
+	for i := 0; i < 100; i++ {
+		tree.Insert(i)
+	}
+	tree.Contains(0) // Make the filter valid
+
+	messages := tree.root.buffer.len()
+	for round := 0; round < 50; round++ {
+		for i := 0; i < 100; i++ {
+			if tree.Insert(i) {
+				t.Fatalf("Expected Insert(%d) of a present key to return false", i)
+			}
+			if tree.Delete(1000 + i) {
+				t.Fatalf("Expected Delete(%d) of a missing key to return false", 1000+i)
+			}
+		}
+	}
+	if !filter.IsValid() || filter.Len() != 100 {
+		t.Fatalf("Expected a valid filter holding 100 keys, got valid %v and %d keys", filter.IsValid(), filter.Len())
+	}
+	if got := tree.Size(); got != 100 || tree.root.buffer.len() != messages {
+		t.Fatalf("Expected size 100 and the buffer unchanged, got %d and %d messages", got, tree.root.buffer.len())
+	}
+	checkTreeInvariants(t, tree)
+}
+
+// TestBufferedTreeShrinksAndGrows tests buffered trees that collapse to a
+// single leaf, with messages pending above it, and grow again
+func TestBufferedTreeShrinksAndGrows(t *testing.T) {
+	tree := NewBufferedTree(3, 5, cmp.Compare[int], func(key int) uint64 { return uint64(key) })
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 300; i++ {
+			tree.Insert(i)
+		}
+		checkTreeInvariants(t, tree)
+		for i := 0; i < 300; i++ {
+			if !tree.Delete(i) {
+				t.Fatalf("Expected to delete %d in round %d", i, round)
+			}
+		}
+		checkTreeInvariants(t, tree)
+		if tree.Size() != 0 || tree.CountKeys() != 0 {
+			t.Fatalf("Expected an empty tree in round %d, got size %d", round, tree.Size())
+		}
+	}
+
+	// A range query applies deletes that collapse the root while the root
+	// still buffers messages outside the range, which move to the new root
+	for _, keep := range []int{2, 40} {
+		tree := NewBufferedOrderedTree[int](3, 1000)
+		for i := 0; i < 100; i++ {
+			tree.Insert(i)
+		}
+		tree.Flush()
+		for i := 0; i < 100-keep; i++ {
+			tree.Delete(i)
+		}
+		tree.Insert(-1)
+		tree.Delete(99)
+
+		if got := tree.CountRange(0, 100-keep-1); got != 0 {
+			t.Fatalf("Expected no keys left in the deleted range, got %d", got)
+		}
+		checkTreeInvariants(t, tree)
+		if !tree.Contains(-1) || tree.Contains(99) || tree.Size() != keep {
+			t.Fatalf("Expected messages outside the range to survive the collapse, got size %d", tree.Size())
+		}
+		want := append([]int{-1}, tree.RangeQuery(100-keep, 98)...)
+		if got := tree.GetAllKeys(); !slices.Equal(got, want) || len(got) != keep {
+			t.Fatalf("Expected %v, got %v", want, got)
+		}
+	}
+}
+
+// TestBufferedTreeDelta tests that a delta of a buffered tree includes the
+// buffered changes, so a plain replica ends up with the same keys
+func TestBufferedTreeDelta(t *testing.T) {
+	primary := NewBufferedOrderedTree[uint64](8, 16)
+	replica := NewOrderedTree[uint64](8)
+	r := rand.New(rand.NewSource(3))
+	for i := 0; i < 2000; i++ {
+		primary.Insert(uint64(r.Intn(5000)))
+	}
+
+	var since uint64
+	for round := 0; round < 3; round++ {
+		var buf bytes.Buffer
+		if err := primary.ExportDelta(since, &buf, Uint64Codec{}); err != nil {
+			t.Fatalf("ExportDelta failed: %v", err)
+		}
+		if err := replica.ApplyDelta(&buf, Uint64Codec{}); err != nil {
+			t.Fatalf("ApplyDelta failed: %v", err)
+		}
+		if got, want := replica.GetAllKeys(), primary.GetAllKeys(); !slices.Equal(got, want) {
+			t.Fatalf("Expected the replica to hold %d keys in round %d, got %d", len(want), round, len(got))
+		}
+
+		since = primary.Checkpoint()
+		for i := 0; i < 100; i++ {
+			primary.Insert(uint64(r.Intn(5000)))
+			primary.Delete(uint64(r.Intn(5000)))
+		}
+	}
+}
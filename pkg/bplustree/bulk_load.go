@@ -0,0 +1,473 @@
+package bplustree
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"sort"
+	"unsafe"
+)
+
+// defaultMemLimit is the in-memory sort buffer used when BuildFromUnsorted
+// is called with a non-positive memLimit.
+const defaultMemLimit = 64 << 20
+
+// progressInterval is the number of keys between two progress reports.
+const progressInterval = 1 << 16
+
+// BulkLoadPhase identifies the stage of a bulk load.
+type BulkLoadPhase int
+
+const (
+	// BulkLoadSorting means input keys are being read and spilled as sorted runs.
+	BulkLoadSorting BulkLoadPhase = iota
+	// BulkLoadMerging means sorted runs are being merged into the tree.
+	BulkLoadMerging
+	// BulkLoadDone means the tree has been built.
+	BulkLoadDone
+)
+
+// BulkLoadProgress reports how far a bulk load has come.
+type BulkLoadProgress struct {
+	Phase       BulkLoadPhase // Current stage of the load
+	KeysRead    int64         // Keys consumed from the input so far
+	RunsWritten int           // Sorted run files written to the temporary directory
+	KeysLoaded  int64         // Distinct keys added to the tree so far
+}
+
+// BuildFromUnsorted builds a tree from an unsorted stream of keys that may be
+// larger than memory.
+//
+// Keys are buffered until the buffer would exceed memLimit bytes, then sorted
+// and written as a run file in tempDir. The runs are merged and the merged,
+// de-duplicated stream is fed into a bottom-up bulk load, so every leaf and
+// branch is built exactly once instead of by repeated splitting. If the whole
+// input fits in memory no run files are written. Run files are removed before
+// BuildFromUnsorted returns.
+//
+// Parameters:
+//   - keys: The input keys, in any order. Duplicates are ignored.
+//   - tempDir: Directory for run files. An empty string means os.TempDir().
+//   - memLimit: Approximate size in bytes of the in-memory sort buffer.
+//   - codec: Orders, hashes and serializes the keys.
+//   - branchingFactor: The maximum number of children per node. Must be at least 3.
+//   - progress: Called as the load advances. May be nil.
+//
+// Time complexity: O(n log n) comparisons and O(n) disk writes and reads.
+func BuildFromUnsorted[K any](
+	keys iter.Seq[K],
+	tempDir string,
+	memLimit int,
+	codec Codec[K],
+	branchingFactor int,
+	progress func(BulkLoadProgress),
+) (*GenericBPlusTree[K], error) {
+	if memLimit <= 0 {
+		memLimit = defaultMemLimit
+	}
+	if progress == nil {
+		progress = func(BulkLoadProgress) {}
+	}
+
+	tree := NewGenericBPlusTree(branchingFactor, codec.Less, codec.Equal, codec.Hash)
+	sorter := &externalSorter[K]{
+		codec:    codec,
+		tempDir:  tempDir,
+		memLimit: memLimit,
+		progress: progress,
+	}
+	defer sorter.removeRuns()
+
+	if err := sorter.sortRuns(keys); err != nil {
+		return nil, err
+	}
+
+	loader := newBulkLoader(tree)
+	err := sorter.merge(func(key K) {
+		loader.add(key)
+		if loader.count%progressInterval == 0 {
+			progress(sorter.report(BulkLoadMerging, loader.count))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	loader.finish()
+
+	progress(sorter.report(BulkLoadDone, loader.count))
+	return tree, nil
+}
+
+// externalSorter splits an input stream into sorted runs on disk and merges them.
+type externalSorter[K any] struct {
+	codec    Codec[K]
+	tempDir  string
+	memLimit int
+	progress func(BulkLoadProgress)
+
+	buffer   []K      // Keys not yet spilled to a run
+	runs     []string // Paths of the run files written so far
+	keysRead int64
+}
+
+// sortRuns reads all keys, spilling a sorted run whenever the buffer is full.
+// If no run had to be written, the keys stay in the buffer.
+func (s *externalSorter[K]) sortRuns(keys iter.Seq[K]) error {
+	keySize := int(unsafe.Sizeof(*new(K)))
+	var scratch []byte
+	used := 0
+
+	var err error
+	for key := range keys {
+		scratch = s.codec.AppendKey(scratch[:0], key)
+		s.buffer = append(s.buffer, key)
+		s.keysRead++
+		used += keySize + len(scratch)
+
+		if used >= s.memLimit {
+			if err = s.spill(); err != nil {
+				break
+			}
+			used = 0
+		}
+		if s.keysRead%progressInterval == 0 {
+			s.progress(s.report(BulkLoadSorting, 0))
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	s.sortBuffer()
+	if len(s.runs) > 0 && len(s.buffer) > 0 {
+		return s.spill()
+	}
+	return nil
+}
+
+// sortBuffer sorts the buffered keys and drops duplicates.
+func (s *externalSorter[K]) sortBuffer() {
+	sort.Slice(s.buffer, func(i, j int) bool {
+		return s.codec.Less(s.buffer[i], s.buffer[j])
+	})
+
+	unique := s.buffer[:0]
+	for i, key := range s.buffer {
+		if i == 0 || !s.codec.Equal(unique[len(unique)-1], key) {
+			unique = append(unique, key)
+		}
+	}
+	s.buffer = unique
+}
+
+// spill writes the buffered keys as a sorted run file and empties the buffer.
+// Each key is stored as a uvarint length followed by its encoding.
+func (s *externalSorter[K]) spill() error {
+	s.sortBuffer()
+
+	f, err := os.CreateTemp(s.tempDir, "bplustree-run-*")
+	if err != nil {
+		return fmt.Errorf("bplustree: creating run file: %w", err)
+	}
+	s.runs = append(s.runs, f.Name())
+
+	w := bufio.NewWriter(f)
+	var scratch, prefix []byte
+	for _, key := range s.buffer {
+		scratch = s.codec.AppendKey(scratch[:0], key)
+		prefix = binary.AppendUvarint(prefix[:0], uint64(len(scratch)))
+		if _, err = w.Write(prefix); err != nil {
+			break
+		}
+		if _, err = w.Write(scratch); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("bplustree: writing run file: %w", err)
+	}
+
+	s.buffer = s.buffer[:0]
+	s.progress(s.report(BulkLoadSorting, 0))
+	return nil
+}
+
+// merge calls emit for every distinct key in ascending order.
+func (s *externalSorter[K]) merge(emit func(K)) error {
+	if len(s.runs) == 0 {
+		for _, key := range s.buffer {
+			emit(key)
+		}
+		return nil
+	}
+
+	h := &runHeap[K]{less: s.codec.Less}
+	for _, path := range s.runs {
+		r, err := openRunReader(path, s.codec)
+		if err != nil {
+			return err
+		}
+		defer r.close()
+
+		ok, err := r.advance()
+		if err != nil {
+			return err
+		}
+		if ok {
+			h.readers = append(h.readers, r)
+		}
+	}
+	heap.Init(h)
+
+	var last K
+	emitted := false
+	for h.Len() > 0 {
+		r := h.readers[0]
+		if !emitted || !s.codec.Equal(last, r.current) {
+			emit(r.current)
+			last = r.current
+			emitted = true
+		}
+
+		ok, err := r.advance()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+	return nil
+}
+
+// removeRuns deletes all run files written so far.
+func (s *externalSorter[K]) removeRuns() {
+	for _, path := range s.runs {
+		os.Remove(path)
+	}
+	s.runs = nil
+}
+
+// report builds a progress snapshot for the given phase.
+func (s *externalSorter[K]) report(phase BulkLoadPhase, keysLoaded int64) BulkLoadProgress {
+	return BulkLoadProgress{
+		Phase:       phase,
+		KeysRead:    s.keysRead,
+		RunsWritten: len(s.runs),
+		KeysLoaded:  keysLoaded,
+	}
+}
+
+// runReader reads keys back from a run file.
+type runReader[K any] struct {
+	file    *os.File
+	reader  *bufio.Reader
+	codec   Codec[K]
+	scratch []byte
+	current K
+}
+
+// openRunReader opens the run file at path.
+func openRunReader[K any](path string, codec Codec[K]) (*runReader[K], error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("bplustree: opening run file: %w", err)
+	}
+	return &runReader[K]{file: f, reader: bufio.NewReader(f), codec: codec}, nil
+}
+
+// advance reads the next key into current.
+// Returns false once the run is exhausted.
+func (r *runReader[K]) advance() (bool, error) {
+	n, err := binary.ReadUvarint(r.reader)
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("bplustree: reading run file: %w", err)
+	}
+
+	if uint64(cap(r.scratch)) < n {
+		r.scratch = make([]byte, n)
+	}
+	r.scratch = r.scratch[:n]
+	if _, err := io.ReadFull(r.reader, r.scratch); err != nil {
+		return false, fmt.Errorf("bplustree: reading run file: %w", err)
+	}
+
+	r.current, err = r.codec.DecodeKey(r.scratch)
+	if err != nil {
+		return false, fmt.Errorf("bplustree: reading run file: %w", err)
+	}
+	return true, nil
+}
+
+// close closes the underlying file.
+func (r *runReader[K]) close() {
+	r.file.Close()
+}
+
+// runHeap is a min-heap of run readers ordered by their current key.
+type runHeap[K any] struct {
+	readers []*runReader[K]
+	less    func(a, b K) bool
+}
+
+func (h *runHeap[K]) Len() int { return len(h.readers) }
+
+func (h *runHeap[K]) Less(i, j int) bool {
+	return h.less(h.readers[i].current, h.readers[j].current)
+}
+
+func (h *runHeap[K]) Swap(i, j int) { h.readers[i], h.readers[j] = h.readers[j], h.readers[i] }
+
+func (h *runHeap[K]) Push(x any) { h.readers = append(h.readers, x.(*runReader[K])) }
+
+func (h *runHeap[K]) Pop() any {
+	last := h.readers[len(h.readers)-1]
+	h.readers = h.readers[:len(h.readers)-1]
+	return last
+}
+
+// bulkLoader builds a tree bottom-up from keys supplied in ascending order.
+// Leaves are filled to the branching factor; only the last leaf and the last
+// node of each branch level are rebalanced so that no node underflows.
+type bulkLoader[K any] struct {
+	tree   *GenericBPlusTree[K]
+	leaves []*node[K]
+	count  int64
+}
+
+// newBulkLoader returns a loader that will replace the contents of tree.
+func newBulkLoader[K any](tree *GenericBPlusTree[K]) *bulkLoader[K] {
+	return &bulkLoader[K]{tree: tree}
+}
+
+// add appends the next key. Keys must be distinct and in ascending order.
+// Time complexity: O(1) amortized.
+func (l *bulkLoader[K]) add(key K) {
+	if len(l.leaves) == 0 || len(l.leaves[len(l.leaves)-1].keys) >= l.tree.branchingFactor {
+		leaf := l.tree.pool.newLeaf()
+		l.tree.markModified(leaf)
+		if len(l.leaves) > 0 {
+			l.leaves[len(l.leaves)-1].next = leaf
+		}
+		l.leaves = append(l.leaves, leaf)
+	}
+
+	leaf := l.leaves[len(l.leaves)-1]
+	leaf.keys = append(leaf.keys, key)
+	l.count++
+}
+
+// finish builds the branch levels above the leaves and installs the result
+// as the tree's root.
+// Time complexity: O(n / B) where B is the branching factor.
+func (l *bulkLoader[K]) finish() {
+	t := l.tree
+	if len(l.leaves) == 0 {
+		t.Clear()
+		return
+	}
+	l.rebalanceLastLeaf()
+
+	level := make([]*node[K], len(l.leaves))
+	mins := make([]K, len(l.leaves))
+	for i, leaf := range l.leaves {
+		level[i] = leaf
+		mins[i] = leaf.keys[0]
+	}
+
+	height := 1
+	for len(level) > 1 {
+		level, mins = l.buildBranchLevel(level, mins)
+		height++
+	}
+
+	t.pool.freeSubtree(t.root)
+	t.root = level[0]
+	t.rightmost = l.leaves[len(l.leaves)-1]
+	t.moveFinger(nil)
+	t.height = height
+	t.size = int(l.count)
+	t.growBloomFilter()
+	if !t.bloomFilter.IsValid() {
+		t.recomputeBloomFilter()
+	}
+}
+
+// rebalanceLastLeaf moves keys from the second-to-last leaf into the last
+// leaf if the last leaf would otherwise underflow.
+func (l *bulkLoader[K]) rebalanceLastLeaf() {
+	if len(l.leaves) < 2 {
+		return
+	}
+	prev := l.leaves[len(l.leaves)-2]
+	last := l.leaves[len(l.leaves)-1]
+	if !last.IsUnderflow(l.tree.branchingFactor) {
+		return
+	}
+
+	split := (len(prev.keys) + len(last.keys) + 1) / 2
+	moved := len(prev.keys) - split
+	last.keys = last.keys[:len(last.keys)+moved]
+	copy(last.keys[moved:], last.keys)
+	copy(last.keys, prev.keys[split:])
+	prev.keys = truncate(prev.keys, split)
+}
+
+// buildBranchLevel groups the nodes of one level under new branch nodes.
+// mins holds the smallest key of each node's subtree and is used for separators.
+// Returns the new level and the smallest key of each new node's subtree.
+func (l *bulkLoader[K]) buildBranchLevel(children []*node[K], mins []K) ([]*node[K], []K) {
+	sizes := chunkSizes(len(children), l.tree.branchingFactor, minInternalKeys(l.tree.branchingFactor)+1)
+
+	parents := make([]*node[K], 0, len(sizes))
+	parentMins := make([]K, 0, len(sizes))
+	start := 0
+	for _, size := range sizes {
+		branch := l.tree.pool.newBranch()
+		branch.children = append(branch.children, children[start:start+size]...)
+		branch.keys = append(branch.keys, mins[start+1:start+size]...)
+
+		parents = append(parents, branch)
+		parentMins = append(parentMins, mins[start])
+		start += size
+	}
+	return parents, parentMins
+}
+
+// chunkSizes splits n items into groups of at most capacity items.
+// All groups are full except the last two, which are evened out so that
+// neither holds fewer than minimum items when there is more than one group.
+func chunkSizes(n, capacity, minimum int) []int {
+	sizes := make([]int, 0, n/capacity+1)
+	for n > 0 {
+		size := capacity
+		if n < size {
+			size = n
+		}
+		sizes = append(sizes, size)
+		n -= size
+	}
+
+	last := len(sizes) - 1
+	if last > 0 && sizes[last] < minimum {
+		total := sizes[last-1] + sizes[last]
+		sizes[last-1] = (total + 1) / 2
+		sizes[last] = total / 2
+	}
+	return sizes
+}
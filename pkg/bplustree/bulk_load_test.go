@@ -0,0 +1,255 @@
+package bplustree
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// checkTreeInvariants verifies the structural properties of a B+ tree:
+// sorted keys, correct separators, node occupancy, uniform leaf depth,
+// a consistent leaf chain, sorted message buffers in the right branches
+// and a size counter matching the stored keys.
+func checkTreeInvariants[K any](t *testing.T, tree *GenericBPlusTree[K]) {
+	t.Helper()
+
+	// Right- and left-heavy splits leave light nodes on purpose
+	checkUnderflow := tree.splitPolicy == SplitMidpoint
+
+	var leaves []*node[K]
+	var walk func(n *node[K], depth int, isRoot bool)
+	walk = func(n *node[K], depth int, isRoot bool) {
+		switch n.kind {
+		case Leaf:
+			if depth != tree.height {
+				t.Fatalf("leaf at depth %d, expected height %d", depth, tree.height)
+			}
+			if checkUnderflow && !isRoot && n.IsUnderflow(tree.branchingFactor) {
+				t.Fatalf("leaf underflow: %d keys", len(n.keys))
+			}
+			leaves = append(leaves, n)
+		case Branch:
+			if len(n.children) != len(n.keys)+1 {
+				t.Fatalf("branch has %d keys and %d children", len(n.keys), len(n.children))
+			}
+			if checkUnderflow && !isRoot && n.IsUnderflow(tree.branchingFactor) {
+				t.Fatalf("branch underflow: %d keys", len(n.keys))
+			}
+			if len(n.buffer.keys) != len(n.buffer.deletes) {
+				t.Fatalf("buffer has %d keys and %d delete flags", len(n.buffer.keys), len(n.buffer.deletes))
+			}
+			for i := 1; i < len(n.buffer.keys); i++ {
+				if tree.compare(n.buffer.keys[i-1], n.buffer.keys[i]) >= 0 {
+					t.Fatalf("buffered keys out of order: %v then %v", n.buffer.keys[i-1], n.buffer.keys[i])
+				}
+			}
+			for i, child := range n.children {
+				for _, key := range subtreeKeys(child) {
+					if i > 0 && tree.compare(key, n.keys[i-1]) < 0 {
+						t.Fatalf("key %v left of separator %v", key, n.keys[i-1])
+					}
+					if i < len(n.keys) && tree.compare(key, n.keys[i]) >= 0 {
+						t.Fatalf("key %v right of separator %v", key, n.keys[i])
+					}
+				}
+				walk(child, depth+1, false)
+			}
+		}
+	}
+	walk(tree.root, 1, true)
+
+	count := 0
+	for i, leaf := range leaves {
+		if i+1 < len(leaves) && leaf.next != leaves[i+1] {
+			t.Fatalf("leaf chain broken after leaf %d", i)
+		}
+		count += len(leaf.keys)
+	}
+	if len(leaves) > 0 && leaves[len(leaves)-1].next != nil {
+		t.Fatalf("last leaf has a next pointer")
+	}
+	if len(leaves) > 0 && tree.rightmost != leaves[len(leaves)-1] {
+		t.Fatalf("rightmost leaf hint is not the last leaf")
+	}
+	if tree.finger != nil && tree.finger.leaf != nil && !slices.Contains(leaves, tree.finger.leaf) {
+		t.Fatalf("finger points at a leaf that is not in the tree")
+	}
+
+	// Read the leaves directly; GetAllKeys would flush the buffers
+	var keys []K
+	for _, leaf := range leaves {
+		keys = append(keys, leaf.keys...)
+	}
+	for i := 1; i < len(keys); i++ {
+		if tree.compare(keys[i-1], keys[i]) >= 0 {
+			t.Fatalf("keys out of order: %v then %v", keys[i-1], keys[i])
+		}
+	}
+	// The size counts buffered messages, so count the keys the newest message
+	// for each key, or else its leaf, holds
+	if tree.buffered() {
+		candidates := subtreeKeys(tree.root)
+		slices.SortFunc(candidates, tree.compare)
+		candidates = slices.CompactFunc(candidates, func(a, b K) bool { return tree.compare(a, b) == 0 })
+		count = 0
+		for _, key := range candidates {
+			if tree.containsBuffered(key) {
+				count++
+			}
+		}
+	}
+	if count != tree.size {
+		t.Fatalf("size is %d but the tree holds %d keys", tree.size, count)
+	}
+}
+
+// subtreeKeys returns every key stored in the leaves below node,
+// and the keys of the messages buffered in the branches below it.
+func subtreeKeys[K any](n *node[K]) []K {
+	switch n.kind {
+	case Leaf:
+		return n.keys
+	case Branch:
+		keys := slices.Clone(n.buffer.keys)
+		for _, child := range n.children {
+			keys = append(keys, subtreeKeys(child)...)
+		}
+		return keys
+	}
+	return nil
+}
+
+// TestBuildFromUnsortedSpillsRuns tests an external sort that needs several run files
+func TestBuildFromUnsortedSpillsRuns(t *testing.T) {
+	tempDir := t.TempDir()
+
+	input := make([]uint64, 20000)
+	for i := range input {
+		input[i] = uint64(rand.Intn(15000)) // Duplicates are expected
+	}
+
+	var last BulkLoadProgress
+	reports := 0
+	tree, err := BuildFromUnsorted(slices.Values(input), tempDir, 4096, Uint64Codec{}, 16,
+		func(p BulkLoadProgress) {
+			last = p
+			reports++
+		})
+	if err != nil {
+		t.Fatalf("BuildFromUnsorted failed: %v", err)
+	}
+
+	expected := slices.Clone(input)
+	slices.Sort(expected)
+	expected = slices.Compact(expected)
+
+	if tree.Size() != len(expected) {
+		t.Errorf("Expected size %d, got %d", len(expected), tree.Size())
+	}
+	if got := tree.RangeQuery(0, 15000); !slices.Equal(got, expected) {
+		t.Errorf("RangeQuery returned %d keys, expected %d", len(got), len(expected))
+	}
+	for _, key := range expected {
+		if !tree.Contains(key) {
+			t.Fatalf("Expected tree to contain %d", key)
+		}
+	}
+	checkTreeInvariants(t, tree)
+
+	if last.Phase != BulkLoadDone || last.RunsWritten < 2 || last.KeysRead != int64(len(input)) {
+		t.Errorf("Unexpected final progress %+v", last)
+	}
+	if reports < last.RunsWritten {
+		t.Errorf("Expected at least one report per run, got %d reports", reports)
+	}
+
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected run files to be removed, found %d", len(entries))
+	}
+
+	// The tree must keep working after a bulk load
+	if !tree.Insert(100000) || !tree.Delete(expected[0]) {
+		t.Errorf("Expected insert and delete to succeed after bulk load")
+	}
+}
+
+// TestBuildFromUnsortedInMemory tests input that fits in the sort buffer
+func TestBuildFromUnsortedInMemory(t *testing.T) {
+	tempDir := t.TempDir()
+
+	for _, n := range []int{0, 1, 3, 4, 5, 17, 100} {
+		t.Run(fmt.Sprintf("n=%d", n), func(t *testing.T) {
+			input := rand.Perm(n)
+
+			var last BulkLoadProgress
+			tree, err := BuildFromUnsorted(slices.Values(input), tempDir, 0, IntCodec{}, 4,
+				func(p BulkLoadProgress) { last = p })
+			if err != nil {
+				t.Fatalf("BuildFromUnsorted failed: %v", err)
+			}
+
+			if tree.Size() != n {
+				t.Errorf("Expected size %d, got %d", n, tree.Size())
+			}
+			if last.RunsWritten != 0 {
+				t.Errorf("Expected no runs, got %d", last.RunsWritten)
+			}
+			checkTreeInvariants(t, tree)
+		})
+	}
+}
+
+// TestBuildFromUnsortedStrings tests a bulk load of string keys
+func TestBuildFromUnsortedStrings(t *testing.T) {
+	input := make([]string, 5000)
+	for i := range input {
+		input[i] = randomString(1 + rand.Intn(20))
+	}
+
+	tree, err := BuildFromUnsorted(slices.Values(input), t.TempDir(), 2048, StringCodec{}, 8, nil)
+	if err != nil {
+		t.Fatalf("BuildFromUnsorted failed: %v", err)
+	}
+
+	expected := slices.Clone(input)
+	sort.Strings(expected)
+	expected = slices.Compact(expected)
+
+	if !slices.Equal(tree.RangeQuery(expected[0], expected[len(expected)-1]), expected) {
+		t.Errorf("Tree keys do not match sorted input")
+	}
+	checkTreeInvariants(t, tree)
+}
+
+// TestCodecRoundTrip tests that the built-in codecs decode what they encode
+func TestCodecRoundTrip(t *testing.T) {
+	for _, v := range []uint64{0, 1, 1 << 63, ^uint64(0)} {
+		got, err := Uint64Codec{}.DecodeKey(Uint64Codec{}.AppendKey(nil, v))
+		if err != nil || got != v {
+			t.Errorf("Uint64Codec round trip of %d gave %d, %v", v, got, err)
+		}
+	}
+	for _, v := range []int{0, -1, 42, -1 << 40} {
+		got, err := IntCodec{}.DecodeKey(IntCodec{}.AppendKey(nil, v))
+		if err != nil || got != v {
+			t.Errorf("IntCodec round trip of %d gave %d, %v", v, got, err)
+		}
+	}
+	for _, v := range []string{"", "a", "hello, world"} {
+		got, err := StringCodec{}.DecodeKey(StringCodec{}.AppendKey(nil, v))
+		if err != nil || got != v {
+			t.Errorf("StringCodec round trip of %q gave %q, %v", v, got, err)
+		}
+	}
+
+	if _, err := (Uint64Codec{}).DecodeKey([]byte{1, 2}); err == nil {
+		t.Errorf("Expected an error for a short uint64 key")
+	}
+}
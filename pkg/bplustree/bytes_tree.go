@@ -0,0 +1,36 @@
+package bplustree
+
+import "bytes"
+
+// BytesTree is a B+ tree of []byte keys ordered by bytes.Compare.
+// Insert copies each key it stores, so callers can reuse their buffers after
+// inserting. All other methods come from the embedded GenericBPlusTree; keys
+// they return are the tree's own copies and must not be modified.
+type BytesTree struct {
+	*GenericBPlusTree[[]byte]
+}
+
+// NewBytesTree creates a new B+ tree for []byte keys.
+// Keys are hashed for the bloom filter with FNV-1a, like strings in NewOrderedTree.
+//
+// Parameters:
+//   - branchingFactor: The maximum number of children per node. Must be at least 3.
+//
+// Returns a new empty B+ tree with a bloom filter enabled for faster lookups.
+func NewBytesTree(branchingFactor int) *BytesTree {
+	return &BytesTree{NewGenericBPlusTreeWithCompare(branchingFactor, bytes.Compare, bytesHash)}
+}
+
+// Insert adds a copy of key to the tree.
+// Returns true if the key was inserted, false if it already existed.
+// Time complexity: O(log n + s) where n is the number of keys in the tree and s is the length of the key.
+func (t *BytesTree) Insert(key []byte) bool {
+	return t.GenericBPlusTree.Insert(bytes.Clone(key))
+}
+
+// bytesHash hashes a byte slice the same way in every process.
+// Nil and empty slices have the same hash, as bytes.Compare finds them equal.
+// Time complexity: O(s) where s is the length of the key.
+func bytesHash(key []byte) uint64 {
+	return fnv1a(key)
+}
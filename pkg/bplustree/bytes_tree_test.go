@@ -0,0 +1,89 @@
+package bplustree
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// TestBytesTree tests []byte keys, including that inserted keys are copied
+func TestBytesTree(t *testing.T) {
+	tree := NewBytesTree(4)
+
+	// Reuse one buffer for every insert
+	buf := make([]byte, 0, 16)
+	for i := 0; i < 100; i++ {
+		buf = fmt.Appendf(buf[:0], "key-%03d", i)
+		if !tree.Insert(buf) {
+			t.Errorf("Failed to insert %s", buf)
+		}
+	}
+	if tree.Insert([]byte("key-042")) {
+		t.Errorf("Expected duplicate insert to fail")
+	}
+	if tree.Size() != 100 {
+		t.Errorf("Expected size 100, got %d", tree.Size())
+	}
+	if !tree.Contains([]byte("key-000")) || !tree.Contains([]byte("key-099")) || tree.Contains([]byte("key-100")) {
+		t.Errorf("Unexpected Contains result")
+	}
+
+	got := tree.RangeQuery([]byte("key-010"), []byte("key-012"))
+	want := [][]byte{[]byte("key-010"), []byte("key-011"), []byte("key-012")}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %q, got %q", want, got)
+	}
+	for i := range want {
+		if !bytes.Equal(got[i], want[i]) {
+			t.Errorf("Expected %q, got %q", want, got)
+		}
+	}
+
+	if !tree.Delete([]byte("key-050")) || tree.Contains([]byte("key-050")) {
+		t.Errorf("Expected key-050 to be deleted")
+	}
+	if n := tree.ForceDeleteKeys([][]byte{[]byte("key-001"), []byte("key-001"), []byte("absent")}); n != 1 {
+		t.Errorf("Expected ForceDeleteKeys to delete 1 key, got %d", n)
+	}
+
+	// Nil and empty keys are the same key
+	tree.Insert(nil)
+	if !tree.Contains([]byte{}) || tree.Insert([]byte{}) {
+		t.Errorf("Expected nil and empty keys to be equal")
+	}
+}
+
+// TestGenericSetNonComparable tests a set of struct keys holding a slice
+func TestGenericSetNonComparable(t *testing.T) {
+	type path struct {
+		parts []string
+	}
+	compare := func(a, b path) int {
+		for i := 0; i < len(a.parts) && i < len(b.parts); i++ {
+			if a.parts[i] != b.parts[i] {
+				if a.parts[i] < b.parts[i] {
+					return -1
+				}
+				return 1
+			}
+		}
+		return len(a.parts) - len(b.parts)
+	}
+	set := NewGenericSet(
+		4,
+		func(a, b path) bool { return compare(a, b) < 0 },
+		func(a, b path) bool { return compare(a, b) == 0 },
+		func(p path) uint64 { return uint64(len(p.parts)) },
+	)
+
+	set.Add(path{[]string{"usr", "bin"}})
+	set.Add(path{[]string{"usr"}})
+	set.Add(path{[]string{"etc"}})
+	if set.Add(path{[]string{"usr", "bin"}}) {
+		t.Errorf("Expected duplicate add to fail")
+	}
+	sorted := set.SortedSlice()
+	if len(sorted) != 3 || sorted[0].parts[0] != "etc" || len(sorted[2].parts) != 2 {
+		t.Errorf("Unexpected order %v", sorted)
+	}
+}
@@ -0,0 +1,923 @@
+package bplustree
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+)
+
+var (
+	// ErrTreeExists is returned by CreateTree when the name is already taken.
+	ErrTreeExists = errors.New("bplustree: tree already exists")
+
+	// ErrTreeNotFound is returned when a named tree is not in the catalog.
+	ErrTreeNotFound = errors.New("bplustree: tree not found")
+
+	// ErrCorrupt is returned when a catalog file or delta fails validation on load.
+	ErrCorrupt = errors.New("bplustree: corrupt data")
+)
+
+// catalogMagic identifies a catalog file.
+var catalogMagic = [4]byte{'B', 'P', 'T', 'C'}
+
+// encryptedCatalogMagic identifies a catalog file with encrypted pages.
+var encryptedCatalogMagic = [4]byte{'B', 'P', 'T', 'E'}
+
+// catalogVersion is the version of the catalog file format.
+const catalogVersion = 1
+
+// Kinds of bloom filter stored in a catalog filter section.
+const (
+	catalogFilterBloom    = 1 // *BloomFilter
+	catalogFilterCounting = 2 // *CountingBloomFilter
+)
+
+// catalogFilterProbes is the number of keys whose hashes are stored with a
+// filter, to tell whether the codec a tree is opened with hashes like the one
+// the filter was built with.
+const catalogFilterProbes = 8
+
+// Catalog stores many named trees in a single file.
+//
+// Trees are created or opened by name and then used like any other
+// GenericBPlusTree. The file is divided into pages of catalogPageSize bytes
+// that all trees share: one allocator hands out the pages, and pages freed by
+// a commit are reused by later ones. Commit writes only the pages that
+// changed, never over a page the previous commit refers to, and then switches
+// to the new state of every tree at once by writing a meta page:
+//
+//	pages 0 and 1: meta pages, written by alternate commits
+//	meta:      magic "BPTC" | version byte | uvarint commit number | uvarint page count | uvarint directory page | uvarint free list page
+//	directory: chain listing name | uvarint branching factor | uvarint index page for every tree
+//	free list: chain listing the pages no tree refers to
+//	index:     chain listing the leaf pages and then the filter pages of one tree
+//	leaf:      2-byte key count | keys, each as a uvarint length followed by its bytes
+//	overflow:  chain holding a long key after its inline prefix
+//	filter:    chain holding kind byte | uvarint probe count | 8-byte probe hashes | MarshalBinary encoding of the tree's bloom filter
+//
+// Every page ends with a CRC-32 that also covers its page number. A meta page
+// holds its contents in its first catalogMetaSize bytes, which storage writes at
+// once, so a commit interrupted before its meta page is written leaves the
+// previous commit in place, and a meta page that fails to verify is reported as
+// ErrCorrupt rather than skipped. Pages freed by a commit are listed in the
+// free list. The directory and the free list are rewritten
+// only when they change, so a commit without changes writes just a meta page.
+// Vacuum moves pages in use toward the front of the file so that it can be truncated.
+//
+// Leaf pages hold the keys of a tree in ascending order, packed by the bytes
+// the keys take up, so trees are rebuilt with a bulk load. Keys longer than
+// catalogOverflowThreshold keep only a prefix in their leaf page and spill the
+// rest into overflow pages, so a leaf page holds at least a few keys. After a
+// change, only the leaf pages whose key ranges hold changed leaves are rewritten (see Checkpoint); pages
+// left less than half full are merged with the next one. A valid BloomFilter
+// or CountingBloomFilter is stored in filter pages and trusted on load instead
+// of being recomputed; only filter pages whose contents changed are rewritten.
+// The filter is stored with the hashes of the first key of the first
+// catalogFilterProbes leaf pages, and recomputed on load if the codec hashes
+// these keys differently.
+//
+// An encrypted catalog (see OpenEncryptedCatalog) uses the magic "BPTE" and
+// replaces the checksum of every page with a nonce and the AES-GCM sealed page body.
+type Catalog struct {
+	path  string
+	trees map[string]*catalogEntry
+	aead  cipher.AEAD // Seals each page when the catalog is encrypted, nil otherwise
+
+	exists        bool          // Whether the file has been written; otherwise Commit creates it
+	txid          uint64        // Number of the latest commit
+	alloc         pageAllocator // Pages of the file and which of them are free
+	directory     []uint64      // Directory chain of the latest commit
+	directoryData []byte        // Contents of the directory chain
+	freelist      []uint64      // Free list chain of the latest commit
+	freelistData  []byte        // Contents of the free list chain
+	released      []uint64      // Pages of dropped trees, free after the next commit
+}
+
+// catalogEntry is one named tree in a catalog.
+type catalogEntry struct {
+	branchingFactor int
+	pages           treePages // Pages of the tree as of the latest commit
+
+	// storedFilter and storedLeaves hold the encoded filter and the encoded
+	// keys of each leaf page read from the file until the tree is opened.
+	storedFilter []byte
+	storedLeaves [][][]byte
+
+	// tree is the *GenericBPlusTree[K] once the tree has been created or opened.
+	tree any
+
+	// commitTree writes the changes of tree, see pagedTree.commit. It is set together with tree.
+	commitTree func(w *pageWriter, old treePages) (treePages, func(), error)
+}
+
+// OpenCatalog opens the catalog file at path.
+// A missing file is treated as an empty catalog; it is created on the first Commit.
+func OpenCatalog(path string) (*Catalog, error) {
+	return openCatalog(path, nil)
+}
+
+// OpenEncryptedCatalog opens the encrypted catalog file at path.
+// A missing file is treated as an empty catalog; it is created on the first Commit.
+//
+// Every page but the clear magic and version of the meta pages is encrypted
+// with AES-GCM under key, which must be 16, 24 or 32 bytes long, using a fresh
+// random nonce each time the page is written. Each page's number is
+// authenticated as well, so a modified, moved, truncated or swapped page fails
+// to load with ErrCorrupt. A wrong key is reported the same way.
+func OpenEncryptedCatalog(path string, key []byte) (*Catalog, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("bplustree: catalog key: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("bplustree: catalog key: %w", err)
+	}
+	return openCatalog(path, aead)
+}
+
+// openCatalog opens the catalog at path, sealing pages with aead if it is not nil.
+func openCatalog(path string, aead cipher.AEAD) (*Catalog, error) {
+	c := &Catalog{
+		path:  path,
+		trees: make(map[string]*catalogEntry),
+		aead:  aead,
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("bplustree: reading catalog: %w", err)
+	}
+
+	if err := c.decode(data); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// CreateTree creates an empty tree named name in the catalog.
+// The tree is not written to disk until the next Commit.
+func CreateTree[K any](c *Catalog, name string, codec Codec[K], branchingFactor int) (*GenericBPlusTree[K], error) {
+	if _, ok := c.trees[name]; ok {
+		return nil, fmt.Errorf("%w: %q", ErrTreeExists, name)
+	}
+
+	tree := NewGenericBPlusTree(branchingFactor, codec.Less, codec.Equal, codec.Hash)
+	entry := &catalogEntry{branchingFactor: tree.branchingFactor}
+	attachTree(entry, &pagedTree[K]{tree: tree, codec: codec})
+	c.trees[name] = entry
+	return tree, nil
+}
+
+// OpenTree returns the tree named name.
+// The first call loads the tree from the catalog file using codec; later calls
+// return the same tree. codec must match the one the tree was created with.
+func OpenTree[K any](c *Catalog, name string, codec Codec[K]) (*GenericBPlusTree[K], error) {
+	entry, ok := c.trees[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrTreeNotFound, name)
+	}
+
+	if entry.tree != nil {
+		tree, ok := entry.tree.(*GenericBPlusTree[K])
+		if !ok {
+			return nil, fmt.Errorf("bplustree: tree %q was opened with a different key type", name)
+		}
+		return tree, nil
+	}
+
+	tree := NewGenericBPlusTree(entry.branchingFactor, codec.Less, codec.Equal, codec.Hash)
+	if entry.storedFilter != nil {
+		filter, probes, err := decodeFilterSection(entry.storedFilter)
+		if err != nil {
+			return nil, fmt.Errorf("%w: tree %q: %v", ErrCorrupt, name, err)
+		}
+		stored, err := storedFilterProbes(codec, entry.storedLeaves)
+		if err != nil {
+			return nil, fmt.Errorf("%w: tree %q: %v", ErrCorrupt, name, err)
+		}
+
+		// A filter built with another hash would rule out keys of the tree
+		if slices.Equal(probes, stored) {
+			tree.bloomFilter = filter
+		}
+	}
+	firsts, err := loadLeafPages(tree, codec, entry.storedLeaves)
+	if err != nil {
+		return nil, fmt.Errorf("%w: tree %q: %v", ErrCorrupt, name, err)
+	}
+
+	attachTree(entry, &pagedTree[K]{tree: tree, codec: codec, firsts: firsts, since: tree.Checkpoint()})
+	entry.storedFilter, entry.storedLeaves = nil, nil
+	return tree, nil
+}
+
+// DropTree removes the tree named name from the catalog.
+// The tree is removed from disk on the next Commit, which frees its pages.
+func (c *Catalog) DropTree(name string) error {
+	entry, ok := c.trees[name]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrTreeNotFound, name)
+	}
+	c.released = append(c.released, entry.pages.all()...)
+	delete(c.trees, name)
+	return nil
+}
+
+// ListTrees returns the names of all trees in the catalog in sorted order.
+func (c *Catalog) ListTrees() []string {
+	names := make([]string, 0, len(c.trees))
+	for name := range c.trees {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Commit atomically writes the changes of all trees in the catalog to its file.
+//
+// Changed pages are written to free pages and synced before the meta page
+// that refers to them, so the file holds either the previous or the new state
+// of every tree. Commit starts a new checkpoint of every opened tree (see
+// Checkpoint) to track the changes for the next Commit.
+//
+// The first Commit of a new catalog writes a new file instead and renames it
+// over the catalog file.
+func (c *Catalog) Commit() error {
+	var err error
+	if c.exists {
+		err = c.commitInPlace()
+	} else {
+		err = c.commitNewFile()
+	}
+	if err != nil {
+		return fmt.Errorf("bplustree: committing catalog: %w", err)
+	}
+	return nil
+}
+
+// commitInPlace writes the changes of a commit into the catalog file.
+func (c *Catalog) commitInPlace() error {
+	f, err := os.OpenFile(c.path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	apply, err := c.commitTo(f, c.alloc)
+	closeErr := f.Close()
+	if err != nil {
+		return err
+	}
+
+	// The meta page is synced, so the commit took place even if closing failed
+	apply()
+	return closeErr
+}
+
+// commitNewFile writes all trees to a temporary file in the same directory and
+// renames it over the catalog file.
+func (c *Catalog) commitNewFile() error {
+	dir := filepath.Dir(c.path)
+	f, err := os.CreateTemp(dir, filepath.Base(c.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+
+	apply, err := c.commitTo(f, pageAllocator{count: catalogMetaPages})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, c.path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	// Make the rename durable. Not every platform supports syncing a directory.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	apply()
+	c.exists = true
+	return nil
+}
+
+// commitTo writes the changes of a commit to f, allocating pages from a copy of alloc.
+// Once f is synced, it returns a function that makes the commit the catalog's
+// latest, to be called when the commit is known to have taken place.
+func (c *Catalog) commitTo(f *os.File, alloc pageAllocator) (func(), error) {
+	w := &pageWriter{c: c, file: f, alloc: alloc.clone()}
+	w.release(c.released...)
+
+	names := c.ListTrees()
+	pages := make([]treePages, len(names))
+	var finish []func()
+	for i, name := range names {
+		entry := c.trees[name]
+		if entry.commitTree == nil {
+			// A tree that has not been opened keeps its pages
+			pages[i] = entry.pages
+			continue
+		}
+		var done func()
+		var err error
+		if pages[i], done, err = entry.commitTree(w, entry.pages); err != nil {
+			return nil, fmt.Errorf("tree %q: %w", name, err)
+		}
+		finish = append(finish, done)
+	}
+
+	apply, err := c.finishCommit(w, f, names, pages)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		apply()
+		for _, done := range finish {
+			done()
+		}
+	}, nil
+}
+
+// finishCommit completes a commit in which the trees named names have the
+// given pages: it writes the directory and the free list where they changed,
+// syncs f and writes the meta page. Once f is synced again, it returns a
+// function that makes the commit the catalog's latest.
+func (c *Catalog) finishCommit(w *pageWriter, f *os.File, names []string, pages []treePages) (func(), error) {
+	if w.end > 0 {
+		w.alloc.count = w.end
+		w.alloc.free = slices.DeleteFunc(w.alloc.free, func(id uint64) bool { return id >= w.end })
+	}
+
+	directory := binary.AppendUvarint(nil, uint64(len(names)))
+	for i, name := range names {
+		directory = binary.AppendUvarint(directory, uint64(len(name)))
+		directory = append(directory, name...)
+		directory = binary.AppendUvarint(directory, uint64(c.trees[name].branchingFactor))
+		directory = binary.AppendUvarint(directory, pages[i].index[0])
+	}
+	directoryPages := c.directory
+	if directoryPages == nil || !bytes.Equal(directory, c.directoryData) || w.relocating(directoryPages) {
+		w.release(c.directory...)
+		var err error
+		if directoryPages, err = w.writeChain(catalogPageDirectory, directory); err != nil {
+			return nil, err
+		}
+	}
+
+	// The free list chain takes its pages from the free pages it lists
+	free := w.freePages()
+	freelistPages, freelist := c.freelist, appendPageList(nil, free)
+	if c.freelistData == nil || !bytes.Equal(freelist, c.freelistData) || w.relocating(freelistPages) {
+		w.release(c.freelist...)
+		freelistPages = nil
+		if len(w.freePages()) > 0 {
+			freelistPages = w.allocateChain(len(appendPageList(nil, w.freePages())))
+		}
+		free = w.freePages()
+		freelist = appendPageList(nil, free)
+		if err := w.writeChainPages(catalogPageFreelist, freelistPages, freelist); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := f.Sync(); err != nil {
+		return nil, err
+	}
+	meta := catalogMeta{txid: c.txid + 1, pageCount: w.alloc.count, directory: directoryPages[0]}
+	if len(freelistPages) > 0 {
+		meta.freelist = freelistPages[0]
+	}
+	if err := w.writeMeta(meta); err != nil {
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		for i, name := range names {
+			c.trees[name].pages = pages[i]
+		}
+		c.txid, c.alloc = meta.txid, pageAllocator{count: w.alloc.count, free: free}
+		c.directory, c.directoryData = directoryPages, directory
+		c.freelist, c.freelistData = freelistPages, freelist
+		c.released = nil
+	}, nil
+}
+
+// Vacuum commits the catalog, then moves the pages in use toward the front of
+// the file and truncates it after the last of them.
+//
+// Every step is a commit of its own, so an interrupted Vacuum leaves a valid
+// file. Trees stay usable throughout, since they are held in memory.
+func (c *Catalog) Vacuum() error {
+	if err := c.Commit(); err != nil {
+		return err
+	}
+	if err := c.vacuum(); err != nil {
+		return fmt.Errorf("bplustree: vacuuming catalog: %w", err)
+	}
+	return nil
+}
+
+// vacuum moves pages and truncates the file of a committed catalog, see Vacuum.
+func (c *Catalog) vacuum() error {
+	f, err := os.OpenFile(c.path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Move pages as long as that lowers the last page in use
+	for last := c.lastPage(); ; {
+		moved, err := c.relocate(f)
+		if err != nil {
+			return err
+		}
+		if !moved || c.lastPage() >= last {
+			break
+		}
+		last = c.lastPage()
+	}
+
+	// Commit twice, so that neither meta page refers to a page after the cut
+	for _, end := range []uint64{c.lastPage() + 1, 0} {
+		w := &pageWriter{c: c, file: f, alloc: c.alloc.clone(), end: end}
+		apply, err := c.finishCommit(w, f, c.ListTrees(), c.treePages())
+		if err != nil {
+			return err
+		}
+		apply()
+	}
+	return f.Truncate(int64(c.alloc.count) * catalogPageSize)
+}
+
+// relocate moves the pages after the pages in use, were they all at the front
+// of the file, into free pages before them in one commit. Filter pages and
+// leaf pages without long keys are copied; leaf pages with long keys are
+// written anew together with their overflow pages. Chains that list moved
+// pages or lie after the pages in use are rewritten.
+// It reports whether there was anything to move.
+func (c *Catalog) relocate(f *os.File) (bool, error) {
+	w := &pageWriter{c: c, file: f, alloc: c.alloc.clone()}
+	w.limit = catalogMetaPages + uint64(len(c.directory)+len(c.freelist))
+	for _, entry := range c.trees {
+		w.limit += uint64(len(entry.pages.all()))
+	}
+	if n := len(w.alloc.free); n == 0 || w.alloc.free[n-1] >= w.limit {
+		return false, nil
+	}
+
+	// Pages that no other page refers to move to the lowest free pages
+	var data []uint64
+	for _, entry := range c.trees {
+		for i, id := range entry.pages.leaves {
+			if len(entry.pages.overflow[i]) == 0 {
+				data = append(data, id)
+			}
+		}
+		data = append(data, entry.pages.filter...)
+	}
+	data = slices.DeleteFunc(data, func(id uint64) bool { return id < w.limit })
+	slices.SortFunc(data, func(x, y uint64) int { return cmp.Compare(y, x) })
+	moves := make(map[uint64]uint64)
+	for _, id := range data {
+		if n := len(w.alloc.free); n == 0 || w.alloc.free[n-1] >= w.limit {
+			break
+		}
+		moves[id] = w.alloc.allocate()
+	}
+
+	names := c.ListTrees()
+	pages := c.treePages()
+	relocating := len(moves) > 0 || w.relocating(c.directory) || w.relocating(c.freelist)
+	for i := range pages {
+		relocating = relocating || w.relocating(pages[i].index)
+		for j, id := range pages[i].leaves {
+			relocating = relocating || (len(pages[i].overflow[j]) > 0 && w.relocating(append([]uint64{id}, pages[i].overflow[j]...)))
+		}
+	}
+	if !relocating {
+		return false, nil
+	}
+
+	page := make([]byte, catalogPageSize)
+	for from, to := range moves {
+		if _, err := f.ReadAt(page, int64(from)*catalogPageSize); err != nil {
+			return false, err
+		}
+		body, err := c.openPage(from, page, 0)
+		if err != nil {
+			return false, err
+		}
+		if err := w.writePage(to, body); err != nil {
+			return false, err
+		}
+		w.release(from)
+	}
+
+	r := &pageReader{c: c, file: f, count: c.alloc.count, seen: make(map[uint64]bool)}
+	for i := range pages {
+		p := &pages[i]
+		leaves, leavesMoved := movePages(p.leaves, moves)
+		filter, filterMoved := movePages(p.filter, moves)
+		overflow := slices.Clone(p.overflow)
+		for j, id := range p.leaves {
+			if len(p.overflow[j]) == 0 || !w.relocating(append([]uint64{id}, p.overflow[j]...)) {
+				continue
+			}
+			keys, _, err := r.leaf(id)
+			if err != nil {
+				return false, err
+			}
+			w.release(id)
+			w.release(p.overflow[j]...)
+			if leaves[j], overflow[j], err = w.writeLeaf(keys); err != nil {
+				return false, err
+			}
+			leavesMoved = true
+		}
+		if !leavesMoved && !filterMoved && !w.relocating(p.index) {
+			continue
+		}
+
+		p.leaves, p.overflow, p.filter = leaves, overflow, filter
+		w.release(p.index...)
+		var err error
+		if p.index, err = w.writeChain(catalogPageIndex, appendIndexData(nil, p)); err != nil {
+			return false, err
+		}
+	}
+
+	apply, err := c.finishCommit(w, f, names, pages)
+	if err != nil {
+		return false, err
+	}
+	apply()
+	return true, nil
+}
+
+// movePages returns pages with the moved ones replaced, and whether any moved.
+func movePages(pages []uint64, moves map[uint64]uint64) ([]uint64, bool) {
+	moved := false
+	result := make([]uint64, len(pages))
+	for i, id := range pages {
+		result[i] = id
+		if to, ok := moves[id]; ok {
+			result[i], moved = to, true
+		}
+	}
+	return result, moved
+}
+
+// treePages returns the pages of every tree as of the latest commit, in name order.
+func (c *Catalog) treePages() []treePages {
+	names := c.ListTrees()
+	pages := make([]treePages, len(names))
+	for i, name := range names {
+		pages[i] = c.trees[name].pages
+	}
+	return pages
+}
+
+// lastPage returns the highest page that the trees or the directory use.
+func (c *Catalog) lastPage() uint64 {
+	last := uint64(catalogMetaPages - 1)
+	for _, entry := range c.trees {
+		for _, id := range entry.pages.all() {
+			last = max(last, id)
+		}
+	}
+	for _, id := range c.directory {
+		last = max(last, id)
+	}
+	return last
+}
+
+// attachTree records the tree of paged as the live tree of the entry.
+func attachTree[K any](e *catalogEntry, paged *pagedTree[K]) {
+	e.tree = paged.tree
+	e.commitTree = paged.commit
+}
+
+// appendFilterData appends the kind byte, the probe hashes and the encoding of filter.
+// Only valid filters of the kinds the catalog knows are stored; for others
+// nothing is appended.
+func appendFilterData(dst []byte, filter BloomFilterInterface, probes []uint64) []byte {
+	if !filter.IsValid() {
+		return dst
+	}
+	var kind byte
+	var data []byte
+	switch f := filter.(type) {
+	case *BloomFilter:
+		kind = catalogFilterBloom
+		data, _ = f.MarshalBinary()
+	case *CountingBloomFilter:
+		kind = catalogFilterCounting
+		data, _ = f.MarshalBinary()
+	default:
+		return dst
+	}
+
+	dst = append(dst, kind)
+	dst = binary.AppendUvarint(dst, uint64(len(probes)))
+	for _, hash := range probes {
+		dst = binary.BigEndian.AppendUint64(dst, hash)
+	}
+	return append(dst, data...)
+}
+
+// decodeFilterSection decodes the contents of a non-empty filter section and
+// returns the filter and the probe hashes stored with it.
+func decodeFilterSection(section []byte) (BloomFilterInterface, []uint64, error) {
+	kind := section[0]
+	r := bytes.NewReader(section[1:])
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n > catalogFilterProbes {
+		return nil, nil, fmt.Errorf("%d filter probes", n)
+	}
+	probes := make([]uint64, n)
+	if err := binary.Read(r, binary.BigEndian, probes); err != nil {
+		return nil, nil, err
+	}
+	data := section[len(section)-r.Len():]
+
+	switch kind {
+	case catalogFilterBloom:
+		filter := &BloomFilter{}
+		return filter, probes, filter.UnmarshalBinary(data)
+	case catalogFilterCounting:
+		filter := &CountingBloomFilter{}
+		return filter, probes, filter.UnmarshalBinary(data)
+	}
+	return nil, nil, fmt.Errorf("unknown filter kind %d", kind)
+}
+
+// filterProbes returns the hashes stored with the filter of a tree whose leaf
+// pages start with firsts: those of the first key of the first catalogFilterProbes pages.
+func filterProbes[K any](codec Codec[K], firsts []K) []uint64 {
+	probes := make([]uint64, min(len(firsts), catalogFilterProbes))
+	for i := range probes {
+		probes[i] = codec.Hash(firsts[i])
+	}
+	return probes
+}
+
+// storedFilterProbes returns filterProbes for the encoded keys of leaf pages.
+func storedFilterProbes[K any](codec Codec[K], pages [][][]byte) ([]uint64, error) {
+	firsts := make([]K, min(len(pages), catalogFilterProbes))
+	for i := range firsts {
+		var err error
+		if firsts[i], err = codec.DecodeKey(pages[i][0]); err != nil {
+			return nil, err
+		}
+	}
+	return filterProbes(codec, firsts), nil
+}
+
+// loadLeafPages bulk loads the encoded keys of leaf pages into the empty tree
+// and returns the first key of each page.
+// Time complexity: O(n) where n is the number of keys in the pages.
+func loadLeafPages[K any](tree *GenericBPlusTree[K], codec Codec[K], pages [][][]byte) ([]K, error) {
+	loader := newBulkLoader(tree)
+	firsts := make([]K, 0, len(pages))
+	var last K
+	for _, keys := range pages {
+		for i, encoded := range keys {
+			key, err := codec.DecodeKey(encoded)
+			if err != nil {
+				return nil, err
+			}
+			if (i > 0 || len(firsts) > 0) && !codec.Less(last, key) {
+				return nil, errors.New("keys out of order")
+			}
+			if i == 0 {
+				firsts = append(firsts, key)
+			}
+			loader.add(key)
+			last = key
+		}
+	}
+
+	loader.finish()
+	return firsts, nil
+}
+
+// decode parses a catalog file. Trees are not decoded until they are opened.
+func (c *Catalog) decode(data []byte) error {
+	if len(data) < len(catalogMagic)+1 {
+		return fmt.Errorf("%w: bad magic", ErrCorrupt)
+	}
+	if err := c.checkMagic([4]byte(data)); err != nil {
+		return err
+	}
+
+	if data[len(catalogMagic)] != catalogVersion {
+		return fmt.Errorf("%w: unsupported version", ErrCorrupt)
+	}
+	if err := c.decodePages(data); err != nil {
+		return fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	c.exists = true
+	return nil
+}
+
+// decodePages parses the pages of a catalog file, reading and verifying every
+// page the latest commit refers to.
+func (c *Catalog) decodePages(data []byte) error {
+	meta, err := c.readMeta(data)
+	if err != nil {
+		return err
+	}
+	r := &pageReader{c: c, file: bytes.NewReader(data), count: meta.pageCount, seen: make(map[uint64]bool)}
+
+	directory, directoryPages, err := r.chain(catalogPageDirectory, meta.directory)
+	if err != nil {
+		return fmt.Errorf("directory: %v", err)
+	}
+	br := bytes.NewReader(directory)
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return fmt.Errorf("directory: %v", err)
+	}
+	for i := uint64(0); i < count; i++ {
+		name, err := readLengthPrefixed(br)
+		if err != nil {
+			return fmt.Errorf("directory: %v", err)
+		}
+		branchingFactor, err := binary.ReadUvarint(br)
+		if err != nil {
+			return fmt.Errorf("directory: %v", err)
+		}
+		index, err := binary.ReadUvarint(br)
+		if err != nil {
+			return fmt.Errorf("directory: %v", err)
+		}
+		if err := checkBranchingFactor(branchingFactor); err != nil {
+			return fmt.Errorf("tree %q: %v", name, err)
+		}
+		if _, ok := c.trees[string(name)]; ok {
+			return fmt.Errorf("duplicate tree %q", name)
+		}
+
+		entry := &catalogEntry{branchingFactor: int(branchingFactor)}
+		if err := r.readTree(entry, index); err != nil {
+			return fmt.Errorf("tree %q: %v", name, err)
+		}
+		c.trees[string(name)] = entry
+	}
+	if br.Len() != 0 {
+		return errors.New("directory: trailing bytes")
+	}
+
+	free, err := r.readFreelist(meta)
+	if err != nil {
+		return fmt.Errorf("free list: %v", err)
+	}
+	c.txid, c.alloc = meta.txid, pageAllocator{count: meta.pageCount}
+	c.alloc.release(free)
+	c.directory, c.directoryData = directoryPages, directory
+	return nil
+}
+
+// readFreelist returns the free pages of the commit of meta, after all pages
+// in use have been read. It reads the free list chain into the catalog.
+func (r *pageReader) readFreelist(meta catalogMeta) ([]uint64, error) {
+	if meta.freelist == 0 {
+		return nil, nil
+	}
+
+	data, pages, err := r.chain(catalogPageFreelist, meta.freelist)
+	if err != nil {
+		return nil, err
+	}
+	br := bytes.NewReader(data)
+	free, err := readPageList(br)
+	if err != nil {
+		return nil, err
+	}
+	if br.Len() != 0 {
+		return nil, errors.New("trailing bytes")
+	}
+	for _, id := range free {
+		if id < catalogMetaPages || id >= r.count || r.seen[id] {
+			return nil, fmt.Errorf("page %d cannot be free", id)
+		}
+		r.seen[id] = true
+	}
+	r.c.freelist, r.c.freelistData = pages, data
+	return free, nil
+}
+
+// readTree reads the index chain of a tree starting at page index and the
+// pages it lists into entry.
+func (r *pageReader) readTree(entry *catalogEntry, index uint64) error {
+	data, indexPages, err := r.chain(catalogPageIndex, index)
+	if err != nil {
+		return err
+	}
+	br := bytes.NewReader(data)
+	leaves, err := readPageList(br)
+	if err != nil {
+		return err
+	}
+	filter, err := readPageList(br)
+	if err != nil {
+		return err
+	}
+	if br.Len() != 0 {
+		return errors.New("index: trailing bytes")
+	}
+	entry.pages = treePages{index: indexPages, leaves: leaves, filter: filter}
+
+	for _, id := range leaves {
+		keys, overflow, err := r.leaf(id)
+		if err != nil {
+			return err
+		}
+		entry.storedLeaves = append(entry.storedLeaves, keys)
+		entry.pages.overflow = append(entry.pages.overflow, overflow)
+	}
+
+	for _, id := range filter {
+		body, err := r.page(id, catalogPageFilter)
+		if err != nil {
+			return err
+		}
+		part, err := readLengthPrefixed(bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("page %d: %v", id, err)
+		}
+		entry.storedFilter = append(entry.storedFilter, part...)
+		entry.pages.filterSums = append(entry.pages.filterSums, sha256.Sum256(part))
+	}
+	return nil
+}
+
+// checkBranchingFactor rejects a stored branching factor that no tree can
+// have: the constructors raise smaller ones to 3.
+func checkBranchingFactor(branchingFactor uint64) error {
+	if branchingFactor < 3 || branchingFactor > math.MaxInt32 {
+		return fmt.Errorf("invalid branching factor %d", branchingFactor)
+	}
+	return nil
+}
+
+// magic returns the magic bytes for this catalog's file format.
+func (c *Catalog) magic() []byte {
+	if c.aead != nil {
+		return encryptedCatalogMagic[:]
+	}
+	return catalogMagic[:]
+}
+
+// checkMagic verifies that magic matches the catalog's file format.
+func (c *Catalog) checkMagic(magic [4]byte) error {
+	switch {
+	case magic == catalogMagic && c.aead != nil:
+		return fmt.Errorf("%w: catalog is not encrypted", ErrCorrupt)
+	case magic == encryptedCatalogMagic && c.aead == nil:
+		return fmt.Errorf("%w: catalog is encrypted", ErrCorrupt)
+	case magic != catalogMagic && magic != encryptedCatalogMagic:
+		return fmt.Errorf("%w: bad magic", ErrCorrupt)
+	}
+	return nil
+}
+
+// readLengthPrefixed reads a uvarint length followed by that many bytes.
+func readLengthPrefixed(r *bytes.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
@@ -0,0 +1,850 @@
+package bplustree
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math/bits"
+	"slices"
+)
+
+// catalogPageSize is the size of every page of a catalog file.
+const catalogPageSize = 4096
+
+// catalogMetaPages is the number of meta pages at the start of a catalog file.
+// Commits write them in turn, so the one a commit does not write still
+// describes the previous commit if the commit is interrupted.
+const catalogMetaPages = 2
+
+// catalogMetaSize is the number of bytes at the start of a meta page that hold
+// its contents; the rest of the page is zero. Storage writes a sector of this
+// size at once, so an interrupted commit never leaves a meta page half written.
+const catalogMetaSize = 512
+
+// Kinds of catalog pages, stored in the first byte of every page body but those of meta pages.
+const (
+	catalogPageDirectory = 1 // Part of the chain listing the trees
+	catalogPageIndex     = 2 // Part of the chain listing the leaf and filter pages of one tree
+	catalogPageLeaf      = 3 // Consecutive keys of one tree
+	catalogPageFreelist  = 4 // Part of the chain listing the free pages
+	catalogPageOverflow  = 5 // Part of the chain holding the rest of a long key
+	catalogPageFilter    = 6 // Part of the encoded bloom filter of one tree
+)
+
+// leafPageHeader is the size of the kind byte and the 2-byte key count of a leaf page body.
+const leafPageHeader = 3
+
+// Keys longer than catalogOverflowThreshold bytes keep their first
+// catalogInlinePrefix bytes in their leaf page, followed by the first page of a
+// chain of overflow pages that holds the rest. A leaf page therefore holds at
+// least a few keys of any length.
+const (
+	catalogOverflowThreshold = 1024
+	catalogInlinePrefix      = 64
+)
+
+// catalogMeta is the contents of a meta page.
+type catalogMeta struct {
+	txid      uint64 // Number of the commit that wrote the meta page, counting from 1
+	pageCount uint64 // Number of pages of the file, free ones included
+	directory uint64 // First page of the directory chain
+	freelist  uint64 // First page of the free list chain, 0 if no page is free
+}
+
+// treePages lists the pages that store one tree.
+// The zero value stands for a tree that has not been written yet.
+type treePages struct {
+	index      []uint64   // Index chain, listing the leaf and filter pages
+	leaves     []uint64   // Leaf pages in key order
+	overflow   [][]uint64 // Overflow pages of the long keys in each leaf page
+	filter     []uint64   // Filter pages in order
+	filterSums [][32]byte // SHA-256 of the part of the filter in each filter page
+}
+
+// written reports whether the tree has been written to the file.
+func (p *treePages) written() bool {
+	return p.index != nil
+}
+
+// all returns every page of the tree.
+func (p *treePages) all() []uint64 {
+	return slices.Concat(slices.Concat(p.overflow...), p.index, p.leaves, p.filter)
+}
+
+// pageAllocator hands out the pages of a catalog file to all of its trees.
+type pageAllocator struct {
+	count uint64   // Number of pages of the file; the page appended next
+	free  []uint64 // Pages no commit refers to, in descending order so the lowest is taken first
+}
+
+// allocate returns a free page, or a new page at the end of the file if there is none.
+// Time complexity: O(1)
+func (a *pageAllocator) allocate() uint64 {
+	if n := len(a.free); n > 0 {
+		id := a.free[n-1]
+		a.free = a.free[:n-1]
+		return id
+	}
+	a.count++
+	return a.count - 1
+}
+
+// release makes pages available to allocate.
+// Time complexity: O(f log f) where f is the number of free pages.
+func (a *pageAllocator) release(pages []uint64) {
+	a.free = append(a.free, pages...)
+	slices.SortFunc(a.free, func(x, y uint64) int { return cmp.Compare(y, x) })
+}
+
+// clone returns a copy of the allocator that allocates independently of it.
+func (a *pageAllocator) clone() pageAllocator {
+	return pageAllocator{count: a.count, free: slices.Clone(a.free)}
+}
+
+// metaPrefix returns the bytes stored in the clear at the start of a meta page:
+// the magic followed by the file format version.
+func (c *Catalog) metaPrefix() []byte {
+	return append(slices.Clone(c.magic()), catalogVersion)
+}
+
+// sealedBodySize returns the number of bytes of body that a sealed run of size
+// bytes holds, after prefix bytes stored in the clear and the checksum or
+// encryption overhead.
+func (c *Catalog) sealedBodySize(size, prefix int) int {
+	if c.aead == nil {
+		return size - prefix - crc32.Size
+	}
+	return size - prefix - c.aead.NonceSize() - c.aead.Overhead()
+}
+
+// pageBodySize returns the number of bytes a page other than a meta page holds for its body.
+func (c *Catalog) pageBodySize() int {
+	return c.sealedBodySize(catalogPageSize, 0)
+}
+
+// pageAdditionalData returns the data a page's checksum or seal covers besides
+// its body: its prefix and its page number, so a page copied elsewhere fails to verify.
+func pageAdditionalData(id uint64, prefix []byte) []byte {
+	return binary.BigEndian.AppendUint64(slices.Clone(prefix), id)
+}
+
+// sealPage returns the size bytes of page id holding prefix and body, with body padded to fill them.
+// Plain pages end with a CRC-32 of the page number, prefix and body; encrypted
+// pages hold a nonce and the AES-GCM sealed body, authenticating page number and prefix.
+func (c *Catalog) sealPage(id uint64, size int, prefix, body []byte) ([]byte, error) {
+	padded := make([]byte, c.sealedBodySize(size, len(prefix)))
+	copy(padded, body)
+	ad := pageAdditionalData(id, prefix)
+
+	page := make([]byte, 0, size)
+	page = append(page, prefix...)
+	if c.aead == nil {
+		page = append(page, padded...)
+		sum := crc32.Update(crc32.ChecksumIEEE(ad), crc32.IEEETable, padded)
+		return binary.BigEndian.AppendUint32(page, sum), nil
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	page = append(page, nonce...)
+	return c.aead.Seal(page, nonce, padded, ad), nil
+}
+
+// openPage verifies page id, or the sealed bytes at its start, whose first prefix
+// bytes are stored in the clear, and returns its body.
+func (c *Catalog) openPage(id uint64, page []byte, prefix int) ([]byte, error) {
+	ad := pageAdditionalData(id, page[:prefix])
+	rest := page[prefix:]
+	if c.aead == nil {
+		body := rest[:len(rest)-crc32.Size]
+		if binary.BigEndian.Uint32(rest[len(body):]) != crc32.Update(crc32.ChecksumIEEE(ad), crc32.IEEETable, body) {
+			return nil, fmt.Errorf("page %d: checksum mismatch", id)
+		}
+		return body, nil
+	}
+
+	nonceSize := c.aead.NonceSize()
+	body, err := c.aead.Open(nil, rest[:nonceSize], rest[nonceSize:], ad)
+	if err != nil {
+		return nil, fmt.Errorf("page %d: authentication failed", id)
+	}
+	return body, nil
+}
+
+// metaSlot returns the meta page written by commit txid.
+func metaSlot(txid uint64) uint64 {
+	return (txid + 1) % catalogMetaPages
+}
+
+// readMeta returns the meta page of the latest commit of a catalog file.
+// Only a meta page that is all zeros is skipped, since it has not been written
+// yet; any other meta page must verify, so that a damaged or tampered latest
+// commit is reported rather than silently replaced by the previous one.
+func (c *Catalog) readMeta(data []byte) (catalogMeta, error) {
+	if len(data) < catalogMetaPages*catalogPageSize {
+		return catalogMeta{}, errors.New("file is shorter than its meta pages")
+	}
+
+	var latest catalogMeta
+	found := false
+	for slot := uint64(0); slot < catalogMetaPages; slot++ {
+		page := data[slot*catalogPageSize : (slot+1)*catalogPageSize]
+		if !slices.ContainsFunc(page, func(b byte) bool { return b != 0 }) {
+			continue
+		}
+		meta, err := c.readMetaPage(slot, page)
+		if err != nil {
+			return catalogMeta{}, fmt.Errorf("meta page %d: %v", slot, err)
+		}
+		if !found || meta.txid > latest.txid {
+			latest, found = meta, true
+		}
+	}
+
+	if !found {
+		return catalogMeta{}, errors.New("no meta page")
+	}
+	if latest.pageCount < catalogMetaPages || latest.pageCount > uint64(len(data))/catalogPageSize {
+		return catalogMeta{}, errors.New("file is shorter than its pages")
+	}
+	return latest, nil
+}
+
+// readMetaPage verifies and parses meta page slot.
+func (c *Catalog) readMetaPage(slot uint64, page []byte) (catalogMeta, error) {
+	prefix := c.metaPrefix()
+	if !bytes.Equal(page[:len(prefix)], prefix) {
+		return catalogMeta{}, errors.New("bad magic or version")
+	}
+	if slices.ContainsFunc(page[catalogMetaSize:], func(b byte) bool { return b != 0 }) {
+		return catalogMeta{}, errors.New("data after the meta page contents")
+	}
+	body, err := c.openPage(slot, page[:catalogMetaSize], len(prefix))
+	if err != nil {
+		return catalogMeta{}, err
+	}
+	meta, err := decodeMeta(body)
+	if err != nil {
+		return catalogMeta{}, err
+	}
+	if metaSlot(meta.txid) != slot {
+		return catalogMeta{}, fmt.Errorf("commit %d in the wrong meta page", meta.txid)
+	}
+	return meta, nil
+}
+
+// appendMeta appends the body of a meta page for meta.
+func appendMeta(dst []byte, meta catalogMeta) []byte {
+	dst = binary.AppendUvarint(dst, meta.txid)
+	dst = binary.AppendUvarint(dst, meta.pageCount)
+	dst = binary.AppendUvarint(dst, meta.directory)
+	return binary.AppendUvarint(dst, meta.freelist)
+}
+
+// decodeMeta parses the body of a meta page.
+func decodeMeta(body []byte) (catalogMeta, error) {
+	r := bytes.NewReader(body)
+	var meta catalogMeta
+	var err error
+	for _, field := range []*uint64{&meta.txid, &meta.pageCount, &meta.directory, &meta.freelist} {
+		if *field, err = binary.ReadUvarint(r); err != nil {
+			return catalogMeta{}, err
+		}
+	}
+	return meta, nil
+}
+
+// pageWriter writes the pages of one commit.
+// It allocates from a copy of the catalog's allocator, which replaces the
+// catalog's own once the commit is durable.
+type pageWriter struct {
+	c        *Catalog
+	file     io.WriterAt
+	alloc    pageAllocator
+	released []uint64 // Pages the commit stops referring to
+
+	// Set by Vacuum: chains with a page at or after limit, the number of
+	// pages the file would have if all pages in use were at its front, or at
+	// or after end, the page the file is cut at, are rewritten even if their
+	// contents did not change. 0 if unset.
+	limit, end uint64
+}
+
+// relocating reports whether a chain on pages has to be rewritten to move it
+// toward the front of the file, see limit and end.
+func (w *pageWriter) relocating(pages []uint64) bool {
+	return slices.ContainsFunc(pages, func(id uint64) bool {
+		return (w.limit > 0 && id >= w.limit) || (w.end > 0 && id >= w.end)
+	})
+}
+
+// freePages returns the pages that are free once the commit is durable, in
+// descending order, leaving out those the file is cut at.
+func (w *pageWriter) freePages() []uint64 {
+	free := slices.Concat(w.alloc.free, w.released)
+	if w.end > 0 {
+		free = slices.DeleteFunc(free, func(id uint64) bool { return id >= w.end })
+	}
+	slices.SortFunc(free, func(x, y uint64) int { return cmp.Compare(y, x) })
+	return free
+}
+
+// release records pages the commit stops referring to. They are not reused by
+// the same commit, since until it is durable the previous commit still refers to them.
+func (w *pageWriter) release(pages ...uint64) {
+	w.released = append(w.released, pages...)
+}
+
+// writePage seals body and writes it as page id.
+func (w *pageWriter) writePage(id uint64, body []byte) error {
+	page, err := w.c.sealPage(id, catalogPageSize, nil, body)
+	if err != nil {
+		return err
+	}
+	_, err = w.file.WriteAt(page, int64(id)*catalogPageSize)
+	return err
+}
+
+// write stores body in a newly allocated page and returns its number.
+func (w *pageWriter) write(body []byte) (uint64, error) {
+	id := w.alloc.allocate()
+	return id, w.writePage(id, body)
+}
+
+// writeMeta writes the meta page of commit meta.txid. Only its first
+// catalogMetaSize bytes are written; the rest of a meta page stays zero.
+func (w *pageWriter) writeMeta(meta catalogMeta) error {
+	slot := metaSlot(meta.txid)
+	page, err := w.c.sealPage(slot, catalogMetaSize, w.c.metaPrefix(), appendMeta(nil, meta))
+	if err != nil {
+		return err
+	}
+	_, err = w.file.WriteAt(page, int64(slot)*catalogPageSize)
+	return err
+}
+
+// writeChain stores data in a chain of pages of the given kind and returns the
+// pages in chain order. Each page body holds the kind, the number of the next
+// page (0 after the last) and a length-prefixed part of data.
+func (w *pageWriter) writeChain(kind byte, data []byte) ([]uint64, error) {
+	pages := w.allocateChain(len(data))
+	return pages, w.writeChainPages(kind, pages, data)
+}
+
+// chainPart is the number of bytes of data a chain page holds.
+func (w *pageWriter) chainPart() int {
+	return w.c.pageBodySize() - 1 - 2*binary.MaxVarintLen64
+}
+
+// allocateChain allocates the pages of a chain holding size bytes of data.
+func (w *pageWriter) allocateChain(size int) []uint64 {
+	part := w.chainPart()
+	pages := make([]uint64, max((size+part-1)/part, 1))
+	for i := range pages {
+		pages[i] = w.alloc.allocate()
+	}
+	return pages
+}
+
+// writeChainPages writes data as a chain of the given pages, see writeChain.
+// Pages that data does not need hold no data.
+func (w *pageWriter) writeChainPages(kind byte, pages []uint64, data []byte) error {
+	part := w.chainPart()
+	body := make([]byte, 0, w.c.pageBodySize())
+	for i, id := range pages {
+		var next uint64
+		if i+1 < len(pages) {
+			next = pages[i+1]
+		}
+		chunk := data[min(i*part, len(data)):min((i+1)*part, len(data))]
+		body = append(body[:0], kind)
+		body = binary.AppendUvarint(body, next)
+		body = binary.AppendUvarint(body, uint64(len(chunk)))
+		body = append(body, chunk...)
+		if err := w.writePage(id, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFilter stores data, a filter kind followed by the filter's encoding, in
+// filter pages. A part of data that matches the part in the same filter page of
+// old keeps that page; the others are written to new pages.
+// It reports whether any filter page changed.
+func (w *pageWriter) writeFilter(old treePages, data []byte) ([]uint64, [][32]byte, bool, error) {
+	part := w.c.pageBodySize() - 1 - binary.MaxVarintLen64
+	var pages []uint64
+	var sums [][32]byte
+	changed := false
+
+	body := make([]byte, 0, w.c.pageBodySize())
+	for i := 0; i*part < len(data); i++ {
+		chunk := data[i*part : min((i+1)*part, len(data))]
+		sum := sha256.Sum256(chunk)
+		if i < len(old.filter) && old.filterSums[i] == sum {
+			pages = append(pages, old.filter[i])
+			sums = append(sums, sum)
+			continue
+		}
+		if i < len(old.filter) {
+			w.release(old.filter[i])
+		}
+
+		body = append(body[:0], catalogPageFilter)
+		body = binary.AppendUvarint(body, uint64(len(chunk)))
+		body = append(body, chunk...)
+		id, err := w.write(body)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		pages = append(pages, id)
+		sums = append(sums, sum)
+		changed = true
+	}
+
+	if len(pages) < len(old.filter) {
+		w.release(old.filter[len(pages):]...)
+		changed = true
+	}
+	return pages, sums, changed, nil
+}
+
+// writeLeaf writes a leaf page holding encoded keys and returns it together
+// with the overflow pages of its long keys.
+// A page body holds the kind, the number of keys as 2 big-endian bytes, and
+// each key as a uvarint length followed by the key, or for a long key by its
+// inline prefix and the first overflow page as 8 big-endian bytes.
+func (w *pageWriter) writeLeaf(keys [][]byte) (uint64, []uint64, error) {
+	body := make([]byte, leafPageHeader, w.c.pageBodySize())
+	body[0] = catalogPageLeaf
+	binary.BigEndian.PutUint16(body[1:], uint16(len(keys)))
+
+	var overflow []uint64
+	for _, key := range keys {
+		body = binary.AppendUvarint(body, uint64(len(key)))
+		if len(key) <= catalogOverflowThreshold {
+			body = append(body, key...)
+			continue
+		}
+		pages, err := w.writeChain(catalogPageOverflow, key[catalogInlinePrefix:])
+		if err != nil {
+			return 0, nil, err
+		}
+		overflow = append(overflow, pages...)
+		body = append(body, key[:catalogInlinePrefix]...)
+		body = binary.BigEndian.AppendUint64(body, pages[0])
+	}
+
+	id, err := w.write(body)
+	return id, overflow, err
+}
+
+// appendPageList appends a count followed by that many page numbers.
+func appendPageList(dst []byte, pages []uint64) []byte {
+	dst = binary.AppendUvarint(dst, uint64(len(pages)))
+	for _, id := range pages {
+		dst = binary.AppendUvarint(dst, id)
+	}
+	return dst
+}
+
+// appendIndexData appends the contents of a tree's index chain:
+// the number of leaf pages and their page numbers, then the same for filter pages.
+func appendIndexData(dst []byte, pages *treePages) []byte {
+	dst = appendPageList(dst, pages.leaves)
+	return appendPageList(dst, pages.filter)
+}
+
+// readPageList reads a page list written by appendPageList.
+func readPageList(r *bytes.Reader) ([]uint64, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	pages := make([]uint64, n)
+	for i := range pages {
+		if pages[i], err = binary.ReadUvarint(r); err != nil {
+			return nil, err
+		}
+	}
+	return pages, nil
+}
+
+// pageReader reads the pages of a catalog file.
+// Every page may be read once only, so a damaged file cannot make two trees
+// share a page or a chain loop back on itself.
+type pageReader struct {
+	c     *Catalog
+	file  io.ReaderAt
+	count uint64
+	seen  map[uint64]bool
+}
+
+// page verifies page id, which must be of the given kind, and returns its body after the kind byte.
+func (r *pageReader) page(id uint64, kind byte) ([]byte, error) {
+	if id < catalogMetaPages || id >= r.count {
+		return nil, fmt.Errorf("page %d out of range", id)
+	}
+	if r.seen[id] {
+		return nil, fmt.Errorf("page %d is used twice", id)
+	}
+	r.seen[id] = true
+
+	page := make([]byte, catalogPageSize)
+	if _, err := r.file.ReadAt(page, int64(id)*catalogPageSize); err != nil {
+		return nil, err
+	}
+	body, err := r.c.openPage(id, page, 0)
+	if err != nil {
+		return nil, err
+	}
+	if body[0] != kind {
+		return nil, fmt.Errorf("page %d has kind %d, expected %d", id, body[0], kind)
+	}
+	return body[1:], nil
+}
+
+// chain reads the chain of pages of the given kind that starts at first and
+// returns its data and its pages.
+func (r *pageReader) chain(kind byte, first uint64) ([]byte, []uint64, error) {
+	var data []byte
+	var pages []uint64
+	for id := first; ; {
+		body, err := r.page(id, kind)
+		if err != nil {
+			return nil, nil, err
+		}
+		br := bytes.NewReader(body)
+		next, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		chunk, err := readLengthPrefixed(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		data = append(data, chunk...)
+		pages = append(pages, id)
+		if next == 0 {
+			return data, pages, nil
+		}
+		id = next
+	}
+}
+
+// leaf reads leaf page id and returns its encoded keys and the overflow pages of its long keys.
+func (r *pageReader) leaf(id uint64) ([][]byte, []uint64, error) {
+	body, err := r.page(id, catalogPageLeaf)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(body) < leafPageHeader-1 {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	keys := make([][]byte, binary.BigEndian.Uint16(body))
+	if len(keys) == 0 {
+		return nil, nil, fmt.Errorf("page %d: no keys", id)
+	}
+
+	var overflow []uint64
+	br := bytes.NewReader(body[leafPageHeader-1:])
+	for i := range keys {
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, nil, fmt.Errorf("page %d: %v", id, err)
+		}
+		inline := n
+		if n > catalogOverflowThreshold {
+			inline = catalogInlinePrefix + 8
+		}
+		if inline > uint64(br.Len()) {
+			return nil, nil, fmt.Errorf("page %d: %v", id, io.ErrUnexpectedEOF)
+		}
+		keys[i] = make([]byte, inline)
+		br.Read(keys[i])
+		if inline == n {
+			continue
+		}
+
+		first := binary.BigEndian.Uint64(keys[i][catalogInlinePrefix:])
+		rest, pages, err := r.chain(catalogPageOverflow, first)
+		if err != nil {
+			return nil, nil, err
+		}
+		if uint64(catalogInlinePrefix+len(rest)) != n {
+			return nil, nil, fmt.Errorf("page %d: overflow of %d bytes for a key of %d", id, len(rest), n)
+		}
+		keys[i] = append(keys[i][:catalogInlinePrefix], rest...)
+		overflow = append(overflow, pages...)
+	}
+	return keys, overflow, nil
+}
+
+// uvarintSize returns the number of bytes binary.AppendUvarint uses for x.
+func uvarintSize(x uint64) int {
+	return (bits.Len64(x|1) + 6) / 7
+}
+
+// leafPacker packs encoded keys into leaf pages by the bytes they take up,
+// starting a new page whenever the next key does not fit into the current one.
+type leafPacker struct {
+	capacity int
+	pages    [][][]byte // Keys of each page
+	sizes    []int      // Bytes each page takes up
+	starts   []int      // Index among the keys added of the first key of each page
+	count    int        // Number of keys added
+}
+
+// leafEntrySize returns the bytes a key of n bytes takes up in its leaf page.
+func leafEntrySize(n int) int {
+	if n > catalogOverflowThreshold {
+		return uvarintSize(uint64(n)) + catalogInlinePrefix + 8
+	}
+	return uvarintSize(uint64(n)) + n
+}
+
+// add appends an encoded key, starting a new page if it does not fit.
+// The packer keeps key, which must not be modified afterwards.
+func (p *leafPacker) add(key []byte) {
+	size := leafEntrySize(len(key))
+	n := len(p.pages)
+	if n == 0 || p.sizes[n-1]+size > p.capacity {
+		p.pages = append(p.pages, nil)
+		p.sizes = append(p.sizes, leafPageHeader)
+		p.starts = append(p.starts, p.count)
+		n++
+	}
+	p.pages[n-1] = append(p.pages[n-1], key)
+	p.sizes[n-1] += size
+	p.count++
+}
+
+// balance moves keys from the second to last page to the last one while the
+// last one stays the smaller, so that a run of keys slightly larger than a
+// page does not leave an almost empty page behind.
+func (p *leafPacker) balance() {
+	n := len(p.pages)
+	if n < 2 || !p.lastUnderfull() {
+		return
+	}
+
+	keys := p.pages[n-2]
+	split := len(keys)
+	for split > 1 {
+		size := leafEntrySize(len(keys[split-1]))
+		if p.sizes[n-1]+size > p.sizes[n-2]-size {
+			break
+		}
+		split--
+		p.sizes[n-2] -= size
+		p.sizes[n-1] += size
+	}
+
+	p.pages[n-1] = slices.Concat(keys[split:], p.pages[n-1])
+	p.pages[n-2] = keys[:split]
+	p.starts[n-1] -= len(keys) - split
+}
+
+// lastUnderfull reports whether the last page is less than half full.
+func (p *leafPacker) lastUnderfull() bool {
+	n := len(p.pages)
+	return n > 0 && p.sizes[n-1] < p.capacity/2
+}
+
+// pagedTree keeps an opened tree of a catalog in step with its leaf pages.
+//
+// Each leaf page holds the keys from its first key up to the first key of the
+// next page; the first page also holds all smaller keys. Leaf pages are packed
+// by bytes, independently of the tree's nodes. A commit rewrites only the
+// leaf pages whose key ranges overlap the leaves changed since the previous
+// commit, found with the checkpoints of the tree, see changedRanges.
+type pagedTree[K any] struct {
+	tree   *GenericBPlusTree[K]
+	codec  Codec[K]
+	firsts []K    // First key of each leaf page
+	since  uint64 // Checkpoint started by the latest commit; leaves changed since are not written
+}
+
+// leafSpan is the run of leaf pages [lo, hi) that a commit rewrites.
+type leafSpan struct {
+	lo, hi int
+}
+
+// commit writes the pages of the tree that changed since the latest commit.
+// It returns the tree's new pages and a function that records them in p once
+// the commit is durable.
+// Time complexity: O(L + c) where L is the number of leaves of the tree and
+// c is the number of keys in the rewritten leaf pages, plus the size of the filter.
+func (p *pagedTree[K]) commit(w *pageWriter, old treePages) (treePages, func(), error) {
+	t := p.tree
+	t.Flush()
+
+	pages := old
+	firsts := p.firsts
+	changed := !old.written()
+	if ranges := t.changedRanges(p.since); len(ranges) > 0 {
+		var err error
+		if pages.leaves, pages.overflow, firsts, err = p.writeRanges(w, old, ranges); err != nil {
+			return treePages{}, nil, err
+		}
+		changed = true
+	}
+	since := t.Checkpoint()
+
+	filter := appendFilterData(nil, t.bloomFilter, filterProbes(p.codec, firsts))
+	var filterChanged bool
+	var err error
+	pages.filter, pages.filterSums, filterChanged, err = w.writeFilter(old, filter)
+	if err != nil {
+		return treePages{}, nil, err
+	}
+
+	if changed || filterChanged {
+		w.release(old.index...)
+		if pages.index, err = w.writeChain(catalogPageIndex, appendIndexData(nil, &pages)); err != nil {
+			return treePages{}, nil, err
+		}
+	}
+	return pages, func() { p.firsts, p.since = firsts, since }, nil
+}
+
+// writeRanges rewrites the leaf pages whose key ranges overlap the changed
+// ranges, and returns the new list of leaf pages, their overflow pages and
+// their first keys.
+// A rewritten run of pages that ends up as a single page less than half full
+// takes in the next page, so pages that lose keys are merged with their neighbours.
+func (p *pagedTree[K]) writeRanges(w *pageWriter, old treePages, ranges []deltaRange[K]) ([]uint64, [][]uint64, []K, error) {
+	var leaves []uint64
+	var overflow [][]uint64
+	var firsts []K
+	next := 0
+
+	spans := p.spans(ranges)
+	for i, s := range spans {
+		leaves = append(leaves, old.leaves[next:s.lo]...)
+		overflow = append(overflow, old.overflow[next:s.lo]...)
+		firsts = append(firsts, p.firsts[next:s.lo]...)
+
+		packer, spanFirsts := p.pack(w.c.pageBodySize(), s)
+		for packer.lastUnderfull() && s.hi < len(old.leaves) && (i+1 == len(spans) || spans[i+1].lo > s.hi) {
+			s.hi++
+			packer, spanFirsts = p.pack(w.c.pageBodySize(), s)
+		}
+
+		for _, keys := range packer.pages {
+			id, pages, err := w.writeLeaf(keys)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			leaves = append(leaves, id)
+			overflow = append(overflow, pages)
+		}
+		firsts = append(firsts, spanFirsts...)
+		w.release(old.leaves[s.lo:s.hi]...)
+		w.release(slices.Concat(old.overflow[s.lo:s.hi]...)...)
+		next = s.hi
+	}
+
+	leaves = append(leaves, old.leaves[next:]...)
+	overflow = append(overflow, old.overflow[next:]...)
+	firsts = append(firsts, p.firsts[next:]...)
+	return leaves, overflow, firsts, nil
+}
+
+// spans returns the runs of leaf pages whose key ranges overlap the changed ranges,
+// in ascending order. Runs that overlap or touch are joined.
+func (p *pagedTree[K]) spans(ranges []deltaRange[K]) []leafSpan {
+	if len(p.firsts) == 0 {
+		return []leafSpan{{0, 0}}
+	}
+
+	var spans []leafSpan
+	for _, r := range ranges {
+		// The range holds keys above r.low and below r.high
+		lo, hi := 0, len(p.firsts)
+		if r.hasLow {
+			n, found := slices.BinarySearchFunc(p.firsts, r.low, p.tree.compare)
+			if found {
+				n++
+			}
+			lo = max(n-1, 0)
+		}
+		if r.hasHigh {
+			n, _ := slices.BinarySearchFunc(p.firsts, r.high, p.tree.compare)
+			hi = max(n, lo+1)
+		}
+
+		if last := len(spans) - 1; last >= 0 && lo <= spans[last].hi {
+			spans[last].hi = max(spans[last].hi, hi)
+		} else {
+			spans = append(spans, leafSpan{lo, hi})
+		}
+	}
+	return spans
+}
+
+// pack packs the tree's keys in the key range of the leaf pages in s into new
+// pages, and returns them with the first key of each.
+func (p *pagedTree[K]) pack(capacity int, s leafSpan) (*leafPacker, []K) {
+	packer := &leafPacker{capacity: capacity}
+
+	var start, end K
+	if s.lo > 0 {
+		start = p.firsts[s.lo]
+	}
+	hasEnd := s.hi < len(p.firsts)
+	if hasEnd {
+		end = p.firsts[s.hi]
+	}
+
+	var keys []K
+	p.tree.ascendFrom(start, s.lo > 0, func(key K) bool {
+		if hasEnd && p.tree.compare(key, end) >= 0 {
+			return false
+		}
+		packer.add(p.codec.AppendKey(nil, key))
+		keys = append(keys, key)
+		return true
+	})
+
+	packer.balance()
+	firsts := make([]K, len(packer.starts))
+	for i, start := range packer.starts {
+		firsts[i] = keys[start]
+	}
+	return packer, firsts
+}
+
+// ascendFrom calls yield for the keys of t from start, or from the smallest key
+// if hasStart is false, in ascending order until yield returns false.
+// A buffered tree must be flushed first.
+// Time complexity: O(log n + k) where k is the number of keys yielded.
+func (t *GenericBPlusTree[K]) ascendFrom(start K, hasStart bool, yield func(K) bool) {
+	leaf, pos := t.firstLeaf(), 0
+	if hasStart {
+		if leaf = t.findLeafNode(t.root, start); leaf == nil {
+			return
+		}
+		pos, _ = t.search(leaf.keys, start)
+	}
+
+	for ; leaf != nil; leaf, pos = leaf.next, 0 {
+		for _, key := range leaf.keys[pos:] {
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
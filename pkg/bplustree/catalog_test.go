@@ -0,0 +1,700 @@
+package bplustree
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestCatalogCommitAndReopen tests that several trees survive a commit and reopen
+func TestCatalogCommitAndReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trees.db")
+
+	catalog, err := OpenCatalog(path)
+	if err != nil {
+		t.Fatalf("OpenCatalog failed: %v", err)
+	}
+
+	ids, err := CreateTree(catalog, "ids", Uint64Codec{}, 8)
+	if err != nil {
+		t.Fatalf("CreateTree failed: %v", err)
+	}
+	for i := uint64(0); i < 1000; i++ {
+		ids.Insert(i * 3)
+	}
+
+	names, err := CreateTree(catalog, "names", StringCodec{}, 4)
+	if err != nil {
+		t.Fatalf("CreateTree failed: %v", err)
+	}
+	for _, name := range []string{"carol", "alice", "bob"} {
+		names.Insert(name)
+	}
+
+	if _, err := CreateTree(catalog, "empty", IntCodec{}, 16); err != nil {
+		t.Fatalf("CreateTree failed: %v", err)
+	}
+
+	if _, err := CreateTree(catalog, "ids", Uint64Codec{}, 8); !errors.Is(err, ErrTreeExists) {
+		t.Errorf("Expected ErrTreeExists, got %v", err)
+	}
+	if err := catalog.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	reopened, err := OpenCatalog(path)
+	if err != nil {
+		t.Fatalf("OpenCatalog failed: %v", err)
+	}
+	if got := reopened.ListTrees(); !slices.Equal(got, []string{"empty", "ids", "names"}) {
+		t.Errorf("Unexpected trees %v", got)
+	}
+
+	ids2, err := OpenTree(reopened, "ids", Uint64Codec{})
+	if err != nil {
+		t.Fatalf("OpenTree failed: %v", err)
+	}
+	if ids2.Size() != 1000 || ids2.BranchingFactor() != 8 {
+		t.Errorf("Expected 1000 keys with branching factor 8, got %s", ids2)
+	}
+	if !ids2.Contains(999*3) || ids2.Contains(1) {
+		t.Errorf("Reopened tree has wrong contents")
+	}
+	checkTreeInvariants(t, ids2)
+
+	names2, err := OpenTree(reopened, "names", StringCodec{})
+	if err != nil {
+		t.Fatalf("OpenTree failed: %v", err)
+	}
+	if got := names2.RangeQuery("a", "z"); !slices.Equal(got, []string{"alice", "bob", "carol"}) {
+		t.Errorf("Unexpected names %v", got)
+	}
+
+	empty, err := OpenTree(reopened, "empty", IntCodec{})
+	if err != nil || !empty.IsEmpty() {
+		t.Errorf("Expected an empty tree, got %v, %v", empty, err)
+	}
+
+	// Opening twice returns the same tree
+	again, _ := OpenTree(reopened, "ids", Uint64Codec{})
+	if again != ids2 {
+		t.Errorf("Expected OpenTree to return the already opened tree")
+	}
+	if _, err := OpenTree(reopened, "ids", StringCodec{}); err == nil {
+		t.Errorf("Expected an error when opening with a different key type")
+	}
+}
+
+// TestCatalogDropTree tests that dropped trees disappear after a commit
+func TestCatalogDropTree(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trees.db")
+
+	catalog, _ := OpenCatalog(path)
+	CreateTree(catalog, "a", IntCodec{}, 4)
+	CreateTree(catalog, "b", IntCodec{}, 4)
+	if err := catalog.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	catalog, _ = OpenCatalog(path)
+	if err := catalog.DropTree("a"); err != nil {
+		t.Fatalf("DropTree failed: %v", err)
+	}
+	if err := catalog.DropTree("a"); !errors.Is(err, ErrTreeNotFound) {
+		t.Errorf("Expected ErrTreeNotFound, got %v", err)
+	}
+	if _, err := OpenTree(catalog, "a", IntCodec{}); !errors.Is(err, ErrTreeNotFound) {
+		t.Errorf("Expected ErrTreeNotFound, got %v", err)
+	}
+
+	// Until the commit, the file still holds the dropped tree
+	before, _ := OpenCatalog(path)
+	if len(before.ListTrees()) != 2 {
+		t.Errorf("Expected uncommitted drop to leave the file unchanged")
+	}
+
+	if err := catalog.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	after, _ := OpenCatalog(path)
+	if got := after.ListTrees(); !slices.Equal(got, []string{"b"}) {
+		t.Errorf("Unexpected trees %v", got)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Expected only the catalog file, found %d entries", len(entries))
+	}
+}
+
+// TestCatalogUnopenedTreesArePreserved tests that trees not opened in a session are rewritten unchanged
+func TestCatalogUnopenedTreesArePreserved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trees.db")
+
+	catalog, _ := OpenCatalog(path)
+	tree, _ := CreateTree(catalog, "kept", IntCodec{}, 4)
+	for i := 0; i < 100; i++ {
+		tree.Insert(i)
+	}
+	catalog.Commit()
+
+	catalog, _ = OpenCatalog(path)
+	CreateTree(catalog, "other", IntCodec{}, 4)
+	catalog.Commit()
+
+	catalog, _ = OpenCatalog(path)
+	kept, err := OpenTree(catalog, "kept", IntCodec{})
+	if err != nil {
+		t.Fatalf("OpenTree failed: %v", err)
+	}
+	if kept.Size() != 100 {
+		t.Errorf("Expected 100 keys, got %d", kept.Size())
+	}
+}
+
+// TestCatalogCorruption tests that damaged files are rejected with ErrCorrupt
+func TestCatalogCorruption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trees.db")
+
+	catalog, _ := OpenCatalog(path)
+	tree, _ := CreateTree(catalog, "ids", Uint64Codec{}, 4)
+	for i := uint64(0); i < 50; i++ {
+		tree.Insert(i)
+	}
+	catalog.Commit()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string][]byte{
+		"flipped byte": func() []byte {
+			d := slices.Clone(data)
+			d[len(d)/2] ^= 0xff
+			return d
+		}(),
+		"truncated": data[:len(data)-3],
+		"bad magic": append([]byte("XXXX"), data[4:]...),
+	}
+	for name, damaged := range cases {
+		t.Run(name, func(t *testing.T) {
+			os.WriteFile(path, damaged, 0o644)
+			if _, err := OpenCatalog(path); !errors.Is(err, ErrCorrupt) {
+				t.Errorf("Expected ErrCorrupt, got %v", err)
+			}
+		})
+	}
+}
+
+// TestEncryptedCatalog tests that an encrypted catalog round-trips and hides its contents
+func TestEncryptedCatalog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trees.db")
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	catalog, err := OpenEncryptedCatalog(path, key)
+	if err != nil {
+		t.Fatalf("OpenEncryptedCatalog failed: %v", err)
+	}
+	tree, _ := CreateTree(catalog, "customers", StringCodec{}, 4)
+	for _, id := range []string{"cust-0001", "cust-0002", "cust-0003"} {
+		tree.Insert(id)
+	}
+	if err := catalog.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if bytes.Contains(data, []byte("cust-")) || bytes.Contains(data, []byte("customers")) {
+		t.Errorf("Expected keys and tree names to be encrypted")
+	}
+
+	reopened, err := OpenEncryptedCatalog(path, key)
+	if err != nil {
+		t.Fatalf("OpenEncryptedCatalog failed: %v", err)
+	}
+	tree2, err := OpenTree(reopened, "customers", StringCodec{})
+	if err != nil {
+		t.Fatalf("OpenTree failed: %v", err)
+	}
+	if got := tree2.RangeQuery("cust-0002", "cust-9999"); !slices.Equal(got, []string{"cust-0002", "cust-0003"}) {
+		t.Errorf("Unexpected range %v", got)
+	}
+
+	if _, err := OpenEncryptedCatalog(path, []byte("fedcba9876543210fedcba9876543210")); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Expected ErrCorrupt for a wrong key, got %v", err)
+	}
+	if _, err := OpenCatalog(path); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Expected ErrCorrupt when opening without a key, got %v", err)
+	}
+	if _, err := OpenEncryptedCatalog(path, []byte("short")); err == nil || errors.Is(err, ErrCorrupt) {
+		t.Errorf("Expected a key error, got %v", err)
+	}
+}
+
+// TestEncryptedCatalogTampering tests that any modification is reported as ErrCorrupt
+func TestEncryptedCatalogTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trees.db")
+	key := []byte("0123456789abcdef")
+
+	catalog, _ := OpenEncryptedCatalog(path, key)
+	for _, name := range []string{"a", "b"} {
+		tree, _ := CreateTree(catalog, name, Uint64Codec{}, 4)
+		for i := uint64(0); i < 20; i++ {
+			tree.Insert(i)
+		}
+	}
+	catalog.Commit()
+	data, _ := os.ReadFile(path)
+
+	// Every single-byte change must be detected, even in the meta page the
+	// first commit did not write
+	for i := range data {
+		damaged := slices.Clone(data)
+		damaged[i] ^= 0x01
+		os.WriteFile(path, damaged, 0o644)
+
+		if _, err := OpenEncryptedCatalog(path, key); !errors.Is(err, ErrCorrupt) {
+			t.Fatalf("Flipping byte %d: expected ErrCorrupt, got %v", i, err)
+		}
+	}
+}
+
+// TestCatalogStoresBloomFilter tests that a tree's bloom filter is stored and trusted on load
+func TestCatalogStoresBloomFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trees.db")
+
+	catalog, _ := OpenCatalog(path)
+	tree, _ := CreateTree(catalog, "ids", Uint64Codec{}, 16)
+	for i := uint64(0); i < 500; i++ {
+		tree.Insert(i * 2)
+	}
+	tree.Contains(0) // Make the filter valid so it is stored
+	if err := catalog.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	reopened, _ := OpenCatalog(path)
+	loaded, err := OpenTree(reopened, "ids", Uint64Codec{})
+	if err != nil {
+		t.Fatalf("OpenTree failed: %v", err)
+	}
+
+	want := tree.bloomFilter.(*CountingBloomFilter)
+	got, ok := loaded.bloomFilter.(*CountingBloomFilter)
+	if !ok || !got.IsValid() || !slices.Equal(got.counters, want.counters) {
+		t.Fatalf("Expected the stored counting bloom filter to be loaded as is")
+	}
+	if !loaded.Contains(998) || loaded.Contains(999) {
+		t.Errorf("Loaded tree has wrong contents")
+	}
+
+	// A filter built with another hash is recomputed instead of trusted
+	reopened, _ = OpenCatalog(path)
+	loaded, err = OpenTree(reopened, "ids", mixedUint64Codec{})
+	if err != nil {
+		t.Fatalf("OpenTree failed: %v", err)
+	}
+	for i := uint64(0); i < 500; i++ {
+		if !loaded.Contains(i * 2) {
+			t.Fatalf("Expected %d to be found through the recomputed filter", i*2)
+		}
+	}
+}
+
+// mixedUint64Codec encodes like Uint64Codec but hashes keys differently
+type mixedUint64Codec struct{ Uint64Codec }
+
+func (mixedUint64Codec) Hash(key uint64) uint64 { return mix64(key) }
+
+// TestCatalogRejectsInvalidBranchingFactor tests that a stored branching factor no constructor produces is corrupt
+func TestCatalogRejectsInvalidBranchingFactor(t *testing.T) {
+	for _, branchingFactor := range []int{0, 2} {
+		path := filepath.Join(t.TempDir(), "trees.db")
+		catalog, _ := OpenCatalog(path)
+		CreateTree(catalog, "ids", Uint64Codec{}, 4)
+		catalog.trees["ids"].branchingFactor = branchingFactor
+		if err := catalog.Commit(); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		if _, err := OpenCatalog(path); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("Expected ErrCorrupt for branching factor %d, got %v", branchingFactor, err)
+		}
+	}
+}
+
+// changedPages returns how many pages of after differ from before, counting pages beyond the end of before
+func changedPages(before, after []byte) int {
+	changed := 0
+	for start := 0; start < len(after); start += catalogPageSize {
+		end := start + catalogPageSize
+		if end > len(before) || !bytes.Equal(before[start:end], after[start:end]) {
+			changed++
+		}
+	}
+	return changed
+}
+
+// TestCatalogCommitWritesChangedPages tests that a commit writes only the pages of what changed
+func TestCatalogCommitWritesChangedPages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trees.db")
+
+	catalog, _ := OpenCatalog(path)
+	ids, _ := CreateTree(catalog, "ids", Uint64Codec{}, 32)
+	for i := uint64(0); i < 100000; i++ {
+		ids.Insert(i * 2)
+	}
+	ids.Contains(1) // Builds the filter so that it is stored
+	names, _ := CreateTree(catalog, "names", StringCodec{}, 8)
+	for i := 0; i < 1000; i++ {
+		names.Insert(fmt.Sprintf("name-%04d", i))
+	}
+	if err := catalog.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	before, _ := os.ReadFile(path)
+
+	ids.Insert(50001)
+	if err := catalog.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	after, _ := os.ReadFile(path)
+
+	// Two leaf pages, the filter pages of the new key's positions, the index,
+	// the directory, the free list and the meta page
+	changed := changedPages(before, after)
+	if limit := 5 + len(catalog.trees["ids"].pages.index) + 7; changed > limit {
+		t.Errorf("Expected at most %d of %d pages to change, got %d", limit, len(after)/catalogPageSize, changed)
+	}
+
+	// A commit without changes writes only the meta page
+	before = after
+	catalog.Commit()
+	after, _ = os.ReadFile(path)
+	if changed := changedPages(before, after); changed != 1 {
+		t.Errorf("Expected 1 page to change, got %d", changed)
+	}
+
+	reopened, _ := OpenCatalog(path)
+	loaded, _ := OpenTree(reopened, "ids", Uint64Codec{})
+	if loaded.Size() != 100001 || !loaded.Contains(50001) || loaded.Contains(50003) {
+		t.Errorf("Unexpected tree of %d keys after reopen", loaded.Size())
+	}
+}
+
+// TestCatalogReusesPages tests that pages freed by a commit are reused so the file stops growing
+func TestCatalogReusesPages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trees.db")
+
+	catalog, _ := OpenCatalog(path)
+	tree, _ := CreateTree(catalog, "ids", Uint64Codec{}, 16)
+	for i := uint64(0); i < 20000; i++ {
+		tree.Insert(i)
+	}
+
+	var sizes []int64
+	for round := uint64(0); round < 10; round++ {
+		for i := uint64(0); i < 20000; i += 10 {
+			tree.Delete(i + round%10)
+			tree.Insert(i + round%10)
+		}
+		if err := catalog.Commit(); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		info, _ := os.Stat(path)
+		sizes = append(sizes, info.Size())
+	}
+	if sizes[9] > sizes[2] {
+		t.Errorf("Expected the file to stop growing, sizes %v", sizes)
+	}
+
+	// Dropping a tree frees its pages for the other trees
+	catalog.DropTree("ids")
+	catalog.Commit()
+	other, _ := CreateTree(catalog, "other", Uint64Codec{}, 16)
+	for i := uint64(0); i < 20000; i++ {
+		other.Insert(i)
+	}
+	catalog.Commit()
+	if info, _ := os.Stat(path); info.Size() > sizes[9] {
+		t.Errorf("Expected the new tree to reuse the dropped tree's pages, size %d > %d", info.Size(), sizes[9])
+	}
+}
+
+// TestCatalogRandomCommits tests trees against maps across many commits and reopens
+func TestCatalogRandomCommits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trees.db")
+	rng := rand.New(rand.NewSource(7))
+
+	catalog, _ := OpenCatalog(path)
+	trees := map[string]*GenericBPlusTree[uint64]{}
+	models := map[string]map[uint64]bool{}
+	for _, name := range []string{"a", "b", "c"} {
+		trees[name], _ = CreateTree(catalog, name, Uint64Codec{}, 3+len(models)*20)
+		models[name] = map[uint64]bool{}
+	}
+
+	for round := 0; round < 40; round++ {
+		for name, tree := range trees {
+			// Clustered changes, with more deletes in later rounds
+			base := uint64(rng.Intn(20000))
+			for i := 0; i < rng.Intn(3000); i++ {
+				key := base + uint64(rng.Intn(5000))
+				if rng.Intn(40) < round {
+					tree.Delete(key)
+					delete(models[name], key)
+				} else {
+					tree.Insert(key)
+					models[name][key] = true
+				}
+			}
+		}
+		if err := catalog.Commit(); err != nil {
+			t.Fatalf("Round %d: Commit failed: %v", round, err)
+		}
+
+		if round%5 != 4 {
+			continue
+		}
+		reopened, err := OpenCatalog(path)
+		if err != nil {
+			t.Fatalf("Round %d: OpenCatalog failed: %v", round, err)
+		}
+		for name, model := range models {
+			loaded, err := OpenTree(reopened, name, Uint64Codec{})
+			if err != nil {
+				t.Fatalf("Round %d: OpenTree(%q) failed: %v", round, name, err)
+			}
+			want := slices.Sorted(maps.Keys(model))
+			if got := loaded.RangeQuery(0, math.MaxUint64); !slices.Equal(got, want) {
+				t.Fatalf("Round %d: tree %q has %d keys, expected %d", round, name, len(got), len(want))
+			}
+
+			// Pages emptied by deletes are merged with their neighbours
+			pages := len(reopened.trees[name].pages.leaves)
+			if perPage := catalogPageSize / 9; pages > 3*len(want)/perPage+3 {
+				t.Errorf("Round %d: tree %q uses %d leaf pages for %d keys", round, name, pages, len(want))
+			}
+		}
+	}
+}
+
+// TestCatalogRejectsDamagedMetaPage tests that a damaged meta page is reported
+// instead of falling back to the commit of the other one
+func TestCatalogRejectsDamagedMetaPage(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	open := map[string]func(path string) (*Catalog, error){
+		"plain":     OpenCatalog,
+		"encrypted": func(path string) (*Catalog, error) { return OpenEncryptedCatalog(path, key) },
+	}
+	for name, openCatalog := range open {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "trees.db")
+			catalog, _ := openCatalog(path)
+			tree, _ := CreateTree(catalog, "ids", Uint64Codec{}, 8)
+			tree.Insert(1)
+			catalog.Commit()
+			tree.Insert(2)
+			catalog.Commit()
+			data, _ := os.ReadFile(path)
+
+			// The second commit wrote the second meta page, the first one the first
+			for _, offset := range []int{catalogPageSize + 10, 10} {
+				damaged := slices.Clone(data)
+				damaged[offset] ^= 0xff
+				os.WriteFile(path, damaged, 0o644)
+				if _, err := openCatalog(path); !errors.Is(err, ErrCorrupt) {
+					t.Errorf("Damaging byte %d: expected ErrCorrupt, got %v", offset, err)
+				}
+			}
+
+			os.WriteFile(path, data, 0o644)
+			reopened, err := openCatalog(path)
+			if err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			loaded, _ := OpenTree(reopened, "ids", Uint64Codec{})
+			if got := loaded.RangeQuery(0, 10); !slices.Equal(got, []uint64{1, 2}) {
+				t.Errorf("Expected the keys of the second commit, got %v", got)
+			}
+		})
+	}
+}
+
+// TestCatalogStoresFreePages tests that the free pages are stored in the file and reused after reopening
+func TestCatalogStoresFreePages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trees.db")
+
+	catalog, _ := OpenCatalog(path)
+	tree, _ := CreateTree(catalog, "ids", Uint64Codec{}, 16)
+	for i := uint64(0); i < 50000; i++ {
+		tree.Insert(i)
+	}
+	catalog.Commit()
+	for i := uint64(0); i < 40000; i++ {
+		tree.Delete(i)
+	}
+	catalog.Commit()
+	if len(catalog.alloc.free) == 0 {
+		t.Fatal("Expected deletes to free pages")
+	}
+
+	reopened, err := OpenCatalog(path)
+	if err != nil {
+		t.Fatalf("OpenCatalog failed: %v", err)
+	}
+	if !slices.Equal(reopened.alloc.free, catalog.alloc.free) {
+		t.Errorf("Expected free pages %v after reopen, got %v", catalog.alloc.free, reopened.alloc.free)
+	}
+
+	before, _ := os.Stat(path)
+	loaded, _ := OpenTree(reopened, "ids", Uint64Codec{})
+	for i := uint64(0); i < 20000; i++ {
+		loaded.Insert(i)
+	}
+	reopened.Commit()
+	if after, _ := os.Stat(path); after.Size() > before.Size() {
+		t.Errorf("Expected inserts to reuse free pages, file grew from %d to %d", before.Size(), after.Size())
+	}
+}
+
+// TestCatalogVacuum tests that Vacuum shrinks the file while trees keep working
+func TestCatalogVacuum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trees.db")
+
+	catalog, _ := OpenCatalog(path)
+	dropped, _ := CreateTree(catalog, "a", Uint64Codec{}, 16)
+	kept, _ := CreateTree(catalog, "b", StringCodec{}, 16)
+	for i := 0; i < 30000; i++ {
+		dropped.Insert(uint64(i))
+		kept.Insert(fmt.Sprintf("key-%06d", i))
+	}
+	kept.Contains("key") // Builds the filter so that it is stored
+	catalog.Commit()
+	catalog.DropTree("a")
+	for i := 0; i < 30000; i += 2 {
+		kept.Delete(fmt.Sprintf("key-%06d", i))
+	}
+	catalog.Commit()
+	before, _ := os.Stat(path)
+
+	if err := catalog.Vacuum(); err != nil {
+		t.Fatalf("Vacuum failed: %v", err)
+	}
+	after, _ := os.Stat(path)
+	live := uint64(catalogMetaPages + len(catalog.directory) + len(catalog.freelist))
+	for _, entry := range catalog.trees {
+		live += uint64(len(entry.pages.all()))
+	}
+	if pages := uint64(after.Size()) / catalogPageSize; pages > live+2 || after.Size() >= before.Size() {
+		t.Errorf("Expected about %d pages after Vacuum, file shrank from %d to %d pages", live, before.Size()/catalogPageSize, pages)
+	}
+
+	// The tree is still in use
+	kept.Insert("key-999999")
+	if err := catalog.Commit(); err != nil {
+		t.Fatalf("Commit after Vacuum failed: %v", err)
+	}
+
+	check := func(c *Catalog, size int) {
+		t.Helper()
+		loaded, err := OpenTree(c, "b", StringCodec{})
+		if err != nil {
+			t.Fatalf("OpenTree failed: %v", err)
+		}
+		if loaded.Size() != size || !loaded.Contains("key-000001") || loaded.Contains("key-000002") {
+			t.Errorf("Unexpected tree of %d keys, expected %d", loaded.Size(), size)
+		}
+	}
+	reopened, err := OpenCatalog(path)
+	if err != nil {
+		t.Fatalf("OpenCatalog failed: %v", err)
+	}
+	check(reopened, 15001)
+
+	// Both meta pages describe the vacuumed file
+	catalog.Vacuum()
+	data, _ := os.ReadFile(path)
+	for slot := uint64(0); slot < catalogMetaPages; slot++ {
+		meta, err := catalog.readMetaPage(slot, data[slot*catalogPageSize:(slot+1)*catalogPageSize])
+		if err != nil {
+			t.Fatalf("Meta page %d: %v", slot, err)
+		}
+		if meta.pageCount > uint64(len(data))/catalogPageSize {
+			t.Errorf("Meta page %d refers to %d pages, the file has %d", slot, meta.pageCount, len(data)/catalogPageSize)
+		}
+	}
+	reopened, _ = OpenCatalog(path)
+	check(reopened, 15001)
+}
+
+// TestCatalogStoresLongKeys tests that keys longer than a page are stored in overflow pages
+func TestCatalogStoresLongKeys(t *testing.T) {
+	for _, encrypted := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "trees.db")
+		open := func() *Catalog {
+			t.Helper()
+			var catalog *Catalog
+			var err error
+			if encrypted {
+				catalog, err = OpenEncryptedCatalog(path, []byte("0123456789abcdef"))
+			} else {
+				catalog, err = OpenCatalog(path)
+			}
+			if err != nil {
+				t.Fatalf("Opening catalog failed: %v", err)
+			}
+			return catalog
+		}
+
+		// Keys from a few bytes to several pages long
+		rng := rand.New(rand.NewSource(3))
+		model := map[string]bool{}
+		catalog := open()
+		tree, _ := CreateTree(catalog, "docs", StringCodec{}, 8)
+		for i := 0; i < 300; i++ {
+			key := fmt.Sprintf("%04d-", i) + strings.Repeat(string(rune('a'+i%26)), rng.Intn(3)*rng.Intn(9000))
+			tree.Insert(key)
+			model[key] = true
+		}
+		if err := catalog.Commit(); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		overflow := len(slices.Concat(catalog.trees["docs"].pages.overflow...))
+		if overflow == 0 {
+			t.Fatal("Expected long keys to use overflow pages")
+		}
+
+		// Deleting long keys frees their overflow pages
+		for key := range model {
+			if len(key) > catalogOverflowThreshold && rng.Intn(2) == 0 {
+				tree.Delete(key)
+				delete(model, key)
+			}
+		}
+		catalog.Commit()
+		if got := len(slices.Concat(catalog.trees["docs"].pages.overflow...)); got >= overflow {
+			t.Errorf("Expected fewer than %d overflow pages after deletes, got %d", overflow, got)
+		}
+		if err := catalog.Vacuum(); err != nil {
+			t.Fatalf("Vacuum failed: %v", err)
+		}
+
+		loaded, err := OpenTree(open(), "docs", StringCodec{})
+		if err != nil {
+			t.Fatalf("OpenTree failed: %v", err)
+		}
+		want := slices.Sorted(maps.Keys(model))
+		if got := loaded.RangeQuery("", "\xff"); !slices.Equal(got, want) {
+			t.Errorf("Encrypted %v: expected %d keys after reopen, got %d", encrypted, len(want), len(got))
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package bplustree
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+)
+
+// Codec describes how keys of type K are ordered, hashed and serialized.
+// It bundles the three functions a GenericBPlusTree needs with a binary
+// encoding, so that keys can be written to files and read back later.
+type Codec[K any] interface {
+	// Less returns true if a < b.
+	Less(a, b K) bool
+
+	// Equal returns true if a == b.
+	Equal(a, b K) bool
+
+	// Hash converts a key to a uint64 for bloom filter usage.
+	// It must return the same value for a key in every process, since
+	// catalogs store bloom filters built from it.
+	Hash(key K) uint64
+
+	// AppendKey appends the encoded form of key to dst and returns the extended slice.
+	AppendKey(dst []byte, key K) []byte
+
+	// DecodeKey decodes a key previously encoded with AppendKey.
+	// src holds exactly one encoded key.
+	DecodeKey(src []byte) (K, error)
+}
+
+// Uint64Codec is a Codec for uint64 keys.
+// Keys are encoded as 8 big-endian bytes.
+type Uint64Codec struct{}
+
+// Less returns true if a < b.
+func (Uint64Codec) Less(a, b uint64) bool { return a < b }
+
+// Equal returns true if a == b.
+func (Uint64Codec) Equal(a, b uint64) bool { return a == b }
+
+// Hash returns the key itself.
+func (Uint64Codec) Hash(key uint64) uint64 { return key }
+
+// AppendKey appends the 8-byte big-endian encoding of key to dst.
+func (Uint64Codec) AppendKey(dst []byte, key uint64) []byte {
+	return binary.BigEndian.AppendUint64(dst, key)
+}
+
+// DecodeKey decodes an 8-byte big-endian key.
+func (Uint64Codec) DecodeKey(src []byte) (uint64, error) {
+	if len(src) != 8 {
+		return 0, fmt.Errorf("bplustree: uint64 key must be 8 bytes, got %d", len(src))
+	}
+	return binary.BigEndian.Uint64(src), nil
+}
+
+// IntCodec is a Codec for int keys.
+// Keys are encoded as 8 big-endian bytes of their int64 value.
+type IntCodec struct{}
+
+// Less returns true if a < b.
+func (IntCodec) Less(a, b int) bool { return a < b }
+
+// Equal returns true if a == b.
+func (IntCodec) Equal(a, b int) bool { return a == b }
+
+// Hash converts the key to a uint64.
+func (IntCodec) Hash(key int) uint64 { return uint64(key) }
+
+// AppendKey appends the 8-byte big-endian encoding of key to dst.
+func (IntCodec) AppendKey(dst []byte, key int) []byte {
+	return binary.BigEndian.AppendUint64(dst, uint64(int64(key)))
+}
+
+// DecodeKey decodes an 8-byte big-endian key.
+func (IntCodec) DecodeKey(src []byte) (int, error) {
+	if len(src) != 8 {
+		return 0, fmt.Errorf("bplustree: int key must be 8 bytes, got %d", len(src))
+	}
+	return int(int64(binary.BigEndian.Uint64(src))), nil
+}
+
+// StringCodec is a Codec for string keys.
+// Keys are encoded as their raw bytes and hashed with FNV-1a.
+type StringCodec struct{}
+
+// Less returns true if a < b.
+func (StringCodec) Less(a, b string) bool { return a < b }
+
+// Equal returns true if a == b.
+func (StringCodec) Equal(a, b string) bool { return a == b }
+
+// Hash hashes the key with FNV-1a.
+func (StringCodec) Hash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// AppendKey appends the raw bytes of key to dst.
+func (StringCodec) AppendKey(dst []byte, key string) []byte {
+	return append(dst, key...)
+}
+
+// DecodeKey returns src as a string.
+func (StringCodec) DecodeKey(src []byte) (string, error) {
+	return string(src), nil
+}
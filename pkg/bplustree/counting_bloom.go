@@ -0,0 +1,173 @@
+package bplustree
+
+import (
+	"fmt"
+	"math"
+)
+
+// RemovableBloomFilter is a Bloom filter that can forget keys.
+// The tree removes deleted keys from filters that implement it instead of
+// clearing the filter and recomputing it on the next lookup.
+type RemovableBloomFilter interface {
+	BloomFilterInterface
+
+	// Remove removes a key that was previously added.
+	// Removing a key that was never added may cause false negatives.
+	Remove(key uint64)
+
+	// Capacity returns the number of keys the filter is sized for.
+	// Beyond it the false-positive rate rises above the rate the filter was built for.
+	Capacity() int
+}
+
+// CountingBloomFilter is a Bloom filter that keeps a small counter per position
+// instead of a single bit, so keys can be removed as well as added.
+// Counters saturate at 255; a saturated counter is never decremented again,
+// which keeps the filter free of false negatives at the cost of a few more false positives.
+type CountingBloomFilter struct {
+	counters      []uint8 // The counter array
+	size          int     // Size of the counter array
+	hashFunctions int     // Number of hash functions
+	valid         bool    // Whether the filter is valid
+}
+
+// NewCountingBloomFilter creates a new counting Bloom filter with the given size and number of hash functions.
+// Parameters:
+//   - size: The number of counters. Larger sizes reduce false positives but use more memory.
+//   - hashFunctions: The number of hash functions to use.
+//
+// Returns a new counting Bloom filter initialized to empty (all counters zero).
+// It uses one byte per position, the same as BloomFilter.
+func NewCountingBloomFilter(size int, hashFunctions int) *CountingBloomFilter {
+	return &CountingBloomFilter{
+		counters:      make([]uint8, size),
+		size:          size,
+		hashFunctions: hashFunctions,
+		valid:         false,
+	}
+}
+
+// Add adds a key to the filter by incrementing the counters at its positions.
+// Time complexity: O(k) where k is the number of hash functions.
+func (bf *CountingBloomFilter) Add(key uint64) {
+	position, step := filterProbe(key, bf.size)
+	for i := 0; i < bf.hashFunctions; i++ {
+		// Saturate rather than wrap around to zero
+		if bf.counters[position] < math.MaxUint8 {
+			bf.counters[position]++
+		}
+		position = nextProbe(position, step, bf.size)
+	}
+}
+
+// Remove removes a key from the filter by decrementing the counters at its positions.
+// Saturated counters are left alone, since the number of keys behind them is unknown.
+// Time complexity: O(k) where k is the number of hash functions.
+func (bf *CountingBloomFilter) Remove(key uint64) {
+	position, step := filterProbe(key, bf.size)
+	for i := 0; i < bf.hashFunctions; i++ {
+		if bf.counters[position] > 0 && bf.counters[position] < math.MaxUint8 {
+			bf.counters[position]--
+		}
+		position = nextProbe(position, step, bf.size)
+	}
+}
+
+// MarshalBinary encodes the filter's size, number of hash functions, validity and counters.
+// Time complexity: O(m) where m is the number of counters.
+func (bf *CountingBloomFilter) MarshalBinary() ([]byte, error) {
+	data := appendFilterHeader(nil, bf.size, bf.hashFunctions, bf.valid)
+	return append(data, bf.counters...), nil
+}
+
+// UnmarshalBinary replaces the filter with one encoded by MarshalBinary.
+// Malformed data is reported as ErrCorrupt.
+// Time complexity: O(m) where m is the number of counters.
+func (bf *CountingBloomFilter) UnmarshalBinary(data []byte) error {
+	size, hashFunctions, valid, counters, err := readFilterHeader(data)
+	if err != nil {
+		return err
+	}
+	if len(counters) != size {
+		return fmt.Errorf("%w: counting bloom filter has %d counters, expected %d", ErrCorrupt, len(counters), size)
+	}
+
+	*bf = CountingBloomFilter{
+		counters:      append([]uint8(nil), counters...),
+		size:          size,
+		hashFunctions: hashFunctions,
+		valid:         valid,
+	}
+	return nil
+}
+
+// Union adds every key of other to the filter by adding their counters, saturating at 255.
+// Both filters must have the same size and number of hash functions, otherwise
+// ErrFilterMismatch is returned and the filter is unchanged.
+// The result is valid only if both filters were valid.
+// Time complexity: O(m) where m is the number of counters.
+func (bf *CountingBloomFilter) Union(other *CountingBloomFilter) error {
+	if bf.size != other.size || bf.hashFunctions != other.hashFunctions {
+		return ErrFilterMismatch
+	}
+	for i, counter := range other.counters {
+		bf.counters[i] = uint8(min(int(bf.counters[i])+int(counter), math.MaxUint8))
+	}
+	bf.valid = bf.valid && other.valid
+	return nil
+}
+
+// Capacity returns the number of keys for which the filter's size and number of
+// hash functions are optimal, the inverse of OptimalBloomFilterSize.
+// Time complexity: O(1)
+func (bf *CountingBloomFilter) Capacity() int {
+	return int(float64(bf.size) * math.Ln2 / float64(bf.hashFunctions))
+}
+
+// EstimatedFalsePositiveRate estimates the filter's current false-positive rate
+// from the fraction of counters that are non-zero.
+// Time complexity: O(m) where m is the number of counters.
+func (bf *CountingBloomFilter) EstimatedFalsePositiveRate() float64 {
+	set := 0
+	for _, counter := range bf.counters {
+		if counter > 0 {
+			set++
+		}
+	}
+	return math.Pow(float64(set)/float64(bf.size), float64(bf.hashFunctions))
+}
+
+// Contains returns true if the key might be in the set, false if it's definitely not in the set.
+// Time complexity: O(k) where k is the number of hash functions.
+func (bf *CountingBloomFilter) Contains(key uint64) bool {
+	position, step := filterProbe(key, bf.size)
+	for i := 0; i < bf.hashFunctions; i++ {
+		// If any counter is zero, the key is definitely not in the set
+		if bf.counters[position] == 0 {
+			return false
+		}
+		position = nextProbe(position, step, bf.size)
+	}
+
+	// All counters are set, so the key might be in the set
+	return true
+}
+
+// Clear resets all counters to zero and marks the filter as invalid.
+// Time complexity: O(m) where m is the number of counters.
+func (bf *CountingBloomFilter) Clear() {
+	clear(bf.counters)
+	bf.valid = false
+}
+
+// SetValid marks the filter as valid.
+// Time complexity: O(1)
+func (bf *CountingBloomFilter) SetValid() {
+	bf.valid = true
+}
+
+// IsValid returns true if the filter is valid.
+// Time complexity: O(1)
+func (bf *CountingBloomFilter) IsValid() bool {
+	return bf.valid
+}
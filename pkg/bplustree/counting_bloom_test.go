@@ -0,0 +1,118 @@
+package bplustree
+
+import (
+	"testing"
+)
+
+// TestCountingBloomFilterRemove tests that removed keys are forgotten and others are kept
+func TestCountingBloomFilterRemove(t *testing.T) {
+	filter := NewCountingBloomFilter(10000, 4)
+
+	for key := uint64(0); key < 500; key++ {
+		filter.Add(key)
+	}
+	for key := uint64(0); key < 500; key += 2 {
+		filter.Remove(key)
+	}
+
+	// No false negatives for the keys that remain
+	for key := uint64(1); key < 500; key += 2 {
+		if !filter.Contains(key) {
+			t.Errorf("Expected filter to contain %d", key)
+		}
+	}
+
+	// Most removed keys must now be reported as absent
+	present := 0
+	for key := uint64(0); key < 500; key += 2 {
+		if filter.Contains(key) {
+			present++
+		}
+	}
+	if present > 25 {
+		t.Errorf("Expected most removed keys to be absent, %d of 250 still present", present)
+	}
+}
+
+// TestCountingBloomFilterSaturation tests that saturated counters never cause false negatives
+func TestCountingBloomFilterSaturation(t *testing.T) {
+	filter := NewCountingBloomFilter(1, 1)
+
+	// Every key shares the single counter
+	for key := uint64(0); key < 300; key++ {
+		filter.Add(key)
+	}
+	for key := uint64(0); key < 299; key++ {
+		filter.Remove(key)
+	}
+	if !filter.Contains(299) {
+		t.Errorf("Expected a saturated counter to keep reporting the remaining key")
+	}
+}
+
+// TestCountingBloomFilterFalsePositiveRates tests that double hashing keeps the target rates
+func TestCountingBloomFilterFalsePositiveRates(t *testing.T) {
+	const n = 100000
+	const queries = 200000
+
+	for _, target := range []float64{0.1, 0.01, 0.001} {
+		size, hashFunctions := OptimalBloomFilterSize(n, target)
+		filter := NewCountingBloomFilter(size, hashFunctions)
+		rate := measureFalsePositiveRate(t, filter, n, queries)
+		t.Logf("Target %.4f: counting bloom %.5f, estimated %.5f", target, rate, filter.EstimatedFalsePositiveRate())
+
+		if rate > target*1.5 {
+			t.Errorf("Counting bloom filter rate %.5f is above the target %.4f", rate, target)
+		}
+	}
+}
+
+// TestTreeDeleteKeepsCountingBloomFilterValid tests that Delete updates the filter instead of clearing it
+func TestTreeDeleteKeepsCountingBloomFilterValid(t *testing.T) {
+	tree := NewGenericBPlusTree[uint64](16,
+		func(a, b uint64) bool { return a < b },
+		func(a, b uint64) bool { return a == b },
+		func(k uint64) uint64 { return k },
+	)
+	for key := uint64(0); key < 1000; key++ {
+		tree.Insert(key)
+	}
+	tree.Contains(0) // Make the filter valid
+
+	for key := uint64(0); key < 1000; key += 3 {
+		if !tree.Delete(key) {
+			t.Fatalf("Expected Delete(%d) to succeed", key)
+		}
+		if !tree.bloomFilter.IsValid() {
+			t.Fatalf("Expected the filter to stay valid after deleting %d", key)
+		}
+	}
+
+	for key := uint64(0); key < 1000; key++ {
+		if got, want := tree.Contains(key), key%3 != 0; got != want {
+			t.Errorf("Contains(%d) = %v, expected %v", key, got, want)
+		}
+	}
+}
+
+// TestTreeDeleteInvalidatesPlainBloomFilter tests that filters without Remove are still cleared on delete
+func TestTreeDeleteInvalidatesPlainBloomFilter(t *testing.T) {
+	tree := NewGenericBPlusTreeWithBloomFilter[uint64](16,
+		func(a, b uint64) bool { return a < b },
+		func(a, b uint64) bool { return a == b },
+		func(k uint64) uint64 { return k },
+		NewBloomFilter(1000, 3),
+	)
+	for key := uint64(0); key < 100; key++ {
+		tree.Insert(key)
+	}
+	tree.Contains(0)
+
+	tree.Delete(50)
+	if tree.bloomFilter.IsValid() {
+		t.Errorf("Expected a plain bloom filter to be invalidated by Delete")
+	}
+	if tree.Contains(50) || !tree.Contains(51) {
+		t.Errorf("Tree has wrong contents after delete")
+	}
+}
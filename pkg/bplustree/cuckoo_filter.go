@@ -0,0 +1,228 @@
+package bplustree
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Cuckoo filter parameters.
+const (
+	cuckooBucketSize = 4   // Fingerprints per bucket
+	cuckooMaxKicks   = 500 // Relocations tried before an insert gives up
+	cuckooLoadFactor = 95  // Percentage of slots that can be filled reliably
+)
+
+// CuckooFilter is a membership filter that stores a 16-bit fingerprint of each key
+// in one of two candidate buckets. Unlike a Bloom filter it supports removal, and at
+// low false-positive rates it needs fewer bits per key: a filter filled to capacity
+// uses about 17 bits per key for a false-positive rate near 0.01%, where a Bloom
+// filter needs about 19. The number of buckets is a power of two, so a filter that
+// is not filled to capacity uses more bits per key.
+//
+// If an insert cannot find room, the filter is marked full and answers "maybe" for
+// every key until it is cleared, so it never produces false negatives.
+type CuckooFilter struct {
+	buckets [][cuckooBucketSize]uint16 // Fingerprints, 0 marks an empty slot
+	mask    uint64                     // Number of buckets minus one
+	count   int                        // Number of fingerprints stored
+	full    bool                       // Whether an insert failed
+	valid   bool                       // Whether the filter is valid
+}
+
+// NewCuckooFilter creates a new cuckoo filter that can hold at least capacity keys.
+func NewCuckooFilter(capacity int) *CuckooFilter {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	// Leave headroom so inserts rarely need long relocation chains
+	slots := (capacity*100 + cuckooLoadFactor - 1) / cuckooLoadFactor
+	numBuckets := (slots + cuckooBucketSize - 1) / cuckooBucketSize
+	numBuckets = 1 << bits.Len(uint(numBuckets-1))
+
+	return &CuckooFilter{
+		buckets: make([][cuckooBucketSize]uint16, numBuckets),
+		mask:    uint64(numBuckets - 1),
+		valid:   false,
+	}
+}
+
+// Add adds a key to the filter.
+// Time complexity: O(1) expected, O(m) worst case where m is the number of relocations.
+func (cf *CuckooFilter) Add(key uint64) {
+	if cf.full {
+		return
+	}
+
+	fp, i1, i2 := cf.locate(key)
+	if cf.insertInto(i1, fp) || cf.insertInto(i2, fp) {
+		cf.count++
+		return
+	}
+
+	// Both buckets are full: evict fingerprints to their alternate buckets
+	index := i1
+	if fp&1 == 1 {
+		index = i2
+	}
+	for kick := 0; kick < cuckooMaxKicks; kick++ {
+		slot := (int(fp) + kick) % cuckooBucketSize
+		fp, cf.buckets[index][slot] = cf.buckets[index][slot], fp
+		index = cf.altIndex(index, fp)
+		if cf.insertInto(index, fp) {
+			cf.count++
+			return
+		}
+	}
+
+	// The evicted fingerprint has nowhere to go
+	cf.full = true
+}
+
+// Remove removes one copy of the key's fingerprint from the filter.
+// It does nothing once the filter is full, since a fingerprint may have been lost.
+// Time complexity: O(1)
+func (cf *CuckooFilter) Remove(key uint64) {
+	if cf.full {
+		return
+	}
+
+	fp, i1, i2 := cf.locate(key)
+	if cf.deleteFrom(i1, fp) || cf.deleteFrom(i2, fp) {
+		cf.count--
+	}
+}
+
+// Contains returns true if the key might be in the set, false if it's definitely not in the set.
+// Time complexity: O(1)
+func (cf *CuckooFilter) Contains(key uint64) bool {
+	if cf.full {
+		return true
+	}
+
+	fp, i1, i2 := cf.locate(key)
+	for _, f := range cf.buckets[i1] {
+		if f == fp {
+			return true
+		}
+	}
+	for _, f := range cf.buckets[i2] {
+		if f == fp {
+			return true
+		}
+	}
+	return false
+}
+
+// Clear removes all fingerprints and marks the filter as invalid.
+// Time complexity: O(m) where m is the number of buckets.
+func (cf *CuckooFilter) Clear() {
+	clear(cf.buckets)
+	cf.count = 0
+	cf.full = false
+	cf.valid = false
+}
+
+// SetValid marks the filter as valid.
+// Time complexity: O(1)
+func (cf *CuckooFilter) SetValid() {
+	cf.valid = true
+}
+
+// IsValid returns true if the filter is valid.
+// Time complexity: O(1)
+func (cf *CuckooFilter) IsValid() bool {
+	return cf.valid
+}
+
+// Capacity returns the number of keys the filter can hold reliably.
+// Time complexity: O(1)
+func (cf *CuckooFilter) Capacity() int {
+	return len(cf.buckets) * cuckooBucketSize * cuckooLoadFactor / 100
+}
+
+// Len returns the number of keys stored in the filter.
+// Time complexity: O(1)
+func (cf *CuckooFilter) Len() int {
+	return cf.count
+}
+
+// EstimatedFalsePositiveRate estimates the filter's current false-positive rate.
+// A lookup compares against every fingerprint in two buckets, and each matches
+// by chance with probability 1/65535. A full filter answers "maybe" to everything.
+// Time complexity: O(1)
+func (cf *CuckooFilter) EstimatedFalsePositiveRate() float64 {
+	if cf.full {
+		return 1
+	}
+	perBucket := float64(cf.count) / float64(len(cf.buckets))
+	return 1 - math.Pow(1-1.0/math.MaxUint16, 2*perBucket)
+}
+
+// IsFull returns true if an insert failed and the filter answers "maybe" for every key.
+// Time complexity: O(1)
+func (cf *CuckooFilter) IsFull() bool {
+	return cf.full
+}
+
+// locate returns the fingerprint of a key and its two candidate buckets.
+// Time complexity: O(1)
+func (cf *CuckooFilter) locate(key uint64) (fp uint16, i1, i2 uint64) {
+	h := mix64(key)
+
+	// Take the fingerprint from the high bits and the bucket from the low bits
+	fp = uint16(h >> 48)
+	if fp == 0 {
+		fp = 1 // 0 marks an empty slot
+	}
+	i1 = h & cf.mask
+	return fp, i1, cf.altIndex(i1, fp)
+}
+
+// altIndex returns the other candidate bucket for a fingerprint stored in bucket index.
+// Applying it twice returns the original bucket.
+// Time complexity: O(1)
+func (cf *CuckooFilter) altIndex(index uint64, fp uint16) uint64 {
+	return (index ^ mix64(uint64(fp))) & cf.mask
+}
+
+// insertInto stores a fingerprint in the first empty slot of a bucket.
+// Returns false if the bucket is full.
+// Time complexity: O(1)
+func (cf *CuckooFilter) insertInto(index uint64, fp uint16) bool {
+	bucket := &cf.buckets[index]
+	for i, f := range bucket {
+		if f == 0 {
+			bucket[i] = fp
+			return true
+		}
+	}
+	return false
+}
+
+// deleteFrom removes one copy of a fingerprint from a bucket.
+// Returns false if the bucket does not hold it.
+// Time complexity: O(1)
+func (cf *CuckooFilter) deleteFrom(index uint64, fp uint16) bool {
+	bucket := &cf.buckets[index]
+	for i, f := range bucket {
+		if f == fp {
+			bucket[i] = 0
+			return true
+		}
+	}
+	return false
+}
+
+// mix64 scrambles the bits of a 64-bit value using the SplitMix64 finalizer.
+// Keys are often small sequential integers hashed by identity, so their
+// bits must be mixed before they can pick buckets and fingerprints.
+// Time complexity: O(1)
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,149 @@
+package bplustree
+
+import (
+	"testing"
+)
+
+// measureFalsePositiveRate adds keys 0..n-1 to the filter and returns the fraction
+// of the keys n..n+queries-1 it reports as present
+func measureFalsePositiveRate(t *testing.T, filter BloomFilterInterface, n, queries int) float64 {
+	t.Helper()
+
+	for key := 0; key < n; key++ {
+		filter.Add(uint64(key))
+	}
+	for key := 0; key < n; key++ {
+		if !filter.Contains(uint64(key)) {
+			t.Fatalf("False negative for key %d", key)
+		}
+	}
+
+	falsePositives := 0
+	for key := n; key < n+queries; key++ {
+		if filter.Contains(uint64(key)) {
+			falsePositives++
+		}
+	}
+	return float64(falsePositives) / float64(queries)
+}
+
+// TestFilterFalsePositiveRates tests the measured false-positive rate of each filter against its target
+func TestFilterFalsePositiveRates(t *testing.T) {
+	const n = 100000
+	const queries = 200000
+
+	for _, target := range []float64{0.1, 0.01, 0.001} {
+		size, hashFunctions := OptimalBloomFilterSize(n, target)
+
+		bloom := measureFalsePositiveRate(t, NewBloomFilter(size, hashFunctions), n, queries)
+		counting := measureFalsePositiveRate(t, NewCountingBloomFilter(size, hashFunctions), n, queries)
+		t.Logf("Target %.4f: bloom %.5f, counting bloom %.5f, %.1f bits/key",
+			target, bloom, counting, float64(size)/n)
+
+		// Allow for sampling noise around the target
+		if bloom > target*1.5 {
+			t.Errorf("Bloom filter rate %.5f is far above the target %.4f", bloom, target)
+		}
+		if counting > target*1.5 {
+			t.Errorf("Counting bloom filter rate %.5f is far above the target %.4f", counting, target)
+		}
+	}
+
+	cuckoo := NewCuckooFilter(n)
+	rate := measureFalsePositiveRate(t, cuckoo, n, queries)
+	t.Logf("Cuckoo: %.5f, %.1f bits/key", rate, float64(len(cuckoo.buckets)*cuckooBucketSize*16)/n)
+
+	// Two buckets of four 16-bit fingerprints give a rate of at most 8/65535
+	if rate > 0.0002 {
+		t.Errorf("Cuckoo filter rate %.5f is above 0.0002", rate)
+	}
+	if cuckoo.IsFull() || cuckoo.Len() != n {
+		t.Errorf("Expected %d keys without overflow, got %d (full=%v)", n, cuckoo.Len(), cuckoo.IsFull())
+	}
+}
+
+// TestCuckooFilterRemove tests that removed keys are forgotten
+func TestCuckooFilterRemove(t *testing.T) {
+	filter := NewCuckooFilter(1000)
+	if filter.Capacity() < 1000 {
+		t.Errorf("Expected capacity of at least 1000, got %d", filter.Capacity())
+	}
+
+	for key := uint64(0); key < 1000; key++ {
+		filter.Add(key)
+	}
+	for key := uint64(0); key < 1000; key += 2 {
+		filter.Remove(key)
+	}
+
+	if filter.Len() != 500 {
+		t.Errorf("Expected 500 keys, got %d", filter.Len())
+	}
+	for key := uint64(1); key < 1000; key += 2 {
+		if !filter.Contains(key) {
+			t.Errorf("Expected filter to contain %d", key)
+		}
+	}
+	present := 0
+	for key := uint64(0); key < 1000; key += 2 {
+		if filter.Contains(key) {
+			present++
+		}
+	}
+	if present > 5 {
+		t.Errorf("Expected removed keys to be absent, %d of 500 still present", present)
+	}
+}
+
+// TestCuckooFilterOverflow tests that an overfilled filter answers "maybe" instead of giving false negatives
+func TestCuckooFilterOverflow(t *testing.T) {
+	filter := NewCuckooFilter(10)
+	for key := uint64(0); key < 100; key++ {
+		filter.Add(key)
+	}
+
+	if !filter.IsFull() {
+		t.Fatalf("Expected the filter to be full")
+	}
+	for key := uint64(0); key < 200; key++ {
+		if !filter.Contains(key) {
+			t.Errorf("Expected a full filter to report %d as maybe present", key)
+		}
+	}
+
+	filter.Clear()
+	if filter.IsFull() || filter.Contains(5) {
+		t.Errorf("Expected Clear to empty the filter")
+	}
+}
+
+// TestTreeWithCuckooFilter tests a tree using a cuckoo filter through inserts and deletes
+func TestTreeWithCuckooFilter(t *testing.T) {
+	tree := NewGenericBPlusTreeWithCuckooFilter[uint64](16,
+		func(a, b uint64) bool { return a < b },
+		func(a, b uint64) bool { return a == b },
+		func(k uint64) uint64 { return k },
+		2000,
+	)
+	for key := uint64(0); key < 1000; key++ {
+		tree.Insert(key)
+	}
+	tree.Contains(0) // Make the filter valid
+
+	for key := uint64(0); key < 1000; key += 2 {
+		tree.Delete(key)
+	}
+	if !tree.bloomFilter.IsValid() {
+		t.Errorf("Expected the cuckoo filter to stay valid after deletes")
+	}
+	for key := uint64(0); key < 1000; key++ {
+		if got, want := tree.Contains(key), key%2 == 1; got != want {
+			t.Errorf("Contains(%d) = %v, expected %v", key, got, want)
+		}
+	}
+
+	tree.ResizeBloomFilter(10000, 0.01)
+	if _, ok := tree.bloomFilter.(*CuckooFilter); !ok {
+		t.Errorf("Expected ResizeBloomFilter to keep the cuckoo filter, got %T", tree.bloomFilter)
+	}
+}
@@ -6,7 +6,7 @@ import (
 )
 
 // PrintTree prints a visual representation of the tree
-func PrintTree[K comparable](t *GenericBPlusTree[K]) string {
+func PrintTree[K any](t *GenericBPlusTree[K]) string {
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("Tree(size=%d, height=%d, branching=%d)\n", t.size, t.Height(), t.branchingFactor))
 	printNode(&sb, t.root, 0)
@@ -14,14 +14,17 @@ func PrintTree[K comparable](t *GenericBPlusTree[K]) string {
 }
 
 // printNode recursively prints a node and its children
-func printNode[K any](sb *strings.Builder, node GenericNode[K], level int) {
+func printNode[K any](sb *strings.Builder, n *node[K], level int) {
 	indent := strings.Repeat("  ", level)
 
-	switch n := node.(type) {
-	case *GenericLeafNode[K]:
+	switch n.kind {
+	case Leaf:
 		sb.WriteString(fmt.Sprintf("%sLeaf: %v\n", indent, n.Keys()))
-	case *GenericBranchNode[K]:
+	case Branch:
 		sb.WriteString(fmt.Sprintf("%sInternal: %v\n", indent, n.Keys()))
+		if n.buffer.len() > 0 {
+			sb.WriteString(fmt.Sprintf("%sPending: %v deletes: %v\n", indent, n.buffer.keys, n.buffer.deletes))
+		}
 		for i, child := range n.Children() {
 			if i > 0 {
 				sb.WriteString(fmt.Sprintf("%s[Key: %v]\n", indent, n.Keys()[i-1]))
@@ -1,6 +1,7 @@
 package bplustree
 
 import (
+	"math/rand"
 	"testing"
 )
 
@@ -193,3 +194,38 @@ func TestDeleteEmptyTree(t *testing.T) {
 		t.Errorf("Expected size 0, got %d", tree.Size())
 	}
 }
+
+// TestDeleteRandomKeys deletes random keys, including keys that are also
+// separators in branch nodes, and checks the tree against a map
+func TestDeleteRandomKeys(t *testing.T) {
+	for _, branchingFactor := range []int{3, 4, 5, 8} {
+		rng := rand.New(rand.NewSource(int64(branchingFactor)))
+		tree := NewBPlusTree(branchingFactor)
+		model := make(map[uint64]bool)
+
+		for i := 0; i < 5000; i++ {
+			key := uint64(rng.Intn(200))
+			if rng.Intn(2) == 0 {
+				if got, want := tree.Insert(key), !model[key]; got != want {
+					t.Fatalf("bf %d: Insert(%d) = %v, want %v", branchingFactor, key, got, want)
+				}
+				model[key] = true
+			} else {
+				if got, want := tree.Delete(key), model[key]; got != want {
+					t.Fatalf("bf %d: Delete(%d) = %v, want %v", branchingFactor, key, got, want)
+				}
+				delete(model, key)
+			}
+
+			if tree.Size() != len(model) {
+				t.Fatalf("bf %d: expected size %d, got %d", branchingFactor, len(model), tree.Size())
+			}
+		}
+
+		for key := uint64(0); key < 200; key++ {
+			if tree.Contains(key) != model[key] {
+				t.Errorf("bf %d: Contains(%d) = %v, want %v", branchingFactor, key, !model[key], model[key])
+			}
+		}
+	}
+}
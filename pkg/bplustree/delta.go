@@ -0,0 +1,299 @@
+package bplustree
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// deltaMagic identifies a delta written by ExportDelta.
+var deltaMagic = [4]byte{'B', 'P', 'T', 'D'}
+
+// deltaVersion is the version of the delta format.
+const deltaVersion = 1
+
+// Flags describing the bounds of a delta range.
+const (
+	deltaHasLow  = 1 << 0 // The range has a lower bound
+	deltaHasHigh = 1 << 1 // The range has an upper bound
+)
+
+// Checkpoint starts a new checkpoint and returns its ID.
+// Leaves whose keys change after this call are reported by
+// ExportDelta(id, ...) until the tree is cleared.
+// Checkpoint IDs start at 1; ID 0 stands for the empty initial state.
+// Time complexity: O(1)
+func (t *GenericBPlusTree[K]) Checkpoint() uint64 {
+	t.checkpoint++
+	return t.checkpoint
+}
+
+// markModified records that the keys of leaf changed during the current checkpoint.
+// Time complexity: O(1)
+func (t *GenericBPlusTree[K]) markModified(leaf *node[K]) {
+	leaf.modified = t.checkpoint
+}
+
+// deltaRange is a run of consecutive leaves changed since a checkpoint.
+// It covers every key strictly between low and high, where a missing bound
+// means the range extends to the end of the key space.
+type deltaRange[K any] struct {
+	low, high       K
+	hasLow, hasHigh bool
+	leaves          []*node[K]
+}
+
+// ExportDelta writes the changes made since the checkpoint since to w.
+//
+// Only leaves whose keys changed since that checkpoint are written. Runs of
+// consecutive changed leaves are written as one range, bounded by the keys of
+// the unchanged leaves on either side, together with every key the range now
+// holds. Applying the delta with ApplyDelta to a tree that matched this tree at
+// the checkpoint makes it match this tree again. ExportDelta(0, ...) writes the
+// whole tree.
+//
+// Time complexity: O(L + d) where L is the number of leaves and d is the
+// number of keys in changed leaves.
+func (t *GenericBPlusTree[K]) ExportDelta(since uint64, w io.Writer, codec Codec[K]) error {
+	// Pending messages of a buffered tree are changes too
+	t.Flush()
+	ranges := t.changedRanges(since)
+
+	bw := bufio.NewWriter(w)
+	buf := append([]byte{}, deltaMagic[:]...)
+	buf = append(buf, deltaVersion)
+	buf = binary.AppendUvarint(buf, uint64(len(ranges)))
+
+	for _, r := range ranges {
+		var flags byte
+		if r.hasLow {
+			flags |= deltaHasLow
+		}
+		if r.hasHigh {
+			flags |= deltaHasHigh
+		}
+		buf = append(buf, flags)
+		if r.hasLow {
+			buf = appendDeltaKey(buf, codec, r.low)
+		}
+		if r.hasHigh {
+			buf = appendDeltaKey(buf, codec, r.high)
+		}
+
+		count := 0
+		for _, leaf := range r.leaves {
+			count += len(leaf.keys)
+		}
+		buf = binary.AppendUvarint(buf, uint64(count))
+		for _, leaf := range r.leaves {
+			for _, key := range leaf.keys {
+				buf = appendDeltaKey(buf, codec, key)
+			}
+			if _, err := bw.Write(buf); err != nil {
+				return err
+			}
+			buf = buf[:0]
+		}
+	}
+
+	if _, err := bw.Write(buf); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// changedRanges walks the leaf chain and groups leaves changed since the
+// checkpoint into ranges. Unchanged empty leaves hold no keys and so cannot
+// bound a range; they are skipped.
+func (t *GenericBPlusTree[K]) changedRanges(since uint64) []deltaRange[K] {
+	var ranges []deltaRange[K]
+	var current *deltaRange[K]
+
+	var lastKey K
+	haveLastKey := false
+
+	for leaf := t.firstLeaf(); leaf != nil; leaf = leaf.next {
+		if leaf.modified >= since {
+			if current == nil {
+				current = &deltaRange[K]{low: lastKey, hasLow: haveLastKey}
+			}
+			current.leaves = append(current.leaves, leaf)
+			continue
+		}
+		if len(leaf.keys) == 0 {
+			continue
+		}
+
+		if current != nil {
+			current.high, current.hasHigh = leaf.keys[0], true
+			ranges = append(ranges, *current)
+			current = nil
+		}
+		lastKey, haveLastKey = leaf.keys[len(leaf.keys)-1], true
+	}
+
+	if current != nil {
+		ranges = append(ranges, *current)
+	}
+	return ranges
+}
+
+// firstLeaf returns the leftmost leaf of the tree.
+// Time complexity: O(log n) where n is the number of keys in the tree.
+func (t *GenericBPlusTree[K]) firstLeaf() *node[K] {
+	n := t.root
+	for !n.IsLeaf() {
+		if len(n.children) == 0 {
+			return nil
+		}
+		n = n.children[0]
+	}
+	return n
+}
+
+// ApplyDelta applies a delta written by ExportDelta to the tree.
+//
+// For every range in the delta, keys of this tree inside the range that are
+// not in the delta are deleted and keys in the delta that are missing are
+// inserted. A malformed delta is reported as ErrCorrupt; ranges before the
+// damaged one have already been applied.
+//
+// Time complexity: O(d log n) where d is the number of keys in the delta
+// and the affected ranges of this tree.
+func (t *GenericBPlusTree[K]) ApplyDelta(r io.Reader, codec Codec[K]) error {
+	br := bufio.NewReader(r)
+
+	var magic [4]byte
+	if _, err := io.ReadFull(br, magic[:]); err != nil || magic != deltaMagic {
+		return fmt.Errorf("%w: bad delta magic", ErrCorrupt)
+	}
+	version, err := br.ReadByte()
+	if err != nil || version != deltaVersion {
+		return fmt.Errorf("%w: unsupported delta version", ErrCorrupt)
+	}
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return fmt.Errorf("%w: bad delta header", ErrCorrupt)
+	}
+
+	for i := uint64(0); i < count; i++ {
+		if err := t.applyDeltaRange(br, codec); err != nil {
+			return fmt.Errorf("%w: delta range %d: %v", ErrCorrupt, i, err)
+		}
+	}
+	return nil
+}
+
+// applyDeltaRange reads one range and makes the tree's keys inside it match.
+func (t *GenericBPlusTree[K]) applyDeltaRange(r *bufio.Reader, codec Codec[K]) error {
+	flags, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	var low, high K
+	hasLow, hasHigh := flags&deltaHasLow != 0, flags&deltaHasHigh != 0
+	if hasLow {
+		if low, err = readDeltaKey(r, codec); err != nil {
+			return err
+		}
+	}
+	if hasHigh {
+		if high, err = readDeltaKey(r, codec); err != nil {
+			return err
+		}
+	}
+
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	keys := make([]K, 0, min(count, 1<<16))
+	for i := uint64(0); i < count; i++ {
+		key, err := readDeltaKey(r, codec)
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 && t.compare(keys[len(keys)-1], key) >= 0 {
+			return errors.New("keys out of order")
+		}
+		keys = append(keys, key)
+	}
+
+	// Both lists are sorted, so a single merge pass finds the differences
+	existing := t.keysBetween(low, hasLow, high, hasHigh)
+	i, j := 0, 0
+	for i < len(existing) || j < len(keys) {
+		switch {
+		case j == len(keys) || (i < len(existing) && t.compare(existing[i], keys[j]) < 0):
+			t.Delete(existing[i])
+			i++
+		case i == len(existing) || t.compare(keys[j], existing[i]) < 0:
+			t.Insert(keys[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return nil
+}
+
+// keysBetween returns the keys strictly between low and high in sorted order.
+// A missing bound means the range is unbounded on that side.
+// Time complexity: O(log n + k) where k is the number of keys returned.
+func (t *GenericBPlusTree[K]) keysBetween(low K, hasLow bool, high K, hasHigh bool) []K {
+	t.Flush()
+	leaf := t.firstLeaf()
+	if hasLow {
+		leaf = t.findLeafNode(t.root, low)
+	}
+
+	var result []K
+	for ; leaf != nil; leaf = leaf.next {
+		for _, key := range leaf.keys {
+			if hasLow && t.compare(low, key) >= 0 {
+				continue
+			}
+			if hasHigh && t.compare(key, high) >= 0 {
+				return result
+			}
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+// appendDeltaKey appends a length-prefixed key encoding to dst.
+func appendDeltaKey[K any](dst []byte, codec Codec[K], key K) []byte {
+	start := len(dst)
+	dst = codec.AppendKey(dst, key)
+	encoded := len(dst) - start
+
+	// Move the encoding right to make room for its length prefix
+	var prefix [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(prefix[:], uint64(encoded))
+	dst = append(dst, prefix[:n]...)
+	copy(dst[start+n:], dst[start:start+encoded])
+	copy(dst[start:], prefix[:n])
+	return dst
+}
+
+// readDeltaKey reads a length-prefixed key encoding.
+func readDeltaKey[K any](r *bufio.Reader, codec Codec[K]) (K, error) {
+	var zero K
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return zero, err
+	}
+	if n > 1<<30 {
+		return zero, errors.New("key too large")
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return zero, err
+	}
+	return codec.DecodeKey(buf)
+}
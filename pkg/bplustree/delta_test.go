@@ -0,0 +1,168 @@
+package bplustree
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// newDeltaTestTree creates a uint64 tree for the delta tests
+func newDeltaTestTree(branchingFactor int) *GenericBPlusTree[uint64] {
+	codec := Uint64Codec{}
+	return NewGenericBPlusTree[uint64](branchingFactor, codec.Less, codec.Equal, codec.Hash)
+}
+
+// shipDelta exports the changes since a checkpoint from primary and applies them to replica
+func shipDelta(t *testing.T, primary, replica *GenericBPlusTree[uint64], since uint64) int {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := primary.ExportDelta(since, &buf, Uint64Codec{}); err != nil {
+		t.Fatalf("ExportDelta failed: %v", err)
+	}
+	size := buf.Len()
+	if err := replica.ApplyDelta(&buf, Uint64Codec{}); err != nil {
+		t.Fatalf("ApplyDelta failed: %v", err)
+	}
+	return size
+}
+
+// TestDeltaReplication tests that shipping deltas keeps a replica identical to the primary
+func TestDeltaReplication(t *testing.T) {
+	for _, bf := range []int{4, 8, 32} {
+		primary := newDeltaTestTree(bf)
+		replica := newDeltaTestTree(bf)
+		rng := rand.New(rand.NewSource(int64(bf)))
+
+		since := primary.Checkpoint()
+		for round := 0; round < 50; round++ {
+			// Mix inserts and deletes so leaves split, borrow and merge
+			for i := 0; i < 40; i++ {
+				key := uint64(rng.Intn(2000))
+				if rng.Intn(3) == 0 {
+					primary.Delete(key)
+				} else {
+					primary.Insert(key)
+				}
+			}
+
+			next := primary.Checkpoint()
+			shipDelta(t, primary, replica, since)
+			since = next
+
+			want := primary.RangeQuery(0, 2000)
+			if got := replica.RangeQuery(0, 2000); !slices.Equal(got, want) {
+				t.Fatalf("bf=%d round %d: replica has %d keys, primary has %d", bf, round, len(got), len(want))
+			}
+			checkTreeInvariants(t, replica)
+		}
+	}
+}
+
+// TestDeltaOnlyWritesChangedLeaves tests that a small change produces a small delta
+func TestDeltaOnlyWritesChangedLeaves(t *testing.T) {
+	primary := newDeltaTestTree(16)
+	for i := uint64(0); i < 10000; i++ {
+		primary.Insert(i * 2)
+	}
+	replica := newDeltaTestTree(16)
+	full := shipDelta(t, primary, replica, 0)
+
+	since := primary.Checkpoint()
+	primary.Insert(5001)
+	primary.Delete(8000)
+
+	partial := shipDelta(t, primary, replica, since)
+	if partial*50 > full {
+		t.Errorf("Expected a delta much smaller than the full export (%d bytes), got %d bytes", full, partial)
+	}
+	if !replica.Contains(5001) || replica.Contains(8000) || replica.Size() != primary.Size() {
+		t.Errorf("Replica does not match the primary after applying the delta")
+	}
+
+	// Nothing changed since the latest checkpoint
+	since = primary.Checkpoint()
+	var buf bytes.Buffer
+	primary.ExportDelta(since, &buf, Uint64Codec{})
+	if buf.Len() != 6 {
+		t.Errorf("Expected an empty delta of 6 bytes, got %d", buf.Len())
+	}
+}
+
+// TestDeltaAfterClear tests that clearing the primary clears the replica
+func TestDeltaAfterClear(t *testing.T) {
+	primary := newDeltaTestTree(4)
+	replica := newDeltaTestTree(4)
+	for i := uint64(0); i < 100; i++ {
+		primary.Insert(i)
+	}
+	shipDelta(t, primary, replica, 0)
+
+	since := primary.Checkpoint()
+	primary.Clear()
+	primary.Insert(7)
+	shipDelta(t, primary, replica, since)
+
+	if got := replica.RangeQuery(0, 100); !slices.Equal(got, []uint64{7}) {
+		t.Errorf("Expected replica to hold only 7, got %v", got)
+	}
+}
+
+// TestDeltaCorruption tests that malformed deltas are rejected with ErrCorrupt
+func TestDeltaCorruption(t *testing.T) {
+	primary := newDeltaTestTree(4)
+	for i := uint64(0); i < 20; i++ {
+		primary.Insert(i)
+	}
+	var buf bytes.Buffer
+	primary.ExportDelta(0, &buf, Uint64Codec{})
+	data := buf.Bytes()
+
+	cases := map[string][]byte{
+		"truncated": data[:len(data)-3],
+		"bad magic": append([]byte("XXXX"), data[4:]...),
+		"empty":     nil,
+	}
+	for name, damaged := range cases {
+		t.Run(name, func(t *testing.T) {
+			replica := newDeltaTestTree(4)
+			if err := replica.ApplyDelta(bytes.NewReader(damaged), Uint64Codec{}); !errors.Is(err, ErrCorrupt) {
+				t.Errorf("Expected ErrCorrupt, got %v", err)
+			}
+		})
+	}
+}
+
+// TestDeleteKeepsTreeValid tests random inserts and deletes against a map
+func TestDeleteKeepsTreeValid(t *testing.T) {
+	for _, bf := range []int{4, 8, 16} {
+		tree := newDeltaTestTree(bf)
+		want := make(map[uint64]bool)
+		rng := rand.New(rand.NewSource(int64(bf)))
+
+		for i := 0; i < 5000; i++ {
+			key := uint64(rng.Intn(500))
+			if rng.Intn(2) == 0 {
+				if got := tree.Delete(key); got != want[key] {
+					t.Fatalf("bf=%d: Delete(%d) returned %v, expected %v", bf, key, got, want[key])
+				}
+				delete(want, key)
+			} else {
+				tree.Insert(key)
+				want[key] = true
+			}
+		}
+
+		checkTreeInvariants(t, tree)
+		if tree.Size() != len(want) {
+			t.Errorf("bf=%d: expected size %d, got %d", bf, len(want), tree.Size())
+		}
+		for key := range want {
+			if !tree.Contains(key) {
+				t.Errorf("bf=%d: expected key %d", bf, key)
+			}
+		}
+	}
+}
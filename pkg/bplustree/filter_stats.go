@@ -0,0 +1,127 @@
+package bplustree
+
+// FilterStats counts how the tree's bloom filter answered lookups in Contains and Delete.
+// Lookups answered by a NullBloomFilter, including while the adaptive mode has
+// disabled the filter, are not counted.
+type FilterStats struct {
+	Negatives      uint64 // Lookups the filter answered "definitely absent", skipping the tree
+	Positives      uint64 // Lookups the filter answered "maybe present"
+	FalsePositives uint64 // Positives for keys the tree did not hold
+	Disabled       bool   // Whether the adaptive mode has currently disabled the filter
+}
+
+// NegativeRate returns the fraction of counted lookups the filter answered "definitely absent".
+// Returns 0 if no lookups were counted.
+// Time complexity: O(1)
+func (s FilterStats) NegativeRate() float64 {
+	total := s.Negatives + s.Positives
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Negatives) / float64(total)
+}
+
+// adaptiveFilter is the state of the adaptive filter mode, see SetAdaptiveFilter.
+type adaptiveFilter struct {
+	threshold float64              // Minimum fraction of lookups the filter must rule out
+	window    uint64               // Lookups per decision
+	lookups   uint64               // Lookups in the current window
+	absent    uint64               // Lookups in the current window a filter could rule out
+	saved     BloomFilterInterface // The real filter while it is disabled, nil otherwise
+}
+
+// FilterStats returns the counts of bloom filter answers since the tree was
+// created or the counts were last reset.
+// Time complexity: O(1)
+func (t *GenericBPlusTree[K]) FilterStats() FilterStats {
+	stats := t.filterStats
+	stats.Disabled = t.adaptive != nil && t.adaptive.saved != nil
+	return stats
+}
+
+// ResetFilterStats sets the counts of bloom filter answers to zero.
+// Time complexity: O(1)
+func (t *GenericBPlusTree[K]) ResetFilterStats() {
+	t.filterStats = FilterStats{}
+}
+
+// SetAdaptiveFilter makes the tree switch its bloom filter off when it does not pay off.
+//
+// The tree looks at lookups in windows of window lookups. If the filter rules
+// out less than threshold of the lookups in a window, it is replaced by a
+// NullBloomFilter, and lookups go straight to the tree. While it is disabled,
+// the tree keeps counting lookups for keys it does not hold; once those make
+// up at least threshold of a window, the filter is restored and rebuilt from
+// the tree on the next lookup.
+//
+// A threshold of 0 or less turns the adaptive mode off and restores a disabled filter.
+// Time complexity: O(1)
+func (t *GenericBPlusTree[K]) SetAdaptiveFilter(threshold float64, window int) {
+	t.restoreAdaptiveFilter()
+	if threshold <= 0 {
+		t.adaptive = nil
+		return
+	}
+
+	if window < 1 {
+		window = 1
+	}
+	t.adaptive = &adaptiveFilter{threshold: threshold, window: uint64(window)}
+}
+
+// recordLookup counts a lookup in the filter statistics and the adaptive mode.
+// excluded is whether the filter ruled the key out and found is whether the tree holds the key.
+// Time complexity: O(1), plus O(1) amortized for switching the filter.
+func (t *GenericBPlusTree[K]) recordLookup(excluded, found bool) {
+	_, disabled := t.bloomFilter.(*NullBloomFilter)
+	if !disabled {
+		switch {
+		case excluded:
+			t.filterStats.Negatives++
+		case found:
+			t.filterStats.Positives++
+		default:
+			t.filterStats.Positives++
+			t.filterStats.FalsePositives++
+		}
+	}
+
+	a := t.adaptive
+	if a == nil {
+		return
+	}
+
+	// A disabled filter would have ruled out the lookups for absent keys
+	a.lookups++
+	if excluded || (a.saved != nil && !found) {
+		a.absent++
+	}
+	if a.lookups < a.window {
+		return
+	}
+
+	rate := float64(a.absent) / float64(a.lookups)
+	a.lookups, a.absent = 0, 0
+	switch {
+	case a.saved == nil && !disabled && rate < a.threshold:
+		a.saved = t.bloomFilter
+		t.bloomFilter = NewNullBloomFilter()
+	case a.saved != nil && rate >= a.threshold:
+		t.restoreAdaptiveFilter()
+	}
+}
+
+// restoreAdaptiveFilter puts back a filter disabled by the adaptive mode.
+// The filter missed the changes made while it was disabled, so it is cleared
+// and rebuilt by the next lookup.
+// Time complexity: O(m) where m is the size of the filter.
+func (t *GenericBPlusTree[K]) restoreAdaptiveFilter() {
+	if t.adaptive == nil || t.adaptive.saved == nil {
+		return
+	}
+
+	t.bloomFilter = t.adaptive.saved
+	t.adaptive.saved = nil
+	t.growBloomFilter()
+	t.bloomFilter.Clear()
+}
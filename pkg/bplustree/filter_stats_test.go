@@ -0,0 +1,93 @@
+package bplustree
+
+import (
+	"testing"
+)
+
+// TestFilterStats tests that Contains and Delete count the filter's answers
+func TestFilterStats(t *testing.T) {
+	tree := NewBPlusTree(16)
+	for key := uint64(0); key < 1000; key++ {
+		tree.Insert(key)
+	}
+
+	for key := uint64(0); key < 2000; key++ {
+		tree.Contains(key)
+	}
+	tree.Delete(5)
+	tree.Delete(5000)
+
+	stats := tree.FilterStats()
+	if stats.Negatives+stats.Positives != 2002 {
+		t.Errorf("Expected 2002 counted lookups, got %d", stats.Negatives+stats.Positives)
+	}
+	if stats.Positives-stats.FalsePositives != 1001 {
+		t.Errorf("Expected 1001 true positives, got %d", stats.Positives-stats.FalsePositives)
+	}
+	if stats.Negatives < 900 || stats.Disabled {
+		t.Errorf("Expected most absent keys to be ruled out by an enabled filter, got %+v", stats)
+	}
+	if rate := stats.NegativeRate(); rate < 0.45 || rate > 0.5 {
+		t.Errorf("Expected a negative rate just under 0.5, got %f", rate)
+	}
+
+	tree.ResetFilterStats()
+	if tree.FilterStats() != (FilterStats{}) {
+		t.Errorf("Expected zero stats after reset, got %+v", tree.FilterStats())
+	}
+
+	// Trees without a filter count nothing
+	plain := NewBPlusTreeWithOptions(16, false)
+	plain.Insert(1)
+	plain.Contains(2)
+	if plain.FilterStats() != (FilterStats{}) {
+		t.Errorf("Expected no stats for a NullBloomFilter, got %+v", plain.FilterStats())
+	}
+}
+
+// TestAdaptiveFilter tests that the filter is disabled for hit-heavy lookups and restored for misses
+func TestAdaptiveFilter(t *testing.T) {
+	tree := NewBPlusTree(16)
+	for key := uint64(0); key < 1000; key++ {
+		tree.Insert(key)
+	}
+	tree.SetAdaptiveFilter(0.2, 100)
+
+	// Only present keys: the filter never rules anything out
+	for key := uint64(0); key < 100; key++ {
+		tree.Contains(key)
+	}
+	if !tree.FilterStats().Disabled {
+		t.Fatalf("Expected the filter to be disabled")
+	}
+	if _, ok := tree.bloomFilter.(*NullBloomFilter); !ok {
+		t.Fatalf("Expected a NullBloomFilter, got %T", tree.bloomFilter)
+	}
+
+	// Changes made while disabled are picked up when the filter is rebuilt
+	tree.Insert(5000)
+	tree.Delete(0)
+
+	// Mostly absent keys: the filter is worth having again
+	for key := uint64(10000); key < 10100; key++ {
+		tree.Contains(key)
+	}
+	if tree.FilterStats().Disabled {
+		t.Fatalf("Expected the filter to be enabled again")
+	}
+	if _, ok := tree.bloomFilter.(*CountingBloomFilter); !ok {
+		t.Fatalf("Expected the counting bloom filter back, got %T", tree.bloomFilter)
+	}
+	if !tree.Contains(5000) || tree.Contains(0) || !tree.Contains(999) {
+		t.Errorf("Rebuilt filter gives wrong answers")
+	}
+
+	// Turning the mode off restores a disabled filter
+	for key := uint64(1); key < 101; key++ {
+		tree.Contains(key)
+	}
+	tree.SetAdaptiveFilter(0, 0)
+	if tree.FilterStats().Disabled {
+		t.Errorf("Expected the filter to be restored when the adaptive mode is turned off")
+	}
+}
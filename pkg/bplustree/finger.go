@@ -0,0 +1,118 @@
+package bplustree
+
+// FingerStats counts how the finger served Contains and Insert, see SetFinger.
+type FingerStats struct {
+	Hits         uint64 // Operations served by the leaf the finger pointed at
+	NeighborHits uint64 // Operations served by the leaf after it
+	Misses       uint64 // Operations that descended from the root
+}
+
+// HitRate returns the fraction of counted operations that did not descend from the root.
+// Returns 0 if no operations were counted.
+// Time complexity: O(1)
+func (s FingerStats) HitRate() float64 {
+	total := s.Hits + s.NeighborHits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits+s.NeighborHits) / float64(total)
+}
+
+// fingerState is the state of the finger, see SetFinger.
+type fingerState[K any] struct {
+	leaf  *node[K]    // Leaf touched last, nil until the next descent
+	stats FingerStats // Counts of finger hits and misses
+}
+
+// SetFinger turns the finger on or off.
+//
+// The finger remembers the leaf touched last by Contains or Insert. The next
+// of these operations checks the key against that leaf's first and last keys,
+// and then against the next leaf in the chain, before it descends from the root.
+// Lookups that cluster around the previous one then cost one or two leaf
+// searches. Turning the finger off also drops its statistics.
+// Time complexity: O(1)
+func (t *GenericBPlusTree[K]) SetFinger(enabled bool) {
+	if !enabled {
+		t.finger = nil
+		return
+	}
+	if t.finger == nil {
+		t.finger = &fingerState[K]{}
+	}
+}
+
+// FingerStats returns the counts of finger hits and misses since the finger was
+// turned on or the counts were last reset. Returns zero counts if the finger is off.
+// Time complexity: O(1)
+func (t *GenericBPlusTree[K]) FingerStats() FingerStats {
+	if t.finger == nil {
+		return FingerStats{}
+	}
+	return t.finger.stats
+}
+
+// ResetFingerStats sets the counts of finger hits and misses to zero.
+// Time complexity: O(1)
+func (t *GenericBPlusTree[K]) ResetFingerStats() {
+	if t.finger != nil {
+		t.finger.stats = FingerStats{}
+	}
+}
+
+// fingerLeaf returns the leaf that should contain key if the finger can tell
+// without a descent, and nil otherwise. The answer is counted in the statistics.
+//
+// A key between the first and last keys of a leaf belongs to that leaf. A key
+// between the last key of the finger leaf and the first key of the next one is
+// in neither; Contains may look it up in either leaf, but Insert must descend,
+// because only the separator in the parent tells which leaf it belongs to.
+// Insert also descends to a full leaf, because splitting it needs the path from the root.
+// Time complexity: O(1), plus up to four comparisons.
+func (t *GenericBPlusTree[K]) fingerLeaf(key K, forInsert bool) *node[K] {
+	f := t.finger
+	if f == nil {
+		return nil
+	}
+
+	if leaf := f.leaf; leaf != nil && len(leaf.keys) > 0 && t.compare(key, leaf.keys[0]) >= 0 {
+		if t.compare(key, leaf.keys[len(leaf.keys)-1]) <= 0 {
+			if !forInsert || !leaf.IsFull(t.branchingFactor) {
+				f.stats.Hits++
+				return leaf
+			}
+		} else if next := leaf.next; next != nil && len(next.keys) > 0 &&
+			t.compare(key, next.keys[len(next.keys)-1]) <= 0 &&
+			(!forInsert || t.compare(key, next.keys[0]) >= 0 && !next.IsFull(t.branchingFactor)) {
+			// The key is past the finger leaf but within the next one
+			f.stats.NeighborHits++
+			f.leaf = next
+			return next
+		}
+	}
+
+	f.stats.Misses++
+	return nil
+}
+
+// moveFinger points the finger at leaf, if the finger is on.
+// Time complexity: O(1)
+func (t *GenericBPlusTree[K]) moveFinger(leaf *node[K]) {
+	if t.finger != nil {
+		t.finger.leaf = leaf
+	}
+}
+
+// removeLeaf drops a leaf that a merge emptied into survivor from the tree's
+// hints, moving the rightmost leaf and the finger to survivor, and returns it
+// to the pool.
+// Time complexity: O(B) where B is the branching factor.
+func (t *GenericBPlusTree[K]) removeLeaf(removed, survivor *node[K]) {
+	if removed == t.rightmost {
+		t.rightmost = survivor
+	}
+	if t.finger != nil && t.finger.leaf == removed {
+		t.finger.leaf = survivor
+	}
+	t.pool.free(removed)
+}
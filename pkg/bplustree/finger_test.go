@@ -0,0 +1,109 @@
+package bplustree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestFingerClusteredLookups tests that lookups of consecutive keys are served
+// by the finger leaf or the one after it
+func TestFingerClusteredLookups(t *testing.T) {
+	tree := NewBPlusTreeWithOptions(16, false)
+	r := rand.New(rand.NewSource(1))
+	for _, i := range r.Perm(10000) {
+		tree.Insert(uint64(i))
+	}
+	tree.SetFinger(true)
+
+	for i := 0; i < 10000; i++ {
+		if !tree.Contains(uint64(i)) {
+			t.Fatalf("Expected tree to contain %d", i)
+		}
+	}
+	if tree.Contains(10000) {
+		t.Errorf("Expected tree not to contain 10000")
+	}
+
+	stats := tree.FingerStats()
+	if stats.Misses > 2 || stats.NeighborHits == 0 {
+		t.Errorf("Expected sequential lookups to miss at most twice, got %+v", stats)
+	}
+	if rate := stats.HitRate(); rate < 0.99 {
+		t.Errorf("Expected a hit rate of at least 0.99, got %.3f", rate)
+	}
+
+	tree.ResetFingerStats()
+	if stats := tree.FingerStats(); stats != (FingerStats{}) {
+		t.Errorf("Expected zero stats after reset, got %+v", stats)
+	}
+	tree.SetFinger(false)
+	tree.Contains(42)
+	if stats := tree.FingerStats(); stats != (FingerStats{}) {
+		t.Errorf("Expected no stats with the finger off, got %+v", stats)
+	}
+}
+
+// TestFingerInsertBetweenLeaves tests that an insert between the last key of
+// the finger leaf and the first key of the next leaf descends to the right leaf
+func TestFingerInsertBetweenLeaves(t *testing.T) {
+	tree := NewBPlusTreeWithOptions(4, false)
+	for _, key := range []uint64{10, 20, 30, 40, 50, 60} {
+		tree.Insert(key)
+	}
+	tree.SetFinger(true)
+	tree.Contains(10)
+
+	// 25 and 35 fall between leaves, 45 into one, wherever the separators are
+	for _, key := range []uint64{25, 35, 45} {
+		tree.Insert(key)
+		checkTreeInvariants(t, tree)
+	}
+	for _, key := range []uint64{10, 25, 35, 45, 60} {
+		if !tree.Contains(key) {
+			t.Errorf("Expected tree to contain %d", key)
+		}
+	}
+}
+
+// TestFingerFollowsSplitsAndMerges tests that the finger stays on a leaf of the
+// tree while clustered inserts split leaves and deletes merge them
+func TestFingerFollowsSplitsAndMerges(t *testing.T) {
+	tree := NewBPlusTreeWithOptions(5, false)
+	tree.SetFinger(true)
+	present := make(map[uint64]bool)
+	r := rand.New(rand.NewSource(2))
+
+	center := 0
+	for i := 0; i < 20000; i++ {
+		// Keys cluster around a center that wanders slowly
+		if r.Intn(100) == 0 {
+			center = r.Intn(2000)
+		}
+		key := uint64(center + r.Intn(40))
+
+		switch r.Intn(3) {
+		case 0:
+			if tree.Insert(key) == present[key] {
+				t.Fatalf("Expected Insert(%d) to return %v", key, !present[key])
+			}
+			present[key] = true
+		case 1:
+			if tree.Delete(key) != present[key] {
+				t.Fatalf("Expected Delete(%d) to return %v", key, present[key])
+			}
+			delete(present, key)
+		default:
+			if tree.Contains(key) != present[key] {
+				t.Fatalf("Expected Contains(%d) to return %v", key, present[key])
+			}
+		}
+		if i%200 == 0 {
+			checkTreeInvariants(t, tree)
+		}
+	}
+	checkTreeInvariants(t, tree)
+
+	if stats := tree.FingerStats(); stats.Hits == 0 || stats.NeighborHits == 0 {
+		t.Errorf("Expected clustered operations to hit the finger, got %+v", stats)
+	}
+}
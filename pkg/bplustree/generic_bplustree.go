@@ -2,33 +2,54 @@
 //
 // A B+ tree is a self-balancing tree data structure that maintains sorted data
 // and allows searches, sequential access, insertions, and deletions in logarithmic time.
-// This implementation is generic and can work with any comparable type.
+// This implementation is generic and can work with any key type that can be ordered,
+// including non-comparable types such as []byte.
 //
 // The B+ tree is optimized with a bloom filter for faster lookups of non-existent keys.
 package bplustree
 
 import (
 	"fmt"
+	"slices"
 )
 
-// GenericBPlusTree is a B+ tree that works with any comparable type.
+// GenericBPlusTree is a B+ tree that works with any key type.
 // It provides efficient operations for inserting, deleting, and querying keys.
 // The tree is self-balancing and maintains its height automatically.
 //
 // The generic parameter K represents the type of keys stored in the tree.
-// The tree requires three functions to work with the keys:
-// - less: a function that returns true if a < b
-// - equal: a function that returns true if a == b
+// The tree requires two functions to work with the keys:
+// - compare: a function that returns a negative number, zero or a positive number if a < b, a == b or a > b
 // - hashFunc: a function that converts a key to a uint64 for bloom filter usage
-type GenericBPlusTree[K comparable] struct {
-	root            GenericNode[K]       // Root node of the tree
-	branchingFactor int                  // Maximum number of children per node
-	height          int                  // Current height of the tree
-	size            int                  // Number of keys in the tree
-	less            func(a, b K) bool    // Function to compare keys (a < b)
-	equal           func(a, b K) bool    // Function to check equality of keys (a == b)
-	hashFunc        func(K) uint64       // Function to hash keys for bloom filter
-	bloomFilter     BloomFilterInterface // Bloom filter for faster lookups
+//
+// Constructors that take separate less and equal functions adapt them into a compare function.
+type GenericBPlusTree[K any] struct {
+	root               *node[K]             // Root node of the tree
+	branchingFactor    int                  // Maximum number of children per node
+	height             int                  // Current height of the tree
+	size               int                  // Number of keys in the tree
+	compare            func(a, b K) int     // Function to order keys, like cmp.Compare
+	search             searchFunc[K]        // Binary search inside nodes, consistent with compare
+	savedSearch        searchFunc[K]        // Search replaced by interpolation search, nil unless enabled
+	naturalOrder       bool                 // Whether compare is cmp.Compare, see NewOrderedTree
+	hashFunc           func(K) uint64       // Function to hash keys for bloom filter
+	bloomFilter        BloomFilterInterface // Bloom filter for faster lookups
+	bloomFPRate        float64              // Target false-positive rate when the bloom filter grows
+	rangeFilter        RangeFilter[K]       // Optional filter for empty ranges, see SetRangeFilter
+	rangeFilterDeletes int                  // Keys deleted since the range filter was filled
+	filterStats        FilterStats          // Counts of bloom filter answers, see FilterStats
+	adaptive           *adaptiveFilter      // Adaptive filter state, nil unless enabled
+	checkpoint         uint64               // ID of the latest checkpoint, see Checkpoint
+	path               treePath[K]          // Reusable root-to-leaf path of inserts and deletes
+	pool               nodePool[K]          // Allocates nodes and recycles those removed from the tree
+	rightmost          *node[K]             // Last leaf, where appends go without a descent
+	splitPolicy        SplitPolicy          // How overflowing nodes are divided, see SetSplitPolicy
+	insertRun          int                  // Latest inserts in a row in one direction, see recordInsert
+	lastInsert         K                    // Key of the latest insert, for insertRun
+	finger             *fingerState[K]      // Finger state, nil unless enabled, see SetFinger
+	bufferSize         int                  // Messages a branch holds before it is flushed, 0 unless buffered
+	pending            messageBuffer[K]     // Messages that reached the leaves, waiting to be applied
+	scratch            messageBuffer[K]     // Reusable space for merging buffers
 }
 
 // NewGenericBPlusTree creates a new generic B+ tree with the specified parameters.
@@ -39,31 +60,101 @@ type GenericBPlusTree[K comparable] struct {
 //   - equal: A function that returns true if a == b for keys of type K.
 //   - hashFunc: A function that converts a key of type K to a uint64 for bloom filter usage.
 //
-// Returns a new empty B+ tree with a bloom filter enabled for faster lookups.
-func NewGenericBPlusTree[K comparable](
+// Returns a new empty B+ tree with a counting bloom filter enabled for faster lookups.
+func NewGenericBPlusTree[K any](
 	branchingFactor int,
 	less func(a, b K) bool,
 	equal func(a, b K) bool,
 	hashFunc func(K) uint64,
+) *GenericBPlusTree[K] {
+	return NewGenericBPlusTreeWithCompare(branchingFactor, compareFromLessEqual(less, equal), hashFunc)
+}
+
+// NewGenericBPlusTreeWithCompare creates a new generic B+ tree ordered by a three-way comparison.
+// A single compare function cannot disagree with itself about equality the way a
+// separate less and equal pair can, and it answers each comparison in one call.
+//
+// Parameters:
+//   - branchingFactor: The maximum number of children per node. Must be at least 3.
+//   - compare: A function that returns a negative number if a < b, zero if a == b
+//     and a positive number if a > b, like cmp.Compare.
+//   - hashFunc: A function that converts a key of type K to a uint64 for bloom filter usage.
+//     Keys that compare equal must have the same hash.
+//
+// Returns a new empty B+ tree with a counting bloom filter enabled for faster lookups.
+func NewGenericBPlusTreeWithCompare[K any](
+	branchingFactor int,
+	compare func(a, b K) int,
+	hashFunc func(K) uint64,
 ) *GenericBPlusTree[K] {
 	if branchingFactor < 3 {
 		branchingFactor = 3 // Minimum branching factor
 	}
 
 	// Create a Bloom filter with reasonable default parameters
-	// Initial size is set for 1000 elements with 1% false positive rate
-	bloomSize, hashFunctions := OptimalBloomFilterSize(1000, 0.01)
+	// Initial size is set for 1000 elements with 1% false positive rate,
+	// and it grows with the tree, see growBloomFilter
+	bloomSize, hashFunctions := OptimalBloomFilterSize(defaultExpectedElements, defaultFalsePositiveRate)
 
-	return &GenericBPlusTree[K]{
-		root:            NewGenericLeafNode[K](),
+	t := &GenericBPlusTree[K]{
 		branchingFactor: branchingFactor,
 		height:          1,
 		size:            0,
-		less:            less,
-		equal:           equal,
+		compare:         compare,
+		search:          compareSearch(compare),
 		hashFunc:        hashFunc,
-		bloomFilter:     NewBloomFilter(bloomSize, hashFunctions),
+		bloomFilter:     NewCountingBloomFilter(bloomSize, hashFunctions),
+		bloomFPRate:     defaultFalsePositiveRate,
+		pool:            newNodePool[K](branchingFactor),
 	}
+	t.root = t.pool.newLeaf()
+	t.rightmost = t.root
+	return t
+}
+
+// NewGenericBPlusTreeWithBloomFilter creates a new generic B+ tree that uses the given filter.
+// Filters that implement RemovableBloomFilter are updated on delete; any other filter
+// is cleared on delete and recomputed on the next lookup.
+//
+// Parameters:
+//   - branchingFactor: The maximum number of children per node. Must be at least 3.
+//   - less: A function that returns true if a < b for keys of type K.
+//   - equal: A function that returns true if a == b for keys of type K.
+//   - hashFunc: A function that converts a key of type K to a uint64 for bloom filter usage.
+//   - filter: The filter to use for lookups of non-existent keys.
+//
+// Returns a new empty B+ tree using the filter.
+func NewGenericBPlusTreeWithBloomFilter[K any](
+	branchingFactor int,
+	less func(a, b K) bool,
+	equal func(a, b K) bool,
+	hashFunc func(K) uint64,
+	filter BloomFilterInterface,
+) *GenericBPlusTree[K] {
+	tree := NewGenericBPlusTreeWithoutBloom(branchingFactor, less, equal, hashFunc)
+	tree.bloomFilter = filter
+	return tree
+}
+
+// NewGenericBPlusTreeWithCuckooFilter creates a new generic B+ tree that uses a cuckoo filter
+// instead of a bloom filter for lookups of non-existent keys.
+//
+// Parameters:
+//   - branchingFactor: The maximum number of children per node. Must be at least 3.
+//   - less: A function that returns true if a < b for keys of type K.
+//   - equal: A function that returns true if a == b for keys of type K.
+//   - hashFunc: A function that converts a key of type K to a uint64 for filter usage.
+//   - capacity: The number of keys the filter should hold.
+//
+// Returns a new empty B+ tree with a cuckoo filter.
+func NewGenericBPlusTreeWithCuckooFilter[K any](
+	branchingFactor int,
+	less func(a, b K) bool,
+	equal func(a, b K) bool,
+	hashFunc func(K) uint64,
+	capacity int,
+) *GenericBPlusTree[K] {
+	return NewGenericBPlusTreeWithBloomFilter(branchingFactor, less, equal, hashFunc, NewCuckooFilter(capacity))
 }
 
 // NewGenericBPlusTreeWithoutBloom creates a new generic B+ tree without a bloom filter.
@@ -76,29 +167,35 @@ func NewGenericBPlusTree[K comparable](
 //   - hashFunc: A function that converts a key of type K to a uint64 (not used without bloom filter).
 //
 // Returns a new empty B+ tree with no bloom filter.
-func NewGenericBPlusTreeWithoutBloom[K comparable](
+func NewGenericBPlusTreeWithoutBloom[K any](
 	branchingFactor int,
 	less func(a, b K) bool,
 	equal func(a, b K) bool,
 	hashFunc func(K) uint64,
 ) *GenericBPlusTree[K] {
-	if branchingFactor < 3 {
-		branchingFactor = 3 // Minimum branching factor
-	}
+	tree := NewGenericBPlusTreeWithCompare(branchingFactor, compareFromLessEqual(less, equal), hashFunc)
+	tree.bloomFilter = NewNullBloomFilter() // Use null bloom filter (always returns "maybe")
+	return tree
+}
 
-	return &GenericBPlusTree[K]{
-		root:            NewGenericLeafNode[K](),
-		branchingFactor: branchingFactor,
-		height:          1,
-		size:            0,
-		less:            less,
-		equal:           equal,
-		hashFunc:        hashFunc,
-		bloomFilter:     NewNullBloomFilter(), // Use null bloom filter (always returns "maybe")
+// compareFromLessEqual adapts a less and an equal function into a compare function.
+// Keys that are neither less nor equal are taken to be greater.
+// Time complexity: O(1) calls to less and equal per comparison.
+func compareFromLessEqual[K any](less, equal func(a, b K) bool) func(a, b K) int {
+	return func(a, b K) int {
+		switch {
+		case less(a, b):
+			return -1
+		case equal(a, b):
+			return 0
+		default:
+			return 1
+		}
 	}
 }
 
 // Size returns the number of keys in the tree.
+// The keys of pending inserts and deletes in a buffered tree are counted too.
 // Time complexity: O(1)
 func (t *GenericBPlusTree[K]) Size() int {
 	return t.size
@@ -126,26 +223,81 @@ func (t *GenericBPlusTree[K]) BranchingFactor() int {
 
 // Insert inserts a key into the tree.
 // Returns true if the key was inserted, false if it already existed.
+// A buffered tree queues the insert of a missing key, see NewBufferedTree.
 // Time complexity: O(log n) where n is the number of keys in the tree.
 func (t *GenericBPlusTree[K]) Insert(key K) bool {
-	// If the root is full, split it before inserting
-	if t.root.IsFull(t.branchingFactor) {
-		t.splitRoot()
+	if t.buffered() {
+		// Queue the insert at the root; the leaf changes when it gets there
+		if t.mayContainBuffered(key) {
+			return false
+		}
+		t.recordInsert(key)
+		t.bufferMessage(key, false)
+	} else if t.appendsToRightmost(key) {
+		// Append to the rightmost leaf without descending the tree,
+		// and only walk down its side of the tree if it needs to be split
+		leaf := t.rightmost
+		leaf.keys = append(leaf.keys, key)
+		t.markModified(leaf)
+		t.recordInsert(key)
+		if leaf.overflows(t.branchingFactor) {
+			t.splitOverflowing(t.descendRightmost())
+		}
+		t.moveFinger(t.rightmost)
+	} else if leaf := t.fingerLeaf(key, true); leaf != nil {
+		// Insert into the finger leaf, which has room for the key
+		if !leaf.InsertKey(key, t.search) {
+			return false
+		}
+		t.markModified(leaf)
+		t.recordInsert(key)
+	} else {
+		// Find the leaf that should contain the key, remembering the way down
+		path := t.descend(key)
+		if path == nil {
+			return false
+		}
+		t.moveFinger(path.leaf)
+		if !path.leaf.InsertKey(key, t.search) {
+			return false
+		}
+		t.markModified(path.leaf)
+		t.recordInsert(key)
+
+		// Split the leaf, and the branches above it, if they overflow
+		t.splitOverflowing(path)
 	}
 
-	// Insert the key into the tree
-	inserted := t.insertNonFull(t.root, key)
+	// Update size and bloom filter
+	t.size++
+	t.updateBloomFilter(key)
+	t.growBloomFilter()
+	t.updateRangeFilter(key)
+	t.growRangeFilter()
 
-	// Update size and bloom filter if the key was inserted
-	if inserted {
-		t.size++
-		t.updateBloomFilter(key)
-	}
+	return true
+}
 
-	return inserted
+// splitOverflowing splits the leaf at the end of path if it holds more keys than
+// allowed after an insert. Each split adds a key to the parent, which may
+// overflow in turn, so it walks up the path until a node has room.
+// Time complexity: O(B log n) where B is the branching factor and n is the number of keys in the tree.
+func (t *GenericBPlusTree[K]) splitOverflowing(path *treePath[K]) {
+	n := path.leaf
+	for n.overflows(t.branchingFactor) {
+		frame, ok := path.pop()
+		if !ok {
+			// The root overflows and gets a new root above it
+			t.splitRoot()
+			return
+		}
+
+		t.splitChild(frame.node, frame.index)
+		n = frame.node
+	}
 }
 
-// splitRoot handles splitting the root when it's full.
+// splitRoot handles splitting the root when it overflows.
 // This increases the height of the tree by 1.
 // Time complexity: O(B) where B is the branching factor.
 func (t *GenericBPlusTree[K]) splitRoot() {
@@ -153,8 +305,8 @@ func (t *GenericBPlusTree[K]) splitRoot() {
 	oldRoot := t.root
 
 	// Create a new root as a branch node
-	t.root = NewGenericBranchNode[K]()
-	newRoot := t.root.(*GenericBranchNode[K])
+	newRoot := t.pool.newBranch()
+	t.root = newRoot
 
 	// Make the old root the first child of the new root
 	newRoot.SetChild(0, oldRoot)
@@ -183,70 +335,22 @@ func (t *GenericBPlusTree[K]) updateBloomFilter(key K) {
 	}
 }
 
-// insertNonFull inserts a key into a non-full node.
-// Returns true if the key was inserted, false if it already existed.
-// Time complexity: O(log n) where n is the number of keys in the tree.
-func (t *GenericBPlusTree[K]) insertNonFull(node GenericNode[K], key K) bool {
-	switch n := node.(type) {
-	case *GenericLeafNode[K]:
-		// If we've reached a leaf node, insert the key
-		return n.InsertKey(key, t.less)
-
-	case *GenericBranchNode[K]:
-		// Find the child that should contain the key
-		childIndex := n.FindChildIndex(key, t.less)
-
-		// Safety check to avoid index out of range
-		if childIndex >= len(n.Children()) {
-			return false
-		}
-
-		child := n.Children()[childIndex]
-
-		// If the child is full, split it before inserting
-		if child.IsFull(t.branchingFactor) {
-			t.splitChild(n, childIndex)
-
-			// After splitting, determine which child to go to
-			// If the key is greater than or equal to the new separator key,
-			// we need to go to the right child (childIndex + 1)
-			if childIndex < len(n.Keys()) && (t.less(n.Keys()[childIndex], key) || t.equal(n.Keys()[childIndex], key)) {
-				childIndex++
-			}
-
-			// Safety check again after potential increment
-			if childIndex >= len(n.Children()) {
-				return false
-			}
-
-			// Get the new child
-			child = n.Children()[childIndex]
-		}
-
-		// Recursively insert into the child
-		return t.insertNonFull(child, key)
-	}
-
-	// This should never happen if the tree is properly structured
-	return false
-}
-
-// splitChild splits a full child of a branch node.
+// splitChild splits an overflowing child of a branch node into two halves.
 // This is a key operation in maintaining the B+ tree property.
 // Time complexity: O(B) where B is the branching factor.
-func (t *GenericBPlusTree[K]) splitChild(parent *GenericBranchNode[K], childIndex int) {
+func (t *GenericBPlusTree[K]) splitChild(parent *node[K], childIndex int) {
 	// Get the child to split
 	child := parent.Children()[childIndex]
 
-	switch c := child.(type) {
-	case *GenericBranchNode[K]:
+	switch c := child; c.kind {
+	case Branch:
 		// Split branch node (internal node)
 
 		// Create a new branch node for the right half
-		newChildImpl := NewGenericBranchNode[K]()
+		newChildImpl := t.pool.newBranch()
 
-		// Calculate the middle index
-		midIndex := t.branchingFactor/2 - 1
+		// Calculate the split index, see SetSplitPolicy
+		midIndex := t.splitIndex(len(c.Keys()), false)
 
 		// Get the middle key that will move up to the parent
 		midKey := c.Keys()[midIndex]
@@ -256,42 +360,45 @@ func (t *GenericBPlusTree[K]) splitChild(parent *GenericBranchNode[K], childInde
 		newChildImpl.children = append(newChildImpl.children, c.Children()[midIndex+1:]...)
 
 		// Update the original child (left half)
-		c.keys = c.Keys()[:midIndex]
-		c.children = c.Children()[:midIndex+1]
+		c.keys = truncate(c.keys, midIndex)
+		c.children = truncate(c.children, midIndex+1)
+
+		// Pending messages for the right half move with it
+		t.moveMessagesRight(c, newChildImpl, midKey)
 
 		// Insert the new child into the parent
-		parent.InsertKeyWithChild(midKey, newChildImpl, t.less)
+		parent.InsertKeyWithChild(midKey, newChildImpl, t.search)
 
-	case *GenericLeafNode[K]:
+	case Leaf:
 		// Split leaf node
 
 		// Create a new leaf node for the right half
-		newLeafImpl := NewGenericLeafNode[K]()
+		newLeafImpl := t.pool.newLeaf()
 
-		// Calculate the middle index
-		// For leaf nodes, we include the middle key in the right node
-		midIndex := t.branchingFactor / 2
+		// Calculate the split index, see SetSplitPolicy
+		// For leaf nodes, we include the key at the split index in the right node
+		midIndex := t.splitIndex(len(c.Keys()), true)
 
 		// Move keys to the new leaf (right half)
 		newLeafImpl.keys = append(newLeafImpl.keys, c.Keys()[midIndex:]...)
 
 		// Update the original leaf (left half)
-		c.keys = c.Keys()[:midIndex]
+		c.keys = truncate(c.keys, midIndex)
 
 		// Update the linked list of leaves for range queries
 		newLeafImpl.next = c.next
 		c.next = newLeafImpl
+		if c == t.rightmost {
+			t.rightmost = newLeafImpl
+		}
+
+		// Both halves hold a different set of keys than before
+		t.markModified(c)
+		t.markModified(newLeafImpl)
 
 		// Insert the new leaf into the parent
 		// Use the first key of the new leaf as the separator key
-		if len(newLeafImpl.Keys()) > 0 {
-			parent.InsertKeyWithChild(newLeafImpl.Keys()[0], newLeafImpl, t.less)
-		} else {
-			// This should not happen in a properly structured tree
-			// But handle it gracefully just in case
-			var zeroKey K
-			parent.InsertKeyWithChild(zeroKey, newLeafImpl, t.less)
-		}
+		parent.InsertKeyWithChild(newLeafImpl.Keys()[0], newLeafImpl, t.search)
 	}
 
 	// Note: We don't update the height here.
@@ -321,12 +428,27 @@ func (t *GenericBPlusTree[K]) Contains(key K) bool {
 	// Early return if bloom filter says key is definitely not present
 	// This is a key optimization for lookups of non-existent keys
 	if !t.bloomFilter.Contains(hash) {
+		t.recordLookup(true, false)
 		return false
 	}
 
 	// Check the tree since bloom filter says key might be present
 	// (bloom filters can have false positives but not false negatives)
-	return t.findLeaf(t.root, key)
+	if t.buffered() {
+		found := t.containsBuffered(key)
+		t.recordLookup(false, found)
+		return found
+	}
+
+	// Start at the finger, if it is on and knows the leaf
+	leaf := t.fingerLeaf(key, false)
+	if leaf == nil {
+		leaf = t.findLeafNode(t.root, key)
+		t.moveFinger(leaf)
+	}
+	found := leaf != nil && leaf.Contains(key, t.search)
+	t.recordLookup(false, found)
+	return found
 }
 
 // recomputeBloomFilter recomputes the Bloom filter from all keys in the tree.
@@ -345,15 +467,22 @@ func (t *GenericBPlusTree[K]) recomputeBloomFilter() {
 
 // addKeysToBloomFilter adds all keys in the subtree rooted at node to the Bloom filter.
 // Time complexity: O(n) where n is the number of keys in the subtree.
-func (t *GenericBPlusTree[K]) addKeysToBloomFilter(node GenericNode[K]) {
-	switch n := node.(type) {
-	case *GenericLeafNode[K]:
+func (t *GenericBPlusTree[K]) addKeysToBloomFilter(n *node[K]) {
+	switch n.kind {
+	case Leaf:
 		// Add all keys in the leaf node to the Bloom filter
 		for _, key := range n.Keys() {
 			hash := t.hashFunc(key)
 			t.bloomFilter.Add(hash)
 		}
-	case *GenericBranchNode[K]:
+	case Branch:
+		// Add the keys of buffered inserts, which are not in the leaves yet
+		for i, key := range n.buffer.keys {
+			if !n.buffer.deletes[i] {
+				t.bloomFilter.Add(t.hashFunc(key))
+			}
+		}
+
 		// Recursively add keys from all children
 		for _, child := range n.Children() {
 			t.addKeysToBloomFilter(child)
@@ -362,78 +491,118 @@ func (t *GenericBPlusTree[K]) addKeysToBloomFilter(node GenericNode[K]) {
 }
 
 // ResizeBloomFilter resizes the bloom filter with new parameters.
-// This can be useful when the tree has grown significantly and the
-// current bloom filter parameters are no longer optimal.
+// The filter also grows by itself as the tree grows, see growBloomFilter; this is
+// useful to size it ahead of a known number of keys or to change the
+// false-positive rate, which is kept for later growth.
+// A tree using a BloomFilter, BlockedBloomFilter or CuckooFilter keeps its kind of filter;
+// any other tree gets a CountingBloomFilter. The false-positive rate of a cuckoo
+// filter is fixed by its fingerprint size, so only expectedElements applies to it.
 // Time complexity: O(n) where n is the number of keys in the tree.
 func (t *GenericBPlusTree[K]) ResizeBloomFilter(expectedElements int, falsePositiveRate float64) {
-	// Calculate optimal bloom filter parameters
-	size, hashFunctions := OptimalBloomFilterSize(expectedElements, falsePositiveRate)
+	// A filter disabled by the adaptive mode is enabled again
+	t.restoreAdaptiveFilter()
 
-	// Create a new bloom filter with the new parameters
-	t.bloomFilter = NewBloomFilter(size, hashFunctions)
+	if falsePositiveRate > 0 && falsePositiveRate < 1 {
+		t.bloomFPRate = falsePositiveRate
+	}
+
+	t.bloomFilter = t.newBloomFilterLike(expectedElements)
 
 	// Recompute the bloom filter with all keys in the tree
 	t.recomputeBloomFilter()
 }
 
-// findLeaf finds the leaf node that should contain the key and checks if it's present.
-// Time complexity: O(log n) where n is the number of keys in the tree.
-func (t *GenericBPlusTree[K]) findLeaf(node GenericNode[K], key K) bool {
-	switch n := node.(type) {
-	case *GenericLeafNode[K]:
-		// We've reached a leaf node, check if it contains the key
-		for _, k := range n.Keys() {
-			if t.equal(k, key) {
-				return true
-			}
-		}
-		return false
+// growBloomFilter replaces the bloom filter with one sized for twice the current
+// number of keys once the tree holds more keys than the filter was sized for.
+// The new filter is filled by the next lookup, so, as the tree doubles between
+// resizes, the cost is O(1) amortized per insert.
+// Filters of kinds the tree does not know how to rebuild are left alone.
+// Time complexity: O(1) amortized
+func (t *GenericBPlusTree[K]) growBloomFilter() {
+	var capacity int
+	switch f := t.bloomFilter.(type) {
+	case *BloomFilter:
+		capacity = f.Capacity()
+	case *CountingBloomFilter:
+		capacity = f.Capacity()
+	case *BlockedBloomFilter:
+		capacity = f.Capacity()
+	case *CuckooFilter:
+		capacity = f.Capacity()
+	default:
+		return
+	}
 
-	case *GenericBranchNode[K]:
-		// Find the child that should contain the key
-		childIndex := n.FindChildIndex(key, t.less)
+	if t.size > capacity {
+		t.bloomFilter = t.newBloomFilterLike(2 * t.size)
+	}
+}
 
-		// Safety check to avoid index out of range
-		if childIndex >= len(n.Children()) {
-			return false
-		}
+// newBloomFilterLike creates an empty filter of the same kind as the tree's
+// filter, sized for expectedElements at the tree's target false-positive rate.
+// Time complexity: O(m) where m is the size of the new filter.
+func (t *GenericBPlusTree[K]) newBloomFilterLike(expectedElements int) BloomFilterInterface {
+	size, hashFunctions := OptimalBloomFilterSize(expectedElements, t.bloomFPRate)
+
+	// Keep a plain, blocked or cuckoo filter if the tree has one, otherwise use a counting filter
+	switch t.bloomFilter.(type) {
+	case *BloomFilter:
+		return NewBloomFilter(size, hashFunctions)
+	case *BlockedBloomFilter:
+		return NewBlockedBloomFilter(size, hashFunctions)
+	case *CuckooFilter:
+		return NewCuckooFilter(expectedElements)
+	default:
+		return NewCountingBloomFilter(size, hashFunctions)
+	}
+}
 
-		// Recursively search in the appropriate child
-		return t.findLeaf(n.Children()[childIndex], key)
+// EstimatedFalsePositiveRate estimates the probability that the bloom filter
+// answers "maybe" for a key that is not in the tree, given the keys it holds now.
+// Filters that cannot estimate their rate, such as NullBloomFilter, report 1.
+// Time complexity: O(m) where m is the size of the filter, plus O(n) if the
+// filter has to be recomputed first.
+func (t *GenericBPlusTree[K]) EstimatedFalsePositiveRate() float64 {
+	if !t.bloomFilter.IsValid() {
+		t.recomputeBloomFilter()
 	}
 
-	// This should never happen if the tree is properly structured
-	return false
+	if estimator, ok := t.bloomFilter.(interface{ EstimatedFalsePositiveRate() float64 }); ok {
+		return estimator.EstimatedFalsePositiveRate()
+	}
+	return 1
 }
 
-// findLeafNode finds and returns the leaf node that should contain the key.
-// This is used for operations that need to modify the leaf, like range queries.
+// findLeaf finds the leaf node that should contain the key and checks if it's present.
 // Time complexity: O(log n) where n is the number of keys in the tree.
-func (t *GenericBPlusTree[K]) findLeafNode(node GenericNode[K], key K) *GenericLeafNode[K] {
-	switch n := node.(type) {
-	case *GenericLeafNode[K]:
-		// We've reached a leaf node, return it
-		return n
+func (t *GenericBPlusTree[K]) findLeaf(n *node[K], key K) bool {
+	leaf := t.findLeafNode(n, key)
+	return leaf != nil && leaf.Contains(key, t.search)
+}
 
-	case *GenericBranchNode[K]:
+// findLeafNode finds and returns the leaf node below n that should contain the key.
+// This is used for operations that need to modify the leaf, like range queries.
+// Unlike descend, it does not record the way down.
+// Time complexity: O(log n) where n is the number of keys in the tree.
+func (t *GenericBPlusTree[K]) findLeafNode(n *node[K], key K) *node[K] {
+	for n.kind == Branch {
 		// Find the child that should contain the key
-		childIndex := n.FindChildIndex(key, t.less)
+		childIndex := n.FindChildIndex(key, t.search)
 
 		// Safety check to avoid index out of range
 		if childIndex >= len(n.Children()) {
 			return nil
 		}
 
-		// Recursively search in the appropriate child
-		return t.findLeafNode(n.Children()[childIndex], key)
+		n = n.Children()[childIndex]
 	}
-
-	// This should never happen if the tree is properly structured
-	return nil
+	return n
 }
 
 // Delete removes a key from the tree.
 // Returns true if the key was deleted, false if it didn't exist.
+// A buffered tree queues the delete without looking the key up and returns
+// true, unless the bloom filter rules the key out.
 // Time complexity: O(log n) where n is the number of keys in the tree.
 func (t *GenericBPlusTree[K]) Delete(key K) bool {
 	// Special case for empty tree
@@ -443,22 +612,46 @@ func (t *GenericBPlusTree[K]) Delete(key K) bool {
 
 	// First, check if the key exists using the bloom filter
 	// This is an optimization to avoid the deletion process for non-existent keys
-	if t.bloomFilter.IsValid() {
+	consulted := t.bloomFilter.IsValid()
+	if consulted {
 		hash := t.hashFunc(key)
 		if !t.bloomFilter.Contains(hash) {
 			// If the bloom filter says the key is definitely not present, return false
+			t.recordLookup(true, false)
 			return false
 		}
 	}
 
-	// Delete the key and balance the tree if necessary
-	deleted, _ := t.deleteAndBalance(t.root, nil, -1, key)
+	if t.buffered() {
+		// Queue the delete at the root; the leaf changes when it gets there
+		found := t.containsBuffered(key)
+		if consulted {
+			t.recordLookup(false, found)
+		}
+		if found {
+			t.bufferMessage(key, true)
+			t.decrementSize()
+			t.removeFromBloomFilter(key)
+			t.recordRangeFilterDelete()
+		}
+		return found
+	}
+
+	// Delete the key from its leaf, remembering the way down
+	path := t.descend(key)
+	deleted := path != nil && path.leaf.DeleteKey(key, t.search)
+	if consulted {
+		t.recordLookup(false, deleted)
+	}
 
 	if deleted {
-		// Update tree state after successful deletion
+		// Balance the tree bottom-up and update tree state after successful deletion
+		t.markModified(path.leaf)
+		t.rebalance(path)
 		t.decrementSize()
 		t.handleRootUnderflow()
-		t.invalidateBloomFilter()
+		t.removeFromBloomFilter(key)
+		t.recordRangeFilterDelete()
 	}
 
 	return deleted
@@ -485,203 +678,74 @@ func (t *GenericBPlusTree[K]) handleRootUnderflow() {
 // isEmptyInternalRoot returns true if the root is an internal node with no keys.
 // Time complexity: O(1)
 func (t *GenericBPlusTree[K]) isEmptyInternalRoot() bool {
-	if branch, ok := t.root.(*GenericBranchNode[K]); ok {
-		return len(branch.Keys()) == 0 && len(branch.Children()) > 0
-	}
-	return false
+	return !t.root.IsLeaf() && len(t.root.keys) == 0 && len(t.root.children) > 0
 }
 
 // promoteOnlyChild makes the only child of the root the new root.
 // This decreases the height of the tree by 1.
 // Time complexity: O(1)
 func (t *GenericBPlusTree[K]) promoteOnlyChild() {
-	if branch, ok := t.root.(*GenericBranchNode[K]); ok {
-		if len(branch.Children()) > 0 {
-			t.root = branch.Children()[0]
-			t.height--
-		}
+	if !t.root.IsLeaf() && len(t.root.children) > 0 {
+		oldRoot := t.root
+		t.root = oldRoot.children[0]
+		t.height--
+		t.inheritMessages(oldRoot, t.root)
+		t.pool.free(oldRoot)
 	}
 }
 
 // invalidateBloomFilter clears the bloom filter.
-// This is called after a key is deleted, as the bloom filter
+// This is called after a key is deleted when the bloom filter
 // cannot efficiently remove elements.
 // Time complexity: O(1)
 func (t *GenericBPlusTree[K]) invalidateBloomFilter() {
 	t.bloomFilter.Clear()
 }
 
-// deleteAndBalance removes a key from a node and balances the tree if necessary.
-// This is the core deletion algorithm for the B+ tree.
-//
-// Parameters:
-// - node: The current node being processed
-// - parent: The parent of the current node (nil for root)
-// - parentChildIndex: The index of the current node in its parent's children array (-1 for root)
-// - key: The key to delete
-//
-// Returns:
-// - deleted: true if the key was deleted
-// - keyToReplaceInParent: a key that needs to be replaced in the parent (for internal nodes)
-//
-// Time complexity: O(log n) where n is the number of keys in the tree.
-func (t *GenericBPlusTree[K]) deleteAndBalance(node GenericNode[K], parent *GenericBranchNode[K], parentChildIndex int, key K) (bool, K) {
-	var zeroKey K // Zero value of K
-
-	switch n := node.(type) {
-	case *GenericLeafNode[K]:
-		// Case 1: Leaf node
-
-		// Try to delete the key from the leaf
-		// First, check if the key exists in this leaf
-		keyExists := false
-		for _, k := range n.Keys() {
-			if t.equal(k, key) {
-				keyExists = true
-				break
-			}
-		}
-
-		if !keyExists {
-			return false, zeroKey // Key not found
-		}
-
-		// Delete the key
-		if !n.DeleteKey(key, t.equal) {
-			return false, zeroKey // Key not found (should not happen)
-		}
-
-		// If this is the root or it doesn't underflow, we're done
-		if parent == nil || !n.IsUnderflow(t.branchingFactor) {
-			return true, zeroKey
-		}
-
-		// Handle underflow by borrowing or merging
-		return t.handleLeafUnderflow(n, parent, parentChildIndex), zeroKey
-
-	case *GenericBranchNode[K]:
-		// Case 2: Branch node (internal node)
-
-		// Check if the key is in this node
-		keyIndex := n.FindKey(key, t.equal)
-		if keyIndex != -1 {
-			// The key is in this internal node, so we need to find a replacement
-			// Get the rightmost key from the left subtree (predecessor)
-			if keyIndex < len(n.Children()) {
-				leftChild := n.Children()[keyIndex]
-				replacementKey, success := t.findAndRemoveMax(leftChild, n, keyIndex)
-				if success {
-					// Replace the key in this node
-					n.keys[keyIndex] = replacementKey
-					return true, zeroKey
-				}
-			}
-			return false, zeroKey
-		}
-
-		// The key is not in this node, so we need to find the child that should contain it
-		childIndex := n.FindChildIndex(key, t.less)
-
-		// Safety check to avoid index out of range
-		if childIndex >= len(n.Children()) {
-			return false, zeroKey
-		}
-
-		// Recursively delete from the child
-		deleted, keyToReplace := t.deleteAndBalance(n.Children()[childIndex], n, childIndex, key)
-		if !deleted {
-			return false, zeroKey // Key not found in the subtree
-		}
-
-		// If we need to replace a key in this node
-		// This happens when a key in an internal node is replaced during deletion
-		if !t.equal(keyToReplace, zeroKey) {
-			if childIndex > 0 {
-				n.keys[childIndex-1] = keyToReplace
-			}
-		}
-
-		// Check if the child underflowed and needs rebalancing
-		if childIndex < len(n.Children()) {
-			child := n.Children()[childIndex]
-			if child.IsUnderflow(t.branchingFactor) {
-				return true, t.handleBranchUnderflow(n, childIndex)
-			}
-		}
-
-		return true, zeroKey
+// removeFromBloomFilter removes a deleted key from the bloom filter.
+// Filters that cannot remove keys are invalidated instead, and are
+// recomputed on the next lookup.
+// Time complexity: O(k) where k is the number of hash functions in the bloom filter.
+func (t *GenericBPlusTree[K]) removeFromBloomFilter(key K) {
+	filter, ok := t.bloomFilter.(RemovableBloomFilter)
+	if !ok {
+		t.invalidateBloomFilter()
+		return
 	}
 
-	// This should never happen if the tree is properly structured
-	return false, zeroKey
+	// An invalid filter is recomputed from the tree anyway
+	if filter.IsValid() {
+		filter.Remove(t.hashFunc(key))
+	}
 }
 
-// findAndRemoveMax finds and removes the maximum key in the subtree rooted at node.
-// This is used during deletion when a key in an internal node needs to be replaced.
+// rebalance fixes the underflow a delete may have left in the leaf at the end of path.
+// This is the core of the deletion algorithm for the B+ tree.
 //
-// Parameters:
-// - node: The current node being processed
-// - parent: The parent of the current node
-// - parentChildIndex: The index of the current node in its parent's children array
-//
-// Returns:
-// - The maximum key in the subtree
-// - true if a key was found and removed, false otherwise
+// Keys are always removed from a leaf. A separator key in a branch node that
+// equals the deleted key is left in place: it still divides the keys of its
+// two subtrees correctly, because every remaining key to its right is larger.
 //
-// Time complexity: O(log n) where n is the number of keys in the subtree.
-func (t *GenericBPlusTree[K]) findAndRemoveMax(node GenericNode[K], parent *GenericBranchNode[K], parentChildIndex int) (K, bool) {
-	var zeroKey K // Zero value of K
-
-	switch n := node.(type) {
-	case *GenericLeafNode[K]:
-		// Case 1: Leaf node - the maximum key is the last key in the leaf
-
-		// Check if the leaf has any keys
-		if len(n.Keys()) == 0 {
-			return zeroKey, false
-		}
-
-		// Get the maximum key (last key in the leaf)
-		maxKeyIndex := len(n.Keys()) - 1
-		maxKey := n.Keys()[maxKeyIndex]
-
-		// Remove the maximum key
-		n.keys = n.Keys()[:maxKeyIndex]
-
-		// Handle underflow if necessary
-		if parent != nil && n.IsUnderflow(t.branchingFactor) {
-			t.handleLeafUnderflow(n, parent, parentChildIndex)
-		}
-
-		return maxKey, true
-
-	case *GenericBranchNode[K]:
-		// Case 2: Branch node - the maximum key is in the rightmost subtree
-
-		// Recursively find and remove the maximum key from the rightmost child
-		childIndex := len(n.Children()) - 1
-
-		// Safety check to avoid index out of range
-		if childIndex < 0 {
-			return zeroKey, false
+// An underflowing node borrows a key from a sibling or is merged with one.
+// A merge removes a key from the parent, which may underflow in turn, so it
+// walks up the path until a node has enough keys or the root is reached.
+// Time complexity: O(B log n) where B is the branching factor and n is the number of keys in the tree.
+func (t *GenericBPlusTree[K]) rebalance(path *treePath[K]) {
+	n := path.leaf
+	for n.IsUnderflow(t.branchingFactor) {
+		frame, ok := path.pop()
+		if !ok {
+			// The root may hold fewer keys, see handleRootUnderflow
+			return
 		}
 
-		maxKey, success := t.findAndRemoveMax(n.Children()[childIndex], n, childIndex)
-		if !success {
-			return zeroKey, false
-		}
-
-		// Handle underflow if necessary
-		child := n.Children()[childIndex]
-		if child.IsUnderflow(t.branchingFactor) {
-			t.handleBranchUnderflow(n, childIndex)
+		if n.IsLeaf() {
+			t.handleLeafUnderflow(n, frame.node, frame.index)
+		} else {
+			t.handleBranchUnderflow(frame.node, frame.index)
 		}
-
-		return maxKey, true
+		n = frame.node
 	}
-
-	// This should never happen if the tree is properly structured
-	return zeroKey, false
 }
 
 // handleLeafUnderflow handles the case where a leaf node has too few keys.
@@ -696,7 +760,7 @@ func (t *GenericBPlusTree[K]) findAndRemoveMax(node GenericNode[K], parent *Gene
 // - true if the underflow was handled successfully
 //
 // Time complexity: O(B) where B is the branching factor.
-func (t *GenericBPlusTree[K]) handleLeafUnderflow(leaf *GenericLeafNode[K], parent *GenericBranchNode[K], leafIndex int) bool {
+func (t *GenericBPlusTree[K]) handleLeafUnderflow(leaf *node[K], parent *node[K], leafIndex int) bool {
 	// First try to borrow keys from siblings
 	if t.tryBorrowFromSiblingLeaf(leaf, parent, leafIndex) {
 		return true
@@ -718,23 +782,27 @@ func (t *GenericBPlusTree[K]) handleLeafUnderflow(leaf *GenericLeafNode[K], pare
 // - true if borrowing was successful
 //
 // Time complexity: O(B) where B is the branching factor.
-func (t *GenericBPlusTree[K]) tryBorrowFromSiblingLeaf(leaf *GenericLeafNode[K], parent *GenericBranchNode[K], leafIndex int) bool {
+func (t *GenericBPlusTree[K]) tryBorrowFromSiblingLeaf(leaf *node[K], parent *node[K], leafIndex int) bool {
 	// Try to borrow from right sibling first (if it exists)
 	if leafIndex < len(parent.Children())-1 {
-		rightSibling, ok := parent.Children()[leafIndex+1].(*GenericLeafNode[K])
-		if ok && len(rightSibling.Keys()) > minLeafKeys(t.branchingFactor) {
+		rightSibling := parent.Children()[leafIndex+1]
+		if rightSibling.IsLeaf() && len(rightSibling.Keys()) > minLeafKeys(t.branchingFactor) {
 			// Right sibling has enough keys to spare one
 			leaf.BorrowFromRight(rightSibling, leafIndex, parent)
+			t.markModified(leaf)
+			t.markModified(rightSibling)
 			return true
 		}
 	}
 
 	// If borrowing from right failed, try to borrow from left sibling
 	if leafIndex > 0 {
-		leftSibling, ok := parent.Children()[leafIndex-1].(*GenericLeafNode[K])
-		if ok && len(leftSibling.Keys()) > minLeafKeys(t.branchingFactor) {
+		leftSibling := parent.Children()[leafIndex-1]
+		if leftSibling.IsLeaf() && len(leftSibling.Keys()) > minLeafKeys(t.branchingFactor) {
 			// Left sibling has enough keys to spare one
 			leaf.BorrowFromLeft(leftSibling, leafIndex, parent)
+			t.markModified(leaf)
+			t.markModified(leftSibling)
 			return true
 		}
 	}
@@ -755,34 +823,36 @@ func (t *GenericBPlusTree[K]) tryBorrowFromSiblingLeaf(leaf *GenericLeafNode[K],
 // - true if merging was successful
 //
 // Time complexity: O(B) where B is the branching factor.
-func (t *GenericBPlusTree[K]) mergeLeafWithSibling(leaf *GenericLeafNode[K], parent *GenericBranchNode[K], leafIndex int) bool {
+func (t *GenericBPlusTree[K]) mergeLeafWithSibling(leaf *node[K], parent *node[K], leafIndex int) bool {
 	// Try to merge with left sibling first (if it exists)
 	if leafIndex > 0 {
-		leftSibling, ok := parent.Children()[leafIndex-1].(*GenericLeafNode[K])
-		if ok {
-			// Merge leaf into left sibling
-			leftSibling.MergeWith(leaf)
+		leftSibling := parent.Children()[leafIndex-1]
+		if leftSibling.IsLeaf() {
+			// Merge leaf into left sibling, dropping the separator key
+			leftSibling.MergeWith(parent.keys[leafIndex-1], leaf)
+			t.markModified(leftSibling)
 
 			// Update the linked list of leaves
 			// (leftSibling.next is already set to leaf.next by MergeWith)
 
-			// Remove the separator key and the leaf from the parent
-			parent.DeleteKey(parent.Keys()[leafIndex-1], t.equal)
-			parent.RemoveChild(leafIndex)
+			// Remove the separator key and the leaf to its right from the parent
+			parent.removeKeyAndRightChild(leafIndex - 1)
+			t.removeLeaf(leaf, leftSibling)
 			return true
 		}
 	}
 
 	// If merging with left failed, try to merge with right sibling
 	if leafIndex < len(parent.Children())-1 {
-		rightSibling, ok := parent.Children()[leafIndex+1].(*GenericLeafNode[K])
-		if ok {
-			// Merge right sibling into leaf
-			leaf.MergeWith(rightSibling)
+		rightSibling := parent.Children()[leafIndex+1]
+		if rightSibling.IsLeaf() {
+			// Merge right sibling into leaf, dropping the separator key
+			leaf.MergeWith(parent.keys[leafIndex], rightSibling)
+			t.markModified(leaf)
 
 			// Remove the separator key and the right sibling from the parent
-			parent.DeleteKey(parent.Keys()[leafIndex], t.equal)
-			parent.RemoveChild(leafIndex + 1)
+			parent.removeKeyAndRightChild(leafIndex)
+			t.removeLeaf(rightSibling, leaf)
 			return true
 		}
 	}
@@ -799,21 +869,19 @@ func (t *GenericBPlusTree[K]) mergeLeafWithSibling(leaf *GenericLeafNode[K], par
 // - childIndex: The index of the branch in its parent's children array
 //
 // Returns:
-// - A key that needs to be replaced in the parent, or the zero value if no replacement is needed
+// - true if the underflow was handled successfully
 //
 // Time complexity: O(B) where B is the branching factor.
-func (t *GenericBPlusTree[K]) handleBranchUnderflow(parent *GenericBranchNode[K], childIndex int) K {
-	var zeroKey K // Zero value of K
-
+func (t *GenericBPlusTree[K]) handleBranchUnderflow(parent *node[K], childIndex int) bool {
 	// Ensure the child is a branch node
-	child, ok := parent.Children()[childIndex].(*GenericBranchNode[K])
-	if !ok {
-		return zeroKey
+	child := parent.Children()[childIndex]
+	if child.IsLeaf() {
+		return false
 	}
 
 	// First try to borrow keys from siblings
 	if t.tryBorrowFromSiblingBranch(child, parent, childIndex) {
-		return zeroKey
+		return true
 	}
 
 	// If borrowing fails, merge with a sibling
@@ -832,25 +900,25 @@ func (t *GenericBPlusTree[K]) handleBranchUnderflow(parent *GenericBranchNode[K]
 // - true if borrowing was successful
 //
 // Time complexity: O(B) where B is the branching factor.
-func (t *GenericBPlusTree[K]) tryBorrowFromSiblingBranch(branch *GenericBranchNode[K], parent *GenericBranchNode[K], branchIndex int) bool {
+func (t *GenericBPlusTree[K]) tryBorrowFromSiblingBranch(branch *node[K], parent *node[K], branchIndex int) bool {
 	// Try to borrow from right sibling first (if it exists)
 	if branchIndex < len(parent.Children())-1 {
-		rightSibling, ok := parent.Children()[branchIndex+1].(*GenericBranchNode[K])
-		if ok && len(rightSibling.Keys()) > minInternalKeys(t.branchingFactor) {
+		rightSibling := parent.Children()[branchIndex+1]
+		if !rightSibling.IsLeaf() && len(rightSibling.Keys()) > minInternalKeys(t.branchingFactor) {
 			// Right sibling has enough keys to spare one
-			separatorKey := parent.Keys()[branchIndex]
-			branch.BorrowFromRight(separatorKey, rightSibling, branchIndex, parent)
+			branch.BorrowFromRight(rightSibling, branchIndex, parent)
+			t.moveMessagesLeft(branch, rightSibling, parent.keys[branchIndex])
 			return true
 		}
 	}
 
 	// If borrowing from right failed, try to borrow from left sibling
 	if branchIndex > 0 {
-		leftSibling, ok := parent.Children()[branchIndex-1].(*GenericBranchNode[K])
-		if ok && len(leftSibling.Keys()) > minInternalKeys(t.branchingFactor) {
+		leftSibling := parent.Children()[branchIndex-1]
+		if !leftSibling.IsLeaf() && len(leftSibling.Keys()) > minInternalKeys(t.branchingFactor) {
 			// Left sibling has enough keys to spare one
-			separatorKey := parent.Keys()[branchIndex-1]
-			branch.BorrowFromLeft(separatorKey, leftSibling, branchIndex, parent)
+			branch.BorrowFromLeft(leftSibling, branchIndex, parent)
+			t.moveMessagesRight(leftSibling, branch, parent.keys[branchIndex-1])
 			return true
 		}
 	}
@@ -868,16 +936,14 @@ func (t *GenericBPlusTree[K]) tryBorrowFromSiblingBranch(branch *GenericBranchNo
 // - branchIndex: The index of the branch in its parent's children array
 //
 // Returns:
-// - A key that needs to be replaced in the parent, or the zero value if no replacement is needed
+// - true if merging was successful
 //
 // Time complexity: O(B) where B is the branching factor.
-func (t *GenericBPlusTree[K]) mergeBranchWithSibling(branch *GenericBranchNode[K], parent *GenericBranchNode[K], branchIndex int) K {
-	var keyToReturn K // Zero value of K
-
+func (t *GenericBPlusTree[K]) mergeBranchWithSibling(branch *node[K], parent *node[K], branchIndex int) bool {
 	// Try to merge with left sibling first (if it exists)
 	if branchIndex > 0 {
-		leftSibling, ok := parent.Children()[branchIndex-1].(*GenericBranchNode[K])
-		if ok {
+		leftSibling := parent.Children()[branchIndex-1]
+		if !leftSibling.IsLeaf() {
 			// Get the separator key from the parent
 			separatorKey := parent.Keys()[branchIndex-1]
 
@@ -886,17 +952,16 @@ func (t *GenericBPlusTree[K]) mergeBranchWithSibling(branch *GenericBranchNode[K
 			leftSibling.MergeWith(separatorKey, branch)
 
 			// Remove the separator key and the branch from the parent
-			parent.DeleteKey(separatorKey, t.equal)
-			parent.RemoveChild(branchIndex)
-
-			return keyToReturn
+			parent.removeKeyAndRightChild(branchIndex - 1)
+			t.pool.free(branch)
+			return true
 		}
 	}
 
 	// If merging with left failed, try to merge with right sibling
 	if branchIndex < len(parent.Children())-1 {
-		rightSibling, ok := parent.Children()[branchIndex+1].(*GenericBranchNode[K])
-		if ok {
+		rightSibling := parent.Children()[branchIndex+1]
+		if !rightSibling.IsLeaf() {
 			// Get the separator key from the parent
 			separatorKey := parent.Keys()[branchIndex]
 
@@ -905,26 +970,21 @@ func (t *GenericBPlusTree[K]) mergeBranchWithSibling(branch *GenericBranchNode[K
 			branch.MergeWith(separatorKey, rightSibling)
 
 			// Remove the separator key and the right sibling from the parent
-			parent.DeleteKey(separatorKey, t.equal)
-			parent.RemoveChild(branchIndex + 1)
-
-			// If this was the last key in the parent, we need to return the first key of the merged node
-			// This key will be used to replace a key in a higher level of the tree
-			if len(parent.Keys()) == 0 && len(branch.Keys()) > 0 {
-				return branch.Keys()[0]
-			}
-
-			return keyToReturn
+			parent.removeKeyAndRightChild(branchIndex)
+			t.pool.free(rightSibling)
+			return true
 		}
 	}
 
 	// Merging failed (this should not happen in a properly structured tree)
-	return keyToReturn
+	return false
 }
 
 // GetAllKeys returns all keys in the tree as an unsorted slice.
 // Time complexity: O(n) where n is the number of keys in the tree.
 func (t *GenericBPlusTree[K]) GetAllKeys() []K {
+	t.Flush()
+
 	// Pre-allocate the slice with the known size for efficiency
 	keys := make([]K, 0, t.size)
 
@@ -935,17 +995,24 @@ func (t *GenericBPlusTree[K]) GetAllKeys() []K {
 }
 
 // collectKeys collects all keys in the subtree rooted at node.
+// It follows the leaf chain from the leftmost leaf of the subtree to its last leaf.
 // Time complexity: O(n) where n is the number of keys in the subtree.
-func (t *GenericBPlusTree[K]) collectKeys(node GenericNode[K], keys *[]K) {
-	switch n := node.(type) {
-	case *GenericLeafNode[K]:
-		// For leaf nodes, add all keys to the result
-		*keys = append(*keys, n.Keys()...)
-
-	case *GenericBranchNode[K]:
-		// For branch nodes, recursively collect keys from all children
-		for _, child := range n.Children() {
-			t.collectKeys(child, keys)
+func (t *GenericBPlusTree[K]) collectKeys(n *node[K], keys *[]K) {
+	// Find the leftmost and rightmost leaves of the subtree
+	first, last := n, n
+	for first.kind == Branch {
+		if len(first.children) == 0 {
+			return
+		}
+		first = first.children[0]
+		last = last.children[len(last.children)-1]
+	}
+
+	// Add the keys of every leaf between them
+	for leaf := first; leaf != nil; leaf = leaf.next {
+		*keys = append(*keys, leaf.keys...)
+		if leaf == last {
+			break
 		}
 	}
 }
@@ -957,48 +1024,99 @@ func (t *GenericBPlusTree[K]) collectKeys(node GenericNode[K], keys *[]K) {
 func (t *GenericBPlusTree[K]) RangeQuery(start, end K) []K {
 	result := make([]K, 0)
 
+	// Skip the descent if the range filter says the range is empty
+	if !t.mayContainRange(start, end) {
+		return result
+	}
+	t.flushRange(start, end)
+
 	// Find the leaf containing the start key
 	leaf := t.findLeafNode(t.root, start)
 	if leaf == nil {
 		return result
 	}
 
+	// Skip the keys before start in the first leaf
+	pos, _ := t.search(leaf.keys, start)
+
 	// Traverse the linked list of leaves until we reach the end key
 	for leaf != nil {
-		for _, key := range leaf.Keys() {
-			// Check if the key is in the range [start, end]
-			inRange := (t.less(start, key) || t.equal(start, key)) &&
-				(t.less(key, end) || t.equal(key, end))
-
-			if inRange {
-				result = append(result, key)
-			}
+		keys := leaf.keys[pos:]
+		n := t.countUpTo(keys, end)
+		result = append(result, keys[:n]...)
 
-			// If we've passed the end key, we're done
-			if t.less(end, key) {
-				return result
-			}
+		// If we've passed the end key, we're done
+		if n < len(keys) {
+			return result
 		}
 
 		// Move to the next leaf in the linked list
 		leaf = leaf.next
+		pos = 0
 	}
 
 	return result
 }
 
+// CountRange returns the number of keys in the range [start, end], inclusive,
+// without collecting them.
+// Time complexity: O(log n + k) where n is the number of keys in the tree
+// and k is the number of keys in the range.
+func (t *GenericBPlusTree[K]) CountRange(start, end K) int {
+	// Skip the descent if the range filter says the range is empty
+	if !t.mayContainRange(start, end) {
+		return 0
+	}
+	t.flushRange(start, end)
+
+	leaf := t.findLeafNode(t.root, start)
+	if leaf == nil {
+		return 0
+	}
+
+	// Skip the keys before start in the first leaf
+	pos, _ := t.search(leaf.keys, start)
+	count := 0
+	for ; leaf != nil; leaf, pos = leaf.next, 0 {
+		keys := leaf.keys[pos:]
+		n := t.countUpTo(keys, end)
+		count += n
+		if n < len(keys) {
+			return count
+		}
+	}
+	return count
+}
+
+// countUpTo returns the number of sorted keys that are less than or equal to end.
+// Time complexity: O(log B) where B is the branching factor.
+func (t *GenericBPlusTree[K]) countUpTo(keys []K, end K) int {
+	n, found := t.search(keys, end)
+	if found {
+		n++
+	}
+	return n
+}
+
 // Clear removes all keys from the tree.
-// Time complexity: O(1)
+// Its nodes are recycled for the keys inserted next.
+// Time complexity: O(n/B) where n is the number of keys in the tree and B is the branching factor.
 func (t *GenericBPlusTree[K]) Clear() {
-	// Create a new empty leaf node as the root
-	t.root = NewGenericLeafNode[K]()
+	// Recycle the old nodes and create a new empty leaf node as the root
+	t.pool.freeSubtree(t.root)
+	root := t.pool.newLeaf()
+	t.markModified(root)
+	t.root = root
+	t.rightmost = root
+	t.moveFinger(nil)
 
 	// Reset tree properties
 	t.height = 1
 	t.size = 0
 
-	// Clear the bloom filter
+	// Clear the bloom filter and range filter
 	t.bloomFilter.Clear()
+	t.invalidateRangeFilter()
 }
 
 // String returns a string representation of the tree.
@@ -1012,6 +1130,7 @@ func (t *GenericBPlusTree[K]) String() string {
 // This is useful for debugging and verification.
 // Time complexity: O(n) where n is the number of keys in the tree.
 func (t *GenericBPlusTree[K]) CountKeys() int {
+	t.Flush()
 	count := 0
 	t.traverseTree(t.root, func(key K) {
 		count++
@@ -1021,13 +1140,13 @@ func (t *GenericBPlusTree[K]) CountKeys() int {
 
 // traverseTree traverses the tree in-order and calls the visitor function for each key.
 // Time complexity: O(n) where n is the number of keys in the tree.
-func (t *GenericBPlusTree[K]) traverseTree(node GenericNode[K], visitor func(K)) {
-	switch n := node.(type) {
-	case *GenericLeafNode[K]:
+func (t *GenericBPlusTree[K]) traverseTree(n *node[K], visitor func(K)) {
+	switch n.kind {
+	case Leaf:
 		for _, key := range n.Keys() {
 			visitor(key)
 		}
-	case *GenericBranchNode[K]:
+	case Branch:
 		for i, child := range n.Children() {
 			t.traverseTree(child, visitor)
 			if i < len(n.Keys()) {
@@ -1041,33 +1160,29 @@ func (t *GenericBPlusTree[K]) traverseTree(node GenericNode[K], visitor func(K))
 // ForceDeleteKeys forcibly deletes keys from the tree.
 // This is a utility method for testing and debugging.
 // It returns the number of keys that were actually deleted.
-// Time complexity: O(n*log(n)) where n is the number of keys to delete.
+// Time complexity: O(m*log(m) + m*log(n)) where m is the number of keys to delete
+// and n is the number of keys in the tree.
 func (t *GenericBPlusTree[K]) ForceDeleteKeys(keys []K) int {
-	// First, collect all keys in the tree
-	treeKeys := t.GetAllKeys()
-
-	// Create a map of keys to delete for faster lookup
-	keysToDelete := make(map[K]bool)
-	for _, key := range keys {
-		keysToDelete[key] = true
-	}
+	// Sort the keys to delete so duplicates are deleted only once
+	keysToDelete := slices.Clone(keys)
+	slices.SortFunc(keysToDelete, t.compare)
+	keysToDelete = slices.CompactFunc(keysToDelete, func(a, b K) bool { return t.compare(a, b) == 0 })
 
 	// Delete all keys that are in the tree and in the keys to delete
+	t.Flush()
 	count := 0
-	for _, key := range treeKeys {
-		if keysToDelete[key] {
-			// Use a direct approach to delete the key
-			leaf := t.findLeafNode(t.root, key)
-			if leaf != nil && leaf.DeleteKey(key, t.equal) {
-				t.decrementSize()
-				count++
-			}
+	for _, key := range keysToDelete {
+		// Use a direct approach to delete the key
+		leaf := t.findLeafNode(t.root, key)
+		if leaf != nil && leaf.DeleteKey(key, t.search) {
+			t.markModified(leaf)
+			t.decrementSize()
+			t.removeFromBloomFilter(key)
+			t.recordRangeFilterDelete()
+			count++
 		}
 	}
 
-	// Invalidate the bloom filter
-	t.invalidateBloomFilter()
-
 	return count
 }
 
@@ -1,6 +1,8 @@
 package bplustree
 
 import (
+	"math/rand"
+	"slices"
 	"testing"
 )
 
@@ -196,6 +198,141 @@ func TestGenericBPlusTreeWithoutBloom(t *testing.T) {
 	}
 }
 
+// TestGenericBPlusTreeWithCompare tests a tree ordered by a three-way compare function
+func TestGenericBPlusTreeWithCompare(t *testing.T) {
+	// Descending order, counting comparisons
+	calls := 0
+	tree := NewGenericBPlusTreeWithCompare(
+		4,
+		func(a, b int) int {
+			calls++
+			return b - a
+		},
+		func(v int) uint64 { return uint64(v) },
+	)
+
+	for i := 0; i < 100; i++ {
+		tree.Insert(i)
+	}
+	if tree.Insert(50) {
+		t.Errorf("Expected duplicate insert to fail")
+	}
+	if !tree.Contains(0) || !tree.Contains(99) || tree.Contains(100) {
+		t.Errorf("Unexpected Contains result")
+	}
+	if got := tree.RangeQuery(12, 9); !slices.Equal(got, []int{12, 11, 10, 9}) {
+		t.Errorf("Expected [12 11 10 9], got %v", got)
+	}
+	if got := tree.CountRange(99, 90); got != 10 {
+		t.Errorf("Expected 10 keys in range, got %d", got)
+	}
+	for i := 0; i < 100; i += 2 {
+		if !tree.Delete(i) {
+			t.Errorf("Failed to delete %d", i)
+		}
+	}
+	if tree.Size() != 50 || tree.Contains(10) || !tree.Contains(11) {
+		t.Errorf("Unexpected tree contents after deletes: %v", tree.GetAllKeys())
+	}
+
+	// Each step of a lookup answers ordering and equality with the same calls
+	calls = 0
+	tree.Contains(51)
+	if calls > tree.Height()*4+4 {
+		t.Errorf("Expected a few compares per level, got %d calls for height %d", calls, tree.Height())
+	}
+}
+
+// TestCompareFromLessEqual tests the adapter used by the less and equal constructors
+func TestCompareFromLessEqual(t *testing.T) {
+	compare := compareFromLessEqual(
+		func(a, b int) bool { return a < b },
+		func(a, b int) bool { return a == b },
+	)
+	if compare(1, 2) >= 0 || compare(2, 2) != 0 || compare(3, 2) <= 0 {
+		t.Errorf("Adapter does not order like the less and equal functions")
+	}
+}
+
+// TestNodeKeysAllocatedOnce tests that nodes keep the key and child slices
+// they were created with through splits, borrows and merges
+func TestNodeKeysAllocatedOnce(t *testing.T) {
+	const bf = 6
+	tree := NewBPlusTree(bf)
+	for i := 0; i < 2000; i++ {
+		tree.Insert(uint64(i * 7 % 2000))
+	}
+	for i := 0; i < 2000; i += 3 {
+		tree.Delete(uint64(i))
+	}
+	checkTreeInvariants(t, tree)
+
+	var walk func(n *node[uint64])
+	walk = func(n *node[uint64]) {
+		if n.IsLeaf() {
+			if cap(n.keys) != bf+1 {
+				t.Errorf("Expected leaf key capacity %d, got %d", bf+1, cap(n.keys))
+			}
+			return
+		}
+		if cap(n.keys) != bf || cap(n.children) != bf+1 {
+			t.Errorf("Expected branch capacities %d and %d, got %d and %d", bf, bf+1, cap(n.keys), cap(n.children))
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(tree.root)
+}
+
+// TestGenericNodeTypes tests the exported leaf and branch node types, which
+// wrap the tree's concrete node with the methods of the GenericNode interface
+func TestGenericNodeTypes(t *testing.T) {
+	less := func(a, b int) bool { return a < b }
+	equal := func(a, b int) bool { return a == b }
+
+	left, right := NewGenericLeafNode[int](), NewGenericLeafNode[int]()
+	for _, key := range []int{3, 1, 2} {
+		left.InsertKey(key, less)
+	}
+	for _, key := range []int{5, 6, 7} {
+		right.InsertKey(key, less)
+	}
+	if left.InsertKey(2, less) || !slices.Equal(left.Keys(), []int{1, 2, 3}) {
+		t.Fatalf("Expected leaf keys [1 2 3] without duplicates, got %v", left.Keys())
+	}
+	left.SetNext(right)
+	if left.Next() != right || left.Type() != Leaf {
+		t.Fatalf("Expected the leaf to link to its right sibling")
+	}
+
+	parent := NewGenericBranchNode[int]()
+	parent.SetChild(0, left)
+	parent.InsertKeyWithChild(5, right, less)
+	if parent.FindChildIndex(4, less) != 0 || parent.FindChildIndex(5, less) != 1 || parent.Type() != Branch {
+		t.Fatalf("Expected keys below 5 to go left and others right")
+	}
+	children := parent.Children()
+	if len(children) != 2 || children[0] != GenericNode[int](left) || children[1].Type() != Leaf {
+		t.Fatalf("Expected the two leaves as children, got %v", children)
+	}
+
+	// Borrowing moves a key and the separator; merging joins the leaves again
+	left.BorrowFromRight(right, 0, parent)
+	if !slices.Equal(left.Keys(), []int{1, 2, 3, 5}) || parent.Keys()[0] != 6 {
+		t.Fatalf("Expected 5 to move left and the separator to become 6, got %v and %v", left.Keys(), parent.Keys())
+	}
+	right.BorrowFromLeft(left, 1, parent)
+	if !slices.Equal(right.Keys(), []int{5, 6, 7}) || parent.Keys()[0] != 5 {
+		t.Fatalf("Expected 5 to move back and the separator to become 5, got %v and %v", right.Keys(), parent.Keys())
+	}
+	left.MergeWith(right)
+	parent.RemoveChild(1)
+	if !left.DeleteKey(6, equal) || left.Contains(6, equal) || left.FindKey(7, equal) != 4 || left.Next() != nil {
+		t.Fatalf("Expected the merged leaf to hold [1 2 3 5 7], got %v", left.Keys())
+	}
+}
+
 // TestGenericBPlusTreeLargeDataset tests the tree with a large dataset
 func TestGenericBPlusTreeLargeDataset(t *testing.T) {
 	if testing.Short() {
@@ -319,3 +456,34 @@ func TestGenericBPlusTreeBloomFilterOptimization(t *testing.T) {
 		}
 	}
 }
+
+// TestInsertDeleteKeepsNodesBalanced tests that splits and merges keep every
+// node above its minimum size, for odd as well as even branching factors
+func TestInsertDeleteKeepsNodesBalanced(t *testing.T) {
+	for _, bf := range []int{3, 4, 5, 7, 8} {
+		tree := NewBPlusTree(bf)
+		present := make(map[uint64]bool)
+		r := rand.New(rand.NewSource(int64(bf)))
+		for i := 0; i < 5000; i++ {
+			key := uint64(r.Intn(1000))
+			if r.Intn(3) == 0 {
+				if tree.Delete(key) != present[key] {
+					t.Fatalf("bf=%d: Expected Delete(%d) to return %v", bf, key, present[key])
+				}
+				delete(present, key)
+			} else {
+				if tree.Insert(key) == present[key] {
+					t.Fatalf("bf=%d: Expected Insert(%d) to return %v", bf, key, !present[key])
+				}
+				present[key] = true
+			}
+			if i%100 == 0 {
+				checkTreeInvariants(t, tree)
+			}
+		}
+		checkTreeInvariants(t, tree)
+		if tree.Size() != len(present) {
+			t.Errorf("bf=%d: Expected size %d, got %d", bf, len(present), tree.Size())
+		}
+	}
+}
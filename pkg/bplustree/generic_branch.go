@@ -1,20 +1,75 @@
 package bplustree
 
-import (
-	"sort"
-)
-
 // GenericBranchNode is an internal node that stores keys of type K
-type GenericBranchNode[K any] struct {
-	keys     []K
-	children []GenericNode[K]
-}
+type GenericBranchNode[K any] node[K]
 
 // NewGenericBranchNode creates a new generic branch node
 func NewGenericBranchNode[K any]() *GenericBranchNode[K] {
 	return &GenericBranchNode[K]{
+		kind:     Branch,
 		keys:     make([]K, 0),
-		children: make([]GenericNode[K], 0),
+		children: make([]*node[K], 0),
+	}
+}
+
+// newBranchNode creates a new branch node with room for branchingFactor children,
+// and one more for an insert that overflows it
+func newBranchNode[K any](branchingFactor int) *node[K] {
+	return &node[K]{
+		kind:     Branch,
+		keys:     make([]K, 0, branchingFactor),
+		children: make([]*node[K], 0, branchingFactor+1),
+	}
+}
+
+// Children returns the children of the node
+func (n *node[K]) Children() []*node[K] {
+	return n.children
+}
+
+// InsertKeyWithChild inserts a key and child into the node at the correct position
+func (n *node[K]) InsertKeyWithChild(key K, child *node[K], search searchFunc[K]) {
+	pos, _ := search(n.keys, key)
+
+	// Insert key, and the child to the right of the key
+	n.keys = insertAt(n.keys, pos, key)
+	n.children = insertAt(n.children, pos+1, child)
+}
+
+// removeKeyAndRightChild removes the key at pos and the child to its right
+func (n *node[K]) removeKeyAndRightChild(pos int) {
+	n.keys = removeAt(n.keys, pos)
+	n.children = removeAt(n.children, pos+1)
+}
+
+// FindChildIndex returns the index of the child that should contain the key
+// Time complexity: O(log B) where B is the branching factor.
+func (n *node[K]) FindChildIndex(key K, search searchFunc[K]) int {
+	// Find the position using binary search
+	pos, found := search(n.keys, key)
+
+	// If the key at pos is equal to the search key, return the child to the right
+	// Otherwise, return the child at pos, which is the last child if all keys are less
+	if found {
+		// Keys are equal, go to the right child
+		return pos + 1
+	}
+	return pos
+}
+
+// SetChild sets the child at the given index
+func (n *node[K]) SetChild(index int, child *node[K]) {
+	if index < len(n.children) {
+		n.children[index] = child
+	} else if index == len(n.children) {
+		n.children = append(n.children, child)
+	}
+}
+
+// RemoveChild removes the child at the given index
+func (n *node[K]) RemoveChild(index int) {
+	if index < len(n.children) {
+		n.children = removeAt(n.children, index)
 	}
 }
 
@@ -30,7 +85,11 @@ func (n *GenericBranchNode[K]) Keys() []K {
 
 // Children returns the children of the node
 func (n *GenericBranchNode[K]) Children() []GenericNode[K] {
-	return n.children
+	children := make([]GenericNode[K], len(n.children))
+	for i, child := range n.children {
+		children[i] = exportNode(child)
+	}
+	return children
 }
 
 // KeyCount returns the number of keys in the node
@@ -40,36 +99,17 @@ func (n *GenericBranchNode[K]) KeyCount() int {
 
 // IsFull returns true if the node is full
 func (n *GenericBranchNode[K]) IsFull(branchingFactor int) bool {
-	return len(n.keys) >= branchingFactor-1
+	return (*node[K])(n).IsFull(branchingFactor)
 }
 
 // IsUnderflow returns true if the node has too few keys
 func (n *GenericBranchNode[K]) IsUnderflow(branchingFactor int) bool {
-	// For internal nodes, minimum number of keys is ceil(m/2)-1
-	return len(n.keys) < minInternalKeys(branchingFactor)
+	return (*node[K])(n).IsUnderflow(branchingFactor)
 }
 
 // InsertKeyWithChild inserts a key and child into the node at the correct position
 func (n *GenericBranchNode[K]) InsertKeyWithChild(key K, child GenericNode[K], less func(a, b K) bool) {
-	pos := n.findInsertPosition(key, less)
-
-	// Insert key
-	n.keys = append(n.keys, *new(K)) // Add zero value of K
-	copy(n.keys[pos+1:], n.keys[pos:])
-	n.keys[pos] = key
-
-	// Insert child (goes to the right of the key)
-	n.children = append(n.children, nil)
-	copy(n.children[pos+2:], n.children[pos+1:])
-	n.children[pos+1] = child
-}
-
-// findInsertPosition finds the position to insert a key
-func (n *GenericBranchNode[K]) findInsertPosition(key K, less func(a, b K) bool) int {
-	// Find the position to insert using binary search
-	return sort.Search(len(n.keys), func(i int) bool {
-		return !less(n.keys[i], key) // equivalent to n.keys[i] >= key
-	})
+	(*node[K])(n).InsertKeyWithChild(key, importNode(child), lessSearch(less))
 }
 
 // InsertKey inserts a key into the node
@@ -85,26 +125,13 @@ func (n *GenericBranchNode[K]) DeleteKey(key K, equal func(a, b K) bool) bool {
 	if pos == -1 {
 		return false
 	}
-
-	// Remove key
-	copy(n.keys[pos:], n.keys[pos+1:])
-	n.keys = n.keys[:len(n.keys)-1]
-
-	// Remove child to the right of the key
-	copy(n.children[pos+1:], n.children[pos+2:])
-	n.children = n.children[:len(n.children)-1]
-
+	(*node[K])(n).removeKeyAndRightChild(pos)
 	return true
 }
 
 // FindKey returns the index of the key in the node, or -1 if not found
 func (n *GenericBranchNode[K]) FindKey(key K, equal func(a, b K) bool) int {
-	for i, k := range n.keys {
-		if equal(k, key) {
-			return i
-		}
-	}
-	return -1
+	return findEqual(n.keys, key, equal)
 }
 
 // Contains returns true if the node contains the key
@@ -114,91 +141,34 @@ func (n *GenericBranchNode[K]) Contains(key K, equal func(a, b K) bool) bool {
 
 // FindChildIndex returns the index of the child that should contain the key
 func (n *GenericBranchNode[K]) FindChildIndex(key K, less func(a, b K) bool) int {
-	// Special case for empty node
-	if len(n.keys) == 0 {
-		if len(n.children) > 0 {
-			return 0
-		}
-		return 0 // This should not happen in a properly structured tree
-	}
-
-	// Find the position using binary search
-	pos := sort.Search(len(n.keys), func(i int) bool {
-		return !less(n.keys[i], key) // equivalent to n.keys[i] >= key
-	})
-
-	// If all keys are less than the search key, return the last child
-	if pos == len(n.keys) {
-		return pos
-	}
-
-	// If the key at pos is equal to the search key, return the child to the right
-	// Otherwise, return the child at pos
-	if !less(key, n.keys[pos]) && !less(n.keys[pos], key) {
-		// Keys are equal, go to the right child
-		return pos + 1
-	}
-	return pos
+	return (*node[K])(n).FindChildIndex(key, lessSearch(less))
 }
 
 // SetChild sets the child at the given index
 func (n *GenericBranchNode[K]) SetChild(index int, child GenericNode[K]) {
-	if index < len(n.children) {
-		n.children[index] = child
-	} else if index == len(n.children) {
-		n.children = append(n.children, child)
-	}
+	(*node[K])(n).SetChild(index, importNode(child))
 }
 
 // RemoveChild removes the child at the given index
 func (n *GenericBranchNode[K]) RemoveChild(index int) {
-	if index < len(n.children) {
-		copy(n.children[index:], n.children[index+1:])
-		n.children = n.children[:len(n.children)-1]
-	}
+	(*node[K])(n).RemoveChild(index)
 }
 
 // MergeWith merges this node with another branch node
 func (n *GenericBranchNode[K]) MergeWith(separatorKey K, other *GenericBranchNode[K]) {
-	// Add the separator key
-	n.keys = append(n.keys, separatorKey)
-
-	// Add all keys from the other node
-	n.keys = append(n.keys, other.keys...)
-
-	// Add all children from the other node
-	n.children = append(n.children, other.children...)
+	(*node[K])(n).MergeWith(separatorKey, (*node[K])(other))
 }
 
 // BorrowFromRight borrows a key and child from the right sibling
 func (n *GenericBranchNode[K]) BorrowFromRight(separatorKey K, rightSibling *GenericBranchNode[K], parentIndex int, parent *GenericBranchNode[K]) {
-	// Add the separator key from parent to this node
-	n.keys = append(n.keys, separatorKey)
-
-	// Add the first child from the right sibling to this node
-	n.children = append(n.children, rightSibling.children[0])
-
-	// Update the separator key in the parent
-	parent.keys[parentIndex] = rightSibling.keys[0]
-
-	// Remove the borrowed key and child from the right sibling
-	rightSibling.keys = rightSibling.keys[1:]
-	rightSibling.children = rightSibling.children[1:]
+	// The node moves the separator down from the parent, and replaces it there
+	parent.keys[parentIndex] = separatorKey
+	(*node[K])(n).BorrowFromRight((*node[K])(rightSibling), parentIndex, (*node[K])(parent))
 }
 
 // BorrowFromLeft borrows a key and child from the left sibling
 func (n *GenericBranchNode[K]) BorrowFromLeft(separatorKey K, leftSibling *GenericBranchNode[K], parentIndex int, parent *GenericBranchNode[K]) {
-	// Insert the separator key at the beginning of this node's keys
-	n.keys = append([]K{separatorKey}, n.keys...)
-
-	// Insert the last child from the left sibling at the beginning of this node's children
-	lastChildIndex := len(leftSibling.children) - 1
-	n.children = append([]GenericNode[K]{leftSibling.children[lastChildIndex]}, n.children...)
-
-	// Update the separator key in the parent
-	parent.keys[parentIndex-1] = leftSibling.keys[len(leftSibling.keys)-1]
-
-	// Remove the borrowed key and child from the left sibling
-	leftSibling.keys = leftSibling.keys[:len(leftSibling.keys)-1]
-	leftSibling.children = leftSibling.children[:len(leftSibling.children)-1]
+	// The node moves the separator down from the parent, and replaces it there
+	parent.keys[parentIndex-1] = separatorKey
+	(*node[K])(n).BorrowFromLeft((*node[K])(leftSibling), parentIndex, (*node[K])(parent))
 }
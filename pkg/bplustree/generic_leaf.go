@@ -1,23 +1,48 @@
 package bplustree
 
-import (
-	"sort"
-)
-
 // GenericLeafNode is a leaf node that stores keys of type K
-type GenericLeafNode[K any] struct {
-	keys []K
-	next *GenericLeafNode[K] // Pointer to the next leaf node for range queries
-}
+type GenericLeafNode[K any] node[K]
 
 // NewGenericLeafNode creates a new generic leaf node
 func NewGenericLeafNode[K any]() *GenericLeafNode[K] {
 	return &GenericLeafNode[K]{
+		kind: Leaf,
 		keys: make([]K, 0),
-		next: nil,
 	}
 }
 
+// newLeafNode creates a new leaf node with room for branchingFactor keys,
+// and one more for an insert that overflows it
+func newLeafNode[K any](branchingFactor int) *node[K] {
+	return &node[K]{
+		kind: Leaf,
+		keys: make([]K, 0, branchingFactor+1),
+	}
+}
+
+// Next returns the next leaf node
+func (n *node[K]) Next() *node[K] {
+	return n.next
+}
+
+// SetNext sets the next leaf node
+func (n *node[K]) SetNext(next *node[K]) {
+	n.next = next
+}
+
+// InsertKey inserts a key into a leaf node
+// Returns false if the key already exists.
+func (n *node[K]) InsertKey(key K, search searchFunc[K]) bool {
+	// Find position to insert and check if key already exists
+	pos, found := search(n.keys, key)
+	if found {
+		return false // Key already exists
+	}
+
+	n.keys = insertAt(n.keys, pos, key)
+	return true
+}
+
 // Type returns the type of the node
 func (n *GenericLeafNode[K]) Type() NodeType {
 	return Leaf
@@ -30,12 +55,12 @@ func (n *GenericLeafNode[K]) Keys() []K {
 
 // Next returns the next leaf node
 func (n *GenericLeafNode[K]) Next() *GenericLeafNode[K] {
-	return n.next
+	return (*GenericLeafNode[K])(n.next)
 }
 
 // SetNext sets the next leaf node
 func (n *GenericLeafNode[K]) SetNext(next *GenericLeafNode[K]) {
-	n.next = next
+	n.next = (*node[K])(next)
 }
 
 // KeyCount returns the number of keys in the node
@@ -45,41 +70,17 @@ func (n *GenericLeafNode[K]) KeyCount() int {
 
 // IsFull returns true if the node is full
 func (n *GenericLeafNode[K]) IsFull(branchingFactor int) bool {
-	return len(n.keys) >= branchingFactor
+	return (*node[K])(n).IsFull(branchingFactor)
 }
 
 // IsUnderflow returns true if the node has too few keys
 func (n *GenericLeafNode[K]) IsUnderflow(branchingFactor int) bool {
-	// For leaf nodes, minimum number of keys is ceil(m/2)
-	return len(n.keys) < minLeafKeys(branchingFactor)
+	return (*node[K])(n).IsUnderflow(branchingFactor)
 }
 
 // InsertKey inserts a key into the node
 func (n *GenericLeafNode[K]) InsertKey(key K, less func(a, b K) bool) bool {
-	// Find position to insert
-	pos := n.findInsertPosition(key, less)
-
-	// Check if key already exists
-	if pos < len(n.keys) {
-		// If neither key < n.keys[pos] nor n.keys[pos] < key, then they must be equal
-		if !less(key, n.keys[pos]) && !less(n.keys[pos], key) {
-			return false // Key already exists
-		}
-	}
-
-	// Insert key
-	n.keys = append(n.keys, *new(K)) // Add zero value of K
-	copy(n.keys[pos+1:], n.keys[pos:])
-	n.keys[pos] = key
-	return true
-}
-
-// findInsertPosition finds the position to insert a key
-func (n *GenericLeafNode[K]) findInsertPosition(key K, less func(a, b K) bool) int {
-	// Find the position to insert using binary search
-	return sort.Search(len(n.keys), func(i int) bool {
-		return !less(n.keys[i], key) // equivalent to n.keys[i] >= key
-	})
+	return (*node[K])(n).InsertKey(key, lessSearch(less))
 }
 
 // DeleteKey deletes a key from the node
@@ -88,21 +89,13 @@ func (n *GenericLeafNode[K]) DeleteKey(key K, equal func(a, b K) bool) bool {
 	if pos == -1 {
 		return false
 	}
-
-	// Remove key
-	copy(n.keys[pos:], n.keys[pos+1:])
-	n.keys = n.keys[:len(n.keys)-1]
+	n.keys = removeAt(n.keys, pos)
 	return true
 }
 
 // FindKey returns the index of the key in the node, or -1 if not found
 func (n *GenericLeafNode[K]) FindKey(key K, equal func(a, b K) bool) int {
-	for i, k := range n.keys {
-		if equal(k, key) {
-			return i
-		}
-	}
-	return -1
+	return findEqual(n.keys, key, equal)
 }
 
 // Contains returns true if the node contains the key
@@ -112,42 +105,16 @@ func (n *GenericLeafNode[K]) Contains(key K, equal func(a, b K) bool) bool {
 
 // MergeWith merges this node with another leaf node
 func (n *GenericLeafNode[K]) MergeWith(other *GenericLeafNode[K]) {
-	// Add all keys from the other node
-	n.keys = append(n.keys, other.keys...)
-
-	// Update the next pointer
-	n.next = other.next
+	var unused K
+	(*node[K])(n).MergeWith(unused, (*node[K])(other))
 }
 
 // BorrowFromRight borrows a key from the right sibling
 func (n *GenericLeafNode[K]) BorrowFromRight(rightSibling *GenericLeafNode[K], parentIndex int, parent *GenericBranchNode[K]) {
-	// Borrow the first key from the right sibling
-	borrowedKey := rightSibling.keys[0]
-
-	// Add the borrowed key to this node
-	n.keys = append(n.keys, borrowedKey)
-
-	// Remove the borrowed key from the right sibling
-	rightSibling.keys = rightSibling.keys[1:]
-
-	// Update the separator key in the parent
-	if len(rightSibling.keys) > 0 {
-		parent.keys[parentIndex] = rightSibling.keys[0]
-	}
+	(*node[K])(n).BorrowFromRight((*node[K])(rightSibling), parentIndex, (*node[K])(parent))
 }
 
 // BorrowFromLeft borrows a key from the left sibling
 func (n *GenericLeafNode[K]) BorrowFromLeft(leftSibling *GenericLeafNode[K], parentIndex int, parent *GenericBranchNode[K]) {
-	// Borrow the last key from the left sibling
-	lastKeyIndex := len(leftSibling.keys) - 1
-	borrowedKey := leftSibling.keys[lastKeyIndex]
-
-	// Insert the borrowed key at the beginning of this node's keys
-	n.keys = append([]K{borrowedKey}, n.keys...)
-
-	// Remove the borrowed key from the left sibling
-	leftSibling.keys = leftSibling.keys[:lastKeyIndex]
-
-	// Update the separator key in the parent
-	parent.keys[parentIndex-1] = n.keys[0]
+	(*node[K])(n).BorrowFromLeft((*node[K])(leftSibling), parentIndex, (*node[K])(parent))
 }
@@ -1,5 +1,10 @@
 package bplustree
 
+import (
+	"fmt"
+	"slices"
+)
+
 // NodeType represents the type of node (leaf or branch)
 type NodeType int
 
@@ -37,3 +42,258 @@ type GenericNode[K any] interface {
 	// Contains returns true if the node contains the key
 	Contains(key K, equal func(a, b K) bool) bool
 }
+
+// node is a node in the B+ tree. Leaves and branches share this one
+// concrete type, told apart by kind, so a descent follows plain pointers
+// instead of calling through an interface and switching on dynamic types.
+// The key and child slices are allocated once with room for a full node plus
+// the one key by which an insert overflows it before it is split, and are
+// shifted in place afterwards.
+//
+// GenericLeafNode and GenericBranchNode are views of a node with the methods
+// of the GenericNode interface; converting between them and node is free.
+type node[K any] struct {
+	kind     NodeType
+	keys     []K
+	children []*node[K]       // Children of a branch, one more than its keys
+	next     *node[K]         // Next leaf, for range queries
+	modified uint64           // Checkpoint ID current when a leaf's keys last changed
+	buffer   messageBuffer[K] // Pending inserts and deletes of a branch in a buffered tree
+}
+
+// searchFunc finds key in sorted keys. It returns the position of key and true if
+// key is present, or the position where key would be inserted and false.
+// It behaves like slices.BinarySearchFunc with the tree's compare function.
+type searchFunc[K any] func(keys []K, key K) (int, bool)
+
+// lessSearch returns a searchFunc that binary searches with less, treating keys
+// for which neither is less than the other as equal.
+func lessSearch[K any](less func(a, b K) bool) searchFunc[K] {
+	return compareSearch(func(a, b K) int {
+		if less(a, b) {
+			return -1
+		}
+		if less(b, a) {
+			return 1
+		}
+		return 0
+	})
+}
+
+// findEqual returns the index of the first key equal to key, or -1 if there is none.
+// Time complexity: O(n) where n is the number of keys.
+func findEqual[K any](keys []K, key K, equal func(a, b K) bool) int {
+	for i, k := range keys {
+		if equal(k, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// exportNode returns n as the GenericNode of its kind.
+func exportNode[K any](n *node[K]) GenericNode[K] {
+	if n.kind == Leaf {
+		return (*GenericLeafNode[K])(n)
+	}
+	return (*GenericBranchNode[K])(n)
+}
+
+// importNode returns the node behind a GenericNode created by this package.
+// It panics for other implementations, which a tree cannot hold.
+func importNode[K any](n GenericNode[K]) *node[K] {
+	switch n := n.(type) {
+	case *GenericLeafNode[K]:
+		return (*node[K])(n)
+	case *GenericBranchNode[K]:
+		return (*node[K])(n)
+	case nil:
+		return nil
+	default:
+		panic(fmt.Sprintf("bplustree: unsupported node type %T", n))
+	}
+}
+
+// compareSearch returns a searchFunc that binary searches with compare.
+func compareSearch[K any](compare func(a, b K) int) searchFunc[K] {
+	return func(keys []K, key K) (int, bool) {
+		return slices.BinarySearchFunc(keys, key, compare)
+	}
+}
+
+// Type returns the type of the node
+func (n *node[K]) Type() NodeType {
+	return n.kind
+}
+
+// IsLeaf returns true if the node is a leaf
+func (n *node[K]) IsLeaf() bool {
+	return n.kind == Leaf
+}
+
+// Keys returns the keys in the node
+func (n *node[K]) Keys() []K {
+	return n.keys
+}
+
+// KeyCount returns the number of keys in the node
+func (n *node[K]) KeyCount() int {
+	return len(n.keys)
+}
+
+// IsFull returns true if the node is full
+func (n *node[K]) IsFull(branchingFactor int) bool {
+	if n.kind == Leaf {
+		return len(n.keys) >= branchingFactor
+	}
+	return len(n.keys) >= branchingFactor-1
+}
+
+// overflows returns true if the node holds more keys than a full node.
+// An insert lets one node overflow at a time, and splits it right away.
+func (n *node[K]) overflows(branchingFactor int) bool {
+	if n.kind == Leaf {
+		return len(n.keys) > branchingFactor
+	}
+	return len(n.keys) > branchingFactor-1
+}
+
+// IsUnderflow returns true if the node has too few keys
+func (n *node[K]) IsUnderflow(branchingFactor int) bool {
+	if n.kind == Leaf {
+		// For leaf nodes, minimum number of keys is ceil(m/2)
+		return len(n.keys) < minLeafKeys(branchingFactor)
+	}
+	// For internal nodes, minimum number of keys is ceil(m/2)-1
+	return len(n.keys) < minInternalKeys(branchingFactor)
+}
+
+// FindKey returns the index of the key in the node, or -1 if not found
+// Time complexity: O(log B) where B is the branching factor.
+func (n *node[K]) FindKey(key K, search searchFunc[K]) int {
+	if pos, found := search(n.keys, key); found {
+		return pos
+	}
+	return -1
+}
+
+// Contains returns true if the node contains the key
+func (n *node[K]) Contains(key K, search searchFunc[K]) bool {
+	return n.FindKey(key, search) != -1
+}
+
+// DeleteKey deletes a key from the node.
+// A branch also loses the child to the right of the key.
+func (n *node[K]) DeleteKey(key K, search searchFunc[K]) bool {
+	pos := n.FindKey(key, search)
+	if pos == -1 {
+		return false
+	}
+
+	if n.kind == Leaf {
+		n.keys = removeAt(n.keys, pos)
+	} else {
+		n.removeKeyAndRightChild(pos)
+	}
+	return true
+}
+
+// MergeWith merges another node of the same kind, to the right of this one, into this node.
+// For branches the separator key from the parent moves down between the two
+// halves; leaves do not use it.
+func (n *node[K]) MergeWith(separatorKey K, other *node[K]) {
+	if n.kind == Leaf {
+		// Add all keys from the other node and take over its place in the leaf chain
+		n.keys = append(n.keys, other.keys...)
+		n.next = other.next
+		return
+	}
+
+	// Add the separator key, then all keys, children and pending messages from the other node
+	n.keys = append(n.keys, separatorKey)
+	n.keys = append(n.keys, other.keys...)
+	n.children = append(n.children, other.children...)
+	n.buffer.appendRange(&other.buffer, 0, other.buffer.len())
+}
+
+// BorrowFromRight borrows the first key of the right sibling.
+// For a branch the separator key from the parent moves down and the sibling's
+// first child moves along with it.
+func (n *node[K]) BorrowFromRight(rightSibling *node[K], parentIndex int, parent *node[K]) {
+	if n.kind == Leaf {
+		// Move the first key of the right sibling to the end of this node
+		n.keys = append(n.keys, rightSibling.keys[0])
+		rightSibling.keys = removeAt(rightSibling.keys, 0)
+
+		// Update the separator key in the parent
+		if len(rightSibling.keys) > 0 {
+			parent.keys[parentIndex] = rightSibling.keys[0]
+		}
+		return
+	}
+
+	// Add the separator key from parent and the first child of the right sibling to this node
+	n.keys = append(n.keys, parent.keys[parentIndex])
+	n.children = append(n.children, rightSibling.children[0])
+
+	// Update the separator key in the parent
+	parent.keys[parentIndex] = rightSibling.keys[0]
+
+	// Remove the borrowed key and child from the right sibling
+	rightSibling.keys = removeAt(rightSibling.keys, 0)
+	rightSibling.children = removeAt(rightSibling.children, 0)
+}
+
+// BorrowFromLeft borrows the last key of the left sibling.
+// For a branch the separator key from the parent moves down and the sibling's
+// last child moves along with it.
+func (n *node[K]) BorrowFromLeft(leftSibling *node[K], parentIndex int, parent *node[K]) {
+	lastKeyIndex := len(leftSibling.keys) - 1
+
+	if n.kind == Leaf {
+		// Move the last key of the left sibling to the beginning of this node
+		n.keys = insertAt(n.keys, 0, leftSibling.keys[lastKeyIndex])
+		leftSibling.keys = truncate(leftSibling.keys, lastKeyIndex)
+
+		// Update the separator key in the parent
+		parent.keys[parentIndex-1] = n.keys[0]
+		return
+	}
+
+	// Insert the separator key and the last child of the left sibling at the beginning of this node
+	lastChildIndex := len(leftSibling.children) - 1
+	n.keys = insertAt(n.keys, 0, parent.keys[parentIndex-1])
+	n.children = insertAt(n.children, 0, leftSibling.children[lastChildIndex])
+
+	// Update the separator key in the parent
+	parent.keys[parentIndex-1] = leftSibling.keys[lastKeyIndex]
+
+	// Remove the borrowed key and child from the left sibling
+	leftSibling.keys = truncate(leftSibling.keys, lastKeyIndex)
+	leftSibling.children = truncate(leftSibling.children, lastChildIndex)
+}
+
+// insertAt inserts value at index i, shifting the following elements right.
+// The slice grows in place when it has spare capacity.
+func insertAt[T any](s []T, i int, value T) []T {
+	var zero T
+	s = append(s, zero)
+	copy(s[i+1:], s[i:])
+	s[i] = value
+	return s
+}
+
+// removeAt removes the element at index i, shifting the following elements left.
+// The freed slot is zeroed so it does not keep a removed value alive.
+func removeAt[T any](s []T, i int) []T {
+	copy(s[i:], s[i+1:])
+	var zero T
+	s[len(s)-1] = zero
+	return s[:len(s)-1]
+}
+
+// truncate shortens s to n elements, zeroing the elements it drops.
+func truncate[T any](s []T, n int) []T {
+	clear(s[n:])
+	return s[:n]
+}
@@ -5,14 +5,14 @@ import (
 )
 
 // GenericSet represents a set of values of type K implemented using a generic B+ tree
-// K must be a comparable type
-type GenericSet[K comparable] struct {
+// K can be any type the comparison functions order
+type GenericSet[K any] struct {
 	tree *GenericBPlusTree[K]
 }
 
 // NewGenericSet creates a new set with the given branching factor
 // and comparison functions
-func NewGenericSet[K comparable](
+func NewGenericSet[K any](
 	branchingFactor int,
 	less func(a, b K) bool,
 	equal func(a, b K) bool,
@@ -44,22 +44,9 @@ func NewIntSet(branchingFactor int) *GenericSet[int] {
 }
 
 // NewStringSet creates a new set for string values
+// Strings are hashed with FNV-1a, see NewOrderedSet.
 func NewStringSet(branchingFactor int) *GenericSet[string] {
-	// Simple hash function for strings
-	stringHash := func(s string) uint64 {
-		var hash uint64
-		for i := 0; i < len(s); i++ {
-			hash = hash*31 + uint64(s[i])
-		}
-		return hash
-	}
-
-	return NewGenericSet(
-		branchingFactor,
-		func(a, b string) bool { return a < b },
-		func(a, b string) bool { return a == b },
-		stringHash,
-	)
+	return NewOrderedSet[string](branchingFactor)
 }
 
 // Add adds a value to the set
@@ -90,12 +77,9 @@ func (s *GenericSet[K]) IsEmpty() bool {
 }
 
 // Clear removes all elements from the set
+// The set keeps the configuration of its tree, see GenericBPlusTree.Clear.
 func (s *GenericSet[K]) Clear() {
-	branchingFactor := s.tree.branchingFactor
-	less := s.tree.less
-	equal := s.tree.equal
-	hashFunc := s.tree.hashFunc
-	s.tree = NewGenericBPlusTree(branchingFactor, less, equal, hashFunc)
+	s.tree.Clear()
 }
 
 // GetAll returns all elements in the set
@@ -107,7 +91,7 @@ func (s *GenericSet[K]) GetAll() []K {
 func (s *GenericSet[K]) SortedSlice() []K {
 	result := s.GetAll()
 	sort.Slice(result, func(i, j int) bool {
-		return s.tree.less(result[i], result[j])
+		return s.tree.compare(result[i], result[j]) < 0
 	})
 	return result
 }
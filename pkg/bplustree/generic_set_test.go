@@ -282,3 +282,28 @@ func TestGenericSetSortedSlice(t *testing.T) {
 		}
 	}
 }
+
+// TestGenericSetClearKeepsConfiguration tests that a cleared set keeps the settings of its tree
+func TestGenericSetClearKeepsConfiguration(t *testing.T) {
+	set := NewOrderedSet[uint64](8)
+	set.tree.SetAdaptiveFilter(0.5, 100)
+	set.tree.SetRangeFilter(NewUint64RangeFilter(1000, 0.01))
+	for value := uint64(0); value < 1000; value++ {
+		set.Add(value)
+	}
+
+	set.Clear()
+
+	if !set.IsEmpty() {
+		t.Fatalf("Expected an empty set after Clear, got %d values", set.Size())
+	}
+	if set.tree.adaptive == nil || set.tree.rangeFilter == nil {
+		t.Errorf("Expected the adaptive filter and range filter to survive Clear")
+	}
+	for value := uint64(0); value < 1000; value += 3 {
+		set.Add(value)
+	}
+	if !set.Contains(999) || set.Contains(998) || set.Size() != 334 {
+		t.Errorf("Expected 334 values after refilling the cleared set, got %d", set.Size())
+	}
+}
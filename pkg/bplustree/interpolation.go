@@ -0,0 +1,108 @@
+package bplustree
+
+import (
+	"errors"
+	"slices"
+)
+
+// Integer is the set of key types that interpolation search works with.
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// ErrNotNaturalOrder is returned by SetInterpolationSearch for a tree that may
+// not order its keys like cmp.Compare.
+var ErrNotNaturalOrder = errors.New("bplustree: tree does not order keys like cmp.Compare")
+
+const (
+	// maxInterpolationProbes is the number of guesses after which a search
+	// inside a node falls back to binary search on the keys that are left,
+	// so that skewed keys cost only a few extra comparisons.
+	maxInterpolationProbes = 3
+
+	// interpolationScan is the number of keys checked one by one next to a
+	// guess. For uniform keys the key is usually among them, and a short scan
+	// has no unpredictable branches, unlike binary search.
+	interpolationScan = 8
+)
+
+// SetInterpolationSearch selects interpolation search (enabled) or binary
+// search for finding keys inside the nodes of t.
+//
+// Interpolation search guesses the position of a key from its value relative to
+// the first and last keys of a node, then checks the few keys next to the guess.
+// For roughly uniform keys, such as hashes or random IDs, it finds keys in large
+// nodes faster than binary search. After maxInterpolationProbes guesses it
+// switches to binary search on the keys that are left, so skewed keys stay cheap.
+//
+// Guesses only make sense if t orders keys like cmp.Compare, so it can be
+// enabled only for trees from NewOrderedTree and NewBufferedOrderedTree;
+// for other trees ErrNotNaturalOrder is returned and t is unchanged.
+// Disabling it restores the search t used before it was enabled.
+// Time complexity: O(1)
+func SetInterpolationSearch[K Integer](t *GenericBPlusTree[K], enabled bool) error {
+	if enabled {
+		if !t.naturalOrder {
+			return ErrNotNaturalOrder
+		}
+		if t.savedSearch == nil {
+			t.savedSearch = t.search
+		}
+		t.search = interpolationSearch[K]
+	} else if t.savedSearch != nil {
+		t.search = t.savedSearch
+		t.savedSearch = nil
+	}
+	return nil
+}
+
+// interpolationSearch finds key in sorted keys like slices.BinarySearch,
+// guessing positions from key values. See SetInterpolationSearch.
+// Time complexity: O(1) expected for uniform keys and O(log B) at worst,
+// where B is the number of keys.
+func interpolationSearch[K Integer](keys []K, key K) (int, bool) {
+	// The position of key is in [lo, hi]
+	lo, hi := 0, len(keys)
+	for probe := 0; probe < maxInterpolationProbes && lo < hi; probe++ {
+		first, last := keys[lo], keys[hi-1]
+		if key <= first {
+			return lo, key == first
+		}
+		if key >= last {
+			if key == last {
+				return hi - 1, true
+			}
+			return hi, false
+		}
+
+		// first < key < last, so there are at least two keys to choose from.
+		// Compute in float64 so differences of signed keys cannot overflow.
+		fraction := (float64(key) - float64(first)) / (float64(last) - float64(first))
+		pos := lo + int(fraction*float64(hi-1-lo))
+		pos = min(max(pos, lo+1), hi-1)
+
+		// Scan a few keys from the guess towards the key
+		if keys[pos] < key {
+			end := min(pos+interpolationScan, hi)
+			for i := pos + 1; i < end; i++ {
+				if keys[i] >= key {
+					return i, keys[i] == key
+				}
+			}
+			lo = end
+		} else {
+			start := max(pos-interpolationScan, lo)
+			for i := pos - 1; i >= start; i-- {
+				if keys[i] < key {
+					return i + 1, keys[i+1] == key
+				}
+			}
+			hi = start + 1
+		}
+	}
+
+	// Fall back to binary search on what is left
+	i, found := slices.BinarySearch(keys[lo:hi], key)
+	return lo + i, found
+}
@@ -0,0 +1,130 @@
+package bplustree
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// TestInterpolationSearchMatchesBinarySearch tests that interpolation search
+// finds the same positions as binary search for uniform, skewed and signed keys
+func TestInterpolationSearchMatchesBinarySearch(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	uniform := make([]uint64, 1000)
+	for i := range uniform {
+		uniform[i] = r.Uint64()
+	}
+	skewed := make([]uint64, 1000)
+	for i := range skewed {
+		skewed[i] = uint64(i * i * i)
+	}
+	for _, keys := range [][]uint64{uniform, skewed, {}, {7}, {3, 9}, {0, math.MaxUint64}} {
+		slices.Sort(keys)
+		keys = slices.Compact(keys)
+		probes := append(slices.Clone(keys), 0, 1, math.MaxUint64)
+		for i := 0; i < 1000; i++ {
+			probes = append(probes, r.Uint64(), uint64(r.Intn(1_000_000_000)))
+		}
+		for _, key := range probes {
+			pos, found := interpolationSearch(keys, key)
+			wantPos, wantFound := slices.BinarySearch(keys, key)
+			if pos != wantPos || found != wantFound {
+				t.Fatalf("Expected (%d, %v) for key %d in %d keys, got (%d, %v)", wantPos, wantFound, key, len(keys), pos, found)
+			}
+		}
+	}
+
+	signed := []int64{math.MinInt64, -5, 0, 3, math.MaxInt64}
+	for _, key := range []int64{math.MinInt64, math.MinInt64 + 1, -5, -1, 0, 2, 3, math.MaxInt64 - 1, math.MaxInt64} {
+		pos, found := interpolationSearch(signed, key)
+		wantPos, wantFound := slices.BinarySearch(signed, key)
+		if pos != wantPos || found != wantFound {
+			t.Errorf("Expected (%d, %v) for key %d, got (%d, %v)", wantPos, wantFound, key, pos, found)
+		}
+	}
+}
+
+// TestSetInterpolationSearch tests a tree that searches its nodes by interpolation
+func TestSetInterpolationSearch(t *testing.T) {
+	tree := NewOrderedTree[uint64](64)
+	if err := SetInterpolationSearch(tree, true); err != nil {
+		t.Fatalf("SetInterpolationSearch failed: %v", err)
+	}
+
+	r := rand.New(rand.NewSource(2))
+	present := make(map[uint64]bool)
+	for i := 0; i < 20000; i++ {
+		key := r.Uint64() >> 40
+		if r.Intn(4) == 0 {
+			tree.Delete(key)
+			delete(present, key)
+		} else {
+			tree.Insert(key)
+			present[key] = true
+		}
+	}
+	checkTreeInvariants(t, tree)
+
+	for key := range present {
+		if !tree.Contains(key) {
+			t.Fatalf("Expected tree to contain %d", key)
+		}
+	}
+	if got := tree.CountRange(0, math.MaxUint64); got != len(present) {
+		t.Errorf("Expected %d keys in the full range, got %d", len(present), got)
+	}
+
+	SetInterpolationSearch(tree, false)
+	checkTreeInvariants(t, tree)
+}
+
+// TestSetInterpolationSearchRestoresSearch tests that disabling interpolation
+// search keeps a tree's own search
+func TestSetInterpolationSearchRestoresSearch(t *testing.T) {
+	tree := NewOrderedTree[int](16)
+	for _, enabled := range []bool{false, true, true, false} {
+		if err := SetInterpolationSearch(tree, enabled); err != nil {
+			t.Fatalf("SetInterpolationSearch(%v) failed: %v", enabled, err)
+		}
+	}
+	if tree.savedSearch != nil {
+		t.Fatalf("Expected no saved search after disabling interpolation search")
+	}
+
+	for key := 0; key < 1000; key++ {
+		tree.Insert(key)
+	}
+	checkTreeInvariants(t, tree)
+}
+
+// TestSetInterpolationSearchRequiresNaturalOrder tests that interpolation search
+// is refused for trees that may order keys otherwise
+func TestSetInterpolationSearchRequiresNaturalOrder(t *testing.T) {
+	descending := NewOrderedTreeFunc(16, func(a, b int) int { return b - a })
+	lessEqual := NewGenericBPlusTree(16,
+		func(a, b int) bool { return a < b },
+		func(a, b int) bool { return a == b },
+		func(v int) uint64 { return uint64(v) },
+	)
+	for _, tree := range []*GenericBPlusTree[int]{descending, lessEqual} {
+		if err := SetInterpolationSearch(tree, true); !errors.Is(err, ErrNotNaturalOrder) {
+			t.Fatalf("Expected ErrNotNaturalOrder, got %v", err)
+		}
+		if err := SetInterpolationSearch(tree, false); err != nil {
+			t.Fatalf("Expected disabling to succeed, got %v", err)
+		}
+
+		for key := 0; key < 1000; key++ {
+			tree.Insert(key)
+		}
+		checkTreeInvariants(t, tree)
+		for key := 0; key < 1000; key++ {
+			if !tree.Contains(key) {
+				t.Fatalf("Expected tree to contain %d", key)
+			}
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package bplustree
+
+import (
+	"math"
+	"slices"
+	"unsafe"
+)
+
+// LearnedIndex is a read-only index over uint64 keys, created from a tree by Freeze.
+//
+// It stores the keys in one sorted array, as if in completely full leaves laid
+// out one after the other, so a key's position also tells its leaf and its
+// place in the leaf. Instead of branch nodes it has a piecewise linear model
+// that maps each key to its position, off by at most the error bound given to
+// Freeze. A lookup finds the segment of the model that covers the key,
+// predicts the key's position from it and binary searches only the few keys
+// around that position.
+type LearnedIndex struct {
+	keys      []uint64         // All keys in sorted order
+	firstKeys []uint64         // First key covered by each segment, in sorted order
+	segments  []learnedSegment // Segments of the model, one per entry of firstKeys
+	maxError  int              // Largest distance between a predicted and an actual position
+}
+
+// learnedSegment is a line that predicts the positions of the keys from its
+// first key up to the first key of the next segment.
+type learnedSegment struct {
+	start int     // Position of the segment's first key
+	slope float64 // Positions per unit of key value
+}
+
+// LearnedIndexStats describes the model of a LearnedIndex.
+type LearnedIndexStats struct {
+	Keys       int // Number of keys in the index
+	Segments   int // Number of linear segments in the model
+	ModelBytes int // Memory used by the model, not counting the keys
+	MaxError   int // Largest distance between a predicted and an actual position
+}
+
+// Freeze creates a LearnedIndex holding the keys of t. The tree is not
+// changed, and later changes to it do not affect the index.
+//
+// The model is built in one pass over the keys: a segment grows as long as a
+// line through its first key can predict the position of every key it covers
+// to within maxError, and a new segment starts where that is no longer possible.
+// A larger maxError makes the model smaller and the search around each
+// prediction longer. A negative maxError is treated as 0.
+//
+// t must order keys like cmp.Compare, as trees from NewOrderedTree do.
+// Time complexity: O(n) where n is the number of keys in the tree.
+func Freeze(t *GenericBPlusTree[uint64], maxError int) *LearnedIndex {
+	maxError = max(maxError, 0)
+
+	keys := make([]uint64, 0, t.size)
+	t.Flush()
+	t.collectKeys(t.root, &keys)
+
+	idx := &LearnedIndex{keys: keys}
+	idx.fit(float64(maxError))
+	idx.maxError = idx.measureError()
+	return idx
+}
+
+// fit builds the segments of the model, each predicting positions to within maxError.
+//
+// A segment starting at key k0 in position p0 predicts p0 + slope*(k-k0) for key k.
+// Every key k in position p narrows the slopes that predict p to within maxError;
+// the segment ends before the first key that leaves no slope, and takes the slope
+// in the middle of those that remain.
+// Time complexity: O(n) where n is the number of keys.
+func (idx *LearnedIndex) fit(maxError float64) {
+	keys := idx.keys
+	for start := 0; start < len(keys); {
+		// Positions increase with keys, so a negative slope never helps
+		low, high := 0.0, math.Inf(1)
+		end := start + 1
+		for ; end < len(keys); end++ {
+			dx := float64(keys[end] - keys[start])
+			dy := float64(end - start)
+			newLow := max(low, (dy-maxError)/dx)
+			newHigh := min(high, (dy+maxError)/dx)
+			if newLow > newHigh {
+				break
+			}
+			low, high = newLow, newHigh
+		}
+
+		// A segment of only the last key has no upper bound on its slope
+		slope := low
+		if end > start+1 {
+			slope = (low + high) / 2
+		}
+		idx.firstKeys = append(idx.firstKeys, keys[start])
+		idx.segments = append(idx.segments, learnedSegment{start: start, slope: slope})
+		start = end
+	}
+}
+
+// measureError returns the largest distance between the predicted and the
+// actual position of any key. Lookups search this far around each prediction,
+// which also covers rounding in the predictions.
+// Time complexity: O(n) where n is the number of keys.
+func (idx *LearnedIndex) measureError() int {
+	maxError := 0
+	for pos, key := range idx.keys {
+		predicted := idx.predict(key)
+		maxError = max(maxError, predicted-pos, pos-predicted)
+	}
+	return maxError
+}
+
+// predict returns the position of key according to the model, or the position
+// key would have if it is not in the index.
+// Time complexity: O(log s) where s is the number of segments.
+func (idx *LearnedIndex) predict(key uint64) int {
+	// Find the last segment starting at or before key
+	i, found := slices.BinarySearch(idx.firstKeys, key)
+	if !found {
+		if i == 0 {
+			return 0
+		}
+		i--
+	}
+
+	// Predict no further than the first position of the next segment, rounding to the nearest position
+	segment := idx.segments[i]
+	limit := len(idx.keys)
+	if i+1 < len(idx.segments) {
+		limit = idx.segments[i+1].start
+	}
+	offset := min(segment.slope*float64(key-idx.firstKeys[i]), float64(limit-segment.start))
+	return segment.start + int(offset+0.5)
+}
+
+// search returns the position of key in the index, or where it would be if it
+// is not in the index, and whether it is there.
+// Time complexity: O(log s + log e) where s is the number of segments and e is the maximum error.
+func (idx *LearnedIndex) search(key uint64) (int, bool) {
+	if len(idx.keys) == 0 {
+		return 0, false
+	}
+
+	// Predictions grow with keys and are off by at most maxError,
+	// so the position of key is within maxError of its prediction
+	predicted := idx.predict(key)
+	lo := max(predicted-idx.maxError, 0)
+	hi := min(predicted+idx.maxError+1, len(idx.keys))
+	i, found := slices.BinarySearch(idx.keys[lo:hi], key)
+	return lo + i, found
+}
+
+// Contains returns true if key is in the index.
+// Time complexity: O(log s + log e) where s is the number of segments and e is the maximum error.
+func (idx *LearnedIndex) Contains(key uint64) bool {
+	_, found := idx.search(key)
+	return found
+}
+
+// RangeQuery returns all keys in the range [start, end], inclusive.
+// The keys are returned in sorted order.
+// Time complexity: O(log s + log e + k) where s is the number of segments,
+// e is the maximum error and k is the number of keys in the range.
+func (idx *LearnedIndex) RangeQuery(start, end uint64) []uint64 {
+	lo, hi := idx.rangeBounds(start, end)
+	return append(make([]uint64, 0, hi-lo), idx.keys[lo:hi]...)
+}
+
+// CountRange returns the number of keys in the range [start, end], inclusive,
+// without collecting them.
+// Time complexity: O(log s + log e) where s is the number of segments and e is the maximum error.
+func (idx *LearnedIndex) CountRange(start, end uint64) int {
+	lo, hi := idx.rangeBounds(start, end)
+	return hi - lo
+}
+
+// rangeBounds returns the positions of the first key in [start, end] and of
+// the first key after it. Both are equal if the range is empty.
+// Time complexity: O(log s + log e) where s is the number of segments and e is the maximum error.
+func (idx *LearnedIndex) rangeBounds(start, end uint64) (int, int) {
+	if start > end {
+		return 0, 0
+	}
+	lo, _ := idx.search(start)
+	hi, found := idx.search(end)
+	if found {
+		hi++
+	}
+	return lo, hi
+}
+
+// Size returns the number of keys in the index.
+// Time complexity: O(1)
+func (idx *LearnedIndex) Size() int {
+	return len(idx.keys)
+}
+
+// Stats returns the size and the maximum error of the model.
+// Time complexity: O(1)
+func (idx *LearnedIndex) Stats() LearnedIndexStats {
+	segmentBytes := int(unsafe.Sizeof(uint64(0)) + unsafe.Sizeof(learnedSegment{}))
+	return LearnedIndexStats{
+		Keys:       len(idx.keys),
+		Segments:   len(idx.segments),
+		ModelBytes: len(idx.segments) * segmentBytes,
+		MaxError:   idx.maxError,
+	}
+}
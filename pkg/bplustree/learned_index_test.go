@@ -0,0 +1,98 @@
+package bplustree
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// TestFreezeMatchesTree tests that a learned index answers lookups and range
+// queries like the tree it was frozen from, for uniform, sequential and skewed keys
+func TestFreezeMatchesTree(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	uniform := make([]uint64, 20000)
+	for i := range uniform {
+		uniform[i] = r.Uint64()
+	}
+	sequential := make([]uint64, 20000)
+	for i := range sequential {
+		sequential[i] = uint64(1000 + 3*i)
+	}
+	skewed := make([]uint64, 20000)
+	for i := range skewed {
+		skewed[i] = uint64(i * i * i)
+	}
+
+	for _, keys := range [][]uint64{uniform, sequential, skewed, {}, {7}, {0, math.MaxUint64}} {
+		tree := NewOrderedTree[uint64](32)
+		for _, key := range keys {
+			tree.Insert(key)
+		}
+
+		for _, maxError := range []int{-1, 0, 4, 64} {
+			idx := Freeze(tree, maxError)
+			stats := idx.Stats()
+			if idx.Size() != tree.Size() || stats.Keys != tree.Size() {
+				t.Fatalf("Expected %d keys, got Size %d and Stats.Keys %d", tree.Size(), idx.Size(), stats.Keys)
+			}
+			if stats.MaxError > max(maxError, 0) {
+				t.Errorf("Expected a maximum error of at most %d for %d keys, got %d", maxError, len(keys), stats.MaxError)
+			}
+
+			probes := []uint64{0, 1, math.MaxUint64 - 1, math.MaxUint64}
+			for _, key := range keys {
+				probes = append(probes, key, key-1, key+1)
+			}
+			for i := 0; i < 1000; i++ {
+				probes = append(probes, r.Uint64(), uint64(r.Intn(len(keys)+1)*3))
+			}
+			for _, key := range probes {
+				if got, want := idx.Contains(key), tree.Contains(key); got != want {
+					t.Fatalf("Expected Contains(%d) = %v with maximum error %d, got %v", key, want, maxError, got)
+				}
+			}
+
+			for i := 0; i < 200; i++ {
+				start, end := probes[r.Intn(len(probes))], probes[r.Intn(len(probes))]
+				want := tree.RangeQuery(start, end)
+				if got := idx.RangeQuery(start, end); !slices.Equal(got, want) {
+					t.Fatalf("Expected RangeQuery(%d, %d) to return %d keys, got %d", start, end, len(want), len(got))
+				}
+				if got := idx.CountRange(start, end); got != len(want) {
+					t.Fatalf("Expected CountRange(%d, %d) = %d, got %d", start, end, len(want), got)
+				}
+			}
+		}
+	}
+}
+
+// TestFreezeModelSize tests that keys on a line need one segment and that a
+// larger error bound needs fewer segments for random keys
+func TestFreezeModelSize(t *testing.T) {
+	tree := NewOrderedTree[uint64](64)
+	for i := uint64(0); i < 10000; i++ {
+		tree.Insert(500 + 7*i)
+	}
+	if stats := Freeze(tree, 0).Stats(); stats.Segments != 1 || stats.MaxError != 0 {
+		t.Errorf("Expected one exact segment for evenly spaced keys, got %+v", stats)
+	}
+
+	tree.Clear()
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 10000; i++ {
+		tree.Insert(r.Uint64())
+	}
+	exact, loose := Freeze(tree, 2).Stats(), Freeze(tree, 64).Stats()
+	if loose.Segments >= exact.Segments || loose.ModelBytes >= exact.ModelBytes {
+		t.Errorf("Expected fewer segments with a larger error bound, got %+v and %+v", exact, loose)
+	}
+
+	// The index keeps the keys at the time of the freeze
+	idx := Freeze(tree, 16)
+	tree.Clear()
+	if idx.Size() != 10000 || idx.CountRange(0, math.MaxUint64) != 10000 {
+		t.Errorf("Expected the index to keep 10000 keys after the tree was cleared, got %d", idx.Size())
+	}
+}
@@ -0,0 +1,135 @@
+package bplustree
+
+const (
+	// maxArenaNodes is the largest number of nodes carved out of one arena.
+	maxArenaNodes = 64
+
+	// maxFreeKeys bounds the key slots of the nodes of each kind a pool keeps
+	// for reuse. Nodes freed beyond it are left to the garbage collector, so a
+	// tree that shrinks a lot does not hold on to all of its old nodes.
+	maxFreeKeys = 1 << 16
+)
+
+// nodePool hands out the nodes of one tree and takes them back.
+//
+// Nodes that merges, root collapses and Clear remove from the tree go onto a
+// free list and are handed out again before anything new is allocated. When a
+// free list is empty, nodes are carved out of arenas: one allocation holds a
+// batch of nodes and one more holds all of their key arrays (and child arrays,
+// for branches). Arenas double in size up to maxArenaNodes, so small trees stay small.
+type nodePool[K any] struct {
+	branchingFactor int
+	freeLeaves      []*node[K] // Leaves ready for reuse, with empty key slices
+	freeBranches    []*node[K] // Branches ready for reuse, with empty key and child slices
+	leafArena       []node[K]  // Leaves not handed out yet
+	branchArena     []node[K]  // Branches not handed out yet
+	allocated       int        // Number of nodes carved out of arenas so far
+	maxFree         int        // Most nodes of each kind kept for reuse
+}
+
+// newNodePool creates a pool of nodes with room for branchingFactor children.
+func newNodePool[K any](branchingFactor int) nodePool[K] {
+	return nodePool[K]{
+		branchingFactor: branchingFactor,
+		maxFree:         max(maxFreeKeys/(branchingFactor+1), 1),
+	}
+}
+
+// newLeaf returns an empty leaf, reusing a freed one if there is any.
+// Time complexity: O(1) amortized.
+func (p *nodePool[K]) newLeaf() *node[K] {
+	if n := len(p.freeLeaves); n > 0 {
+		leaf := p.freeLeaves[n-1]
+		p.freeLeaves[n-1] = nil
+		p.freeLeaves = p.freeLeaves[:n-1]
+		return leaf
+	}
+
+	if len(p.leafArena) == 0 {
+		// Allocate a batch of leaves and their keys, like newLeafNode would
+		count := p.arenaSize()
+		keyCap := p.branchingFactor + 1
+		p.leafArena = make([]node[K], count)
+		keys := make([]K, count*keyCap)
+		for i := range p.leafArena {
+			p.leafArena[i] = node[K]{
+				kind: Leaf,
+				keys: keys[i*keyCap : i*keyCap : (i+1)*keyCap],
+			}
+		}
+	}
+
+	leaf := &p.leafArena[0]
+	p.leafArena = p.leafArena[1:]
+	return leaf
+}
+
+// newBranch returns an empty branch, reusing a freed one if there is any.
+// Time complexity: O(1) amortized.
+func (p *nodePool[K]) newBranch() *node[K] {
+	if n := len(p.freeBranches); n > 0 {
+		branch := p.freeBranches[n-1]
+		p.freeBranches[n-1] = nil
+		p.freeBranches = p.freeBranches[:n-1]
+		return branch
+	}
+
+	if len(p.branchArena) == 0 {
+		// Allocate a batch of branches with their keys and children, like newBranchNode would
+		count := p.arenaSize()
+		keyCap, childCap := p.branchingFactor, p.branchingFactor+1
+		p.branchArena = make([]node[K], count)
+		keys := make([]K, count*keyCap)
+		children := make([]*node[K], count*childCap)
+		for i := range p.branchArena {
+			p.branchArena[i] = node[K]{
+				kind:     Branch,
+				keys:     keys[i*keyCap : i*keyCap : (i+1)*keyCap],
+				children: children[i*childCap : i*childCap : (i+1)*childCap],
+			}
+		}
+	}
+
+	branch := &p.branchArena[0]
+	p.branchArena = p.branchArena[1:]
+	return branch
+}
+
+// arenaSize returns the number of nodes to allocate in the next arena,
+// which doubles with the number of nodes allocated so far.
+func (p *nodePool[K]) arenaSize() int {
+	count := min(max(p.allocated, 1), maxArenaNodes)
+	p.allocated += count
+	return count
+}
+
+// free takes back a node that is no longer part of the tree.
+// The node is emptied, so it does not keep keys or other nodes alive.
+// Time complexity: O(B) where B is the branching factor.
+func (p *nodePool[K]) free(n *node[K]) {
+	n.keys = truncate(n.keys, 0)
+	n.children = truncate(n.children, 0)
+	n.next = nil
+	n.modified = 0
+	n.buffer.reset()
+
+	switch n.kind {
+	case Leaf:
+		if len(p.freeLeaves) < p.maxFree {
+			p.freeLeaves = append(p.freeLeaves, n)
+		}
+	case Branch:
+		if len(p.freeBranches) < p.maxFree {
+			p.freeBranches = append(p.freeBranches, n)
+		}
+	}
+}
+
+// freeSubtree takes back every node of the subtree rooted at n.
+// Time complexity: O(n) where n is the number of keys in the subtree.
+func (p *nodePool[K]) freeSubtree(n *node[K]) {
+	for _, child := range n.children {
+		p.freeSubtree(child)
+	}
+	p.free(n)
+}
@@ -0,0 +1,59 @@
+package bplustree
+
+import "testing"
+
+// TestNodePoolRecyclesMergedNodes tests that nodes removed by merges are
+// reused by later splits instead of being allocated again
+func TestNodePoolRecyclesMergedNodes(t *testing.T) {
+	tree := NewBPlusTreeWithOptions(8, false)
+	for i := 0; i < 1000; i++ {
+		tree.Insert(uint64(i))
+	}
+	for i := 0; i < 1000; i++ {
+		tree.Delete(uint64(i))
+	}
+
+	freed := len(tree.pool.freeLeaves)
+	if freed == 0 || len(tree.pool.freeBranches) == 0 {
+		t.Fatalf("Expected merged leaves and branches in the pool, got %d and %d", freed, len(tree.pool.freeBranches))
+	}
+	for _, leaf := range tree.pool.freeLeaves {
+		if len(leaf.keys) != 0 || leaf.next != nil {
+			t.Fatalf("Expected freed leaves to be empty")
+		}
+	}
+
+	// Churning the same keys again allocates no nodes
+	allocs := testing.AllocsPerRun(10, func() {
+		for i := 0; i < 1000; i++ {
+			tree.Insert(uint64(i))
+		}
+		for i := 0; i < 1000; i++ {
+			tree.Delete(uint64(i))
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations when churning keys, got %v", allocs)
+	}
+	checkTreeInvariants(t, tree)
+}
+
+// TestNodePoolClear tests that Clear recycles the nodes of the tree
+func TestNodePoolClear(t *testing.T) {
+	tree := NewBPlusTree(4)
+	for i := 0; i < 100; i++ {
+		tree.Insert(uint64(i))
+	}
+	tree.Clear()
+
+	if len(tree.pool.freeLeaves) == 0 || len(tree.pool.freeBranches) == 0 {
+		t.Errorf("Expected Clear to return the nodes to the pool")
+	}
+	for i := 0; i < 100; i++ {
+		tree.Insert(uint64(i * 2))
+	}
+	checkTreeInvariants(t, tree)
+	if !tree.Contains(42) || tree.Contains(43) {
+		t.Errorf("Expected the tree to hold the keys inserted after Clear")
+	}
+}
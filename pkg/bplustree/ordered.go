@@ -0,0 +1,147 @@
+package bplustree
+
+import (
+	"cmp"
+	"hash/maphash"
+	"math"
+	"reflect"
+	"slices"
+)
+
+// orderedHashSeed seeds the bloom filter hash of trees built by NewOrderedTreeFunc.
+// It is shared by all trees in a process, so their filters can be combined with
+// Union, but differs between processes, so filters of these trees must not be
+// stored and loaded by another process.
+var orderedHashSeed = maphash.MakeSeed()
+
+// NewOrderedTree creates a new B+ tree for any ordered key type.
+// Keys are ordered by cmp.Compare and hashed for the bloom filter by orderedHash,
+// which gives every key the same hash in every process, so no comparison or
+// hash functions are needed.
+// Searches inside nodes use the ordering of K directly, making lookups faster
+// than in trees built from comparison functions.
+// Floating-point NaNs are ordered before all other values and equal to each other.
+//
+// Parameters:
+//   - branchingFactor: The maximum number of children per node. Must be at least 3.
+//
+// Returns a new empty B+ tree with a bloom filter enabled for faster lookups.
+func NewOrderedTree[K cmp.Ordered](branchingFactor int) *GenericBPlusTree[K] {
+	tree := NewGenericBPlusTreeWithCompare(branchingFactor, cmp.Compare[K], orderedHash[K])
+
+	// Search nodes with a binary search the compiler specializes for K, whose
+	// comparisons are inlined rather than called through compare
+	tree.search = slices.BinarySearch[[]K, K]
+	tree.naturalOrder = true
+	return tree
+}
+
+// NewOrderedTreeFunc creates a new B+ tree ordered by compare, which returns a
+// negative number if a < b, zero if a == b and a positive number if a > b, like
+// cmp.Compare. It suits struct keys, for example ordered field by field with cmp.Or.
+// Keys are hashed for the bloom filter with maphash.Comparable, whose seed
+// differs between processes.
+//
+// Keys that compare equal must also be equal under ==, or the bloom filter may
+// rule out keys that are present. For orderings where this does not hold, such
+// as case-insensitive strings, use NewGenericBPlusTreeWithCompare with a matching hash.
+//
+// Parameters:
+//   - branchingFactor: The maximum number of children per node. Must be at least 3.
+//   - compare: The function that orders keys.
+//
+// Returns a new empty B+ tree with a bloom filter enabled for faster lookups.
+func NewOrderedTreeFunc[K comparable](branchingFactor int, compare func(a, b K) int) *GenericBPlusTree[K] {
+	return NewGenericBPlusTreeWithCompare(branchingFactor, compare, comparableHash[K])
+}
+
+// NewOrderedSet creates a new set for any ordered value type, see NewOrderedTree.
+func NewOrderedSet[K cmp.Ordered](branchingFactor int) *GenericSet[K] {
+	return &GenericSet[K]{tree: NewOrderedTree[K](branchingFactor)}
+}
+
+// NewOrderedSetFunc creates a new set ordered by compare, see NewOrderedTreeFunc.
+func NewOrderedSetFunc[K comparable](branchingFactor int, compare func(a, b K) int) *GenericSet[K] {
+	return &GenericSet[K]{tree: NewOrderedTreeFunc(branchingFactor, compare)}
+}
+
+// comparableHash hashes any comparable key with the process-wide seed.
+// maphash hashes NaNs randomly, like map keys, but cmp.Compare finds them equal,
+// so keys that are not equal to themselves all share one hash.
+// Time complexity: O(s) where s is the size of the key.
+func comparableHash[K comparable](key K) uint64 {
+	if key != key {
+		return 0
+	}
+	return maphash.Comparable(orderedHashSeed, key)
+}
+
+// orderedHash hashes an ordered key the same way in every process.
+// Integers hash to their value and strings with FNV-1a; the bloom filters mix
+// the hash further. Floats that cmp.Compare finds equal share one hash: -0 that
+// of 0, and all NaNs that of 0 too.
+// Time complexity: O(s) where s is the size of the key.
+func orderedHash[K cmp.Ordered](key K) uint64 {
+	switch k := any(key).(type) {
+	case string:
+		return fnv1a(k)
+	case int:
+		return uint64(k)
+	case int8:
+		return uint64(k)
+	case int16:
+		return uint64(k)
+	case int32:
+		return uint64(k)
+	case int64:
+		return uint64(k)
+	case uint:
+		return uint64(k)
+	case uint8:
+		return uint64(k)
+	case uint16:
+		return uint64(k)
+	case uint32:
+		return uint64(k)
+	case uint64:
+		return k
+	case uintptr:
+		return uint64(k)
+	case float32:
+		return floatHash(float64(k))
+	case float64:
+		return floatHash(k)
+	}
+
+	// Named types, such as type ID int, by their underlying type
+	v := reflect.ValueOf(key)
+	switch v.Kind() {
+	case reflect.String:
+		return fnv1a(v.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint64(v.Int())
+	case reflect.Float32, reflect.Float64:
+		return floatHash(v.Float())
+	default:
+		return v.Uint()
+	}
+}
+
+// floatHash hashes a float so that -0 and 0, and all NaNs, share a hash.
+func floatHash(f float64) uint64 {
+	if f == 0 || f != f {
+		return 0
+	}
+	return math.Float64bits(f)
+}
+
+// fnv1a hashes a string or byte slice with 64-bit FNV-1a.
+// Time complexity: O(s) where s is the length of s.
+func fnv1a[S string | []byte](s S) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= 1099511628211
+	}
+	return h
+}
@@ -0,0 +1,157 @@
+package bplustree
+
+import (
+	"cmp"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// TestOrderedTree tests trees built with NewOrderedTree for several key types
+func TestOrderedTree(t *testing.T) {
+	ints := NewOrderedTree[int](4)
+	for i := 100; i > -100; i-- {
+		ints.Insert(i)
+	}
+	if !ints.Contains(-42) || ints.Contains(1000) {
+		t.Errorf("Unexpected Contains result for int keys")
+	}
+	if got := ints.RangeQuery(-2, 2); !slices.Equal(got, []int{-2, -1, 0, 1, 2}) {
+		t.Errorf("Expected [-2 -1 0 1 2], got %v", got)
+	}
+
+	strs := NewOrderedTree[string](4)
+	for _, s := range []string{"pear", "apple", "fig", "banana"} {
+		strs.Insert(s)
+	}
+	if !strs.Contains("fig") || strs.Contains("grape") {
+		t.Errorf("Unexpected Contains result for string keys")
+	}
+	if got := strs.RangeQuery("a", "c"); !slices.Equal(got, []string{"apple", "banana"}) {
+		t.Errorf("Expected [apple banana], got %v", got)
+	}
+}
+
+// TestOrderedTreeFloatNaN tests that NaN can be inserted, found and deleted like any other key
+func TestOrderedTreeFloatNaN(t *testing.T) {
+	tree := NewOrderedTree[float64](4)
+	for _, f := range []float64{1.5, math.NaN(), -2, math.Inf(1), 0} {
+		tree.Insert(f)
+	}
+	if tree.Insert(math.NaN()) {
+		t.Errorf("Expected a second NaN to be a duplicate")
+	}
+	if !tree.Contains(math.NaN()) {
+		t.Errorf("Expected the tree to contain NaN")
+	}
+	if !tree.Contains(math.Copysign(0, -1)) {
+		t.Errorf("Expected -0 to be found as 0")
+	}
+	if !tree.Delete(math.NaN()) || tree.Contains(math.NaN()) {
+		t.Errorf("Expected NaN to be deleted")
+	}
+	if tree.Size() != 4 {
+		t.Errorf("Expected size 4, got %d", tree.Size())
+	}
+}
+
+// TestOrderedHash tests that ordered keys hash the same in every process and equal keys share a hash
+func TestOrderedHash(t *testing.T) {
+	// Fixed values, so the hash cannot depend on a per-process seed
+	if got := orderedHash("abc"); got != (StringCodec{}).Hash("abc") {
+		t.Errorf("Expected strings to hash with FNV-1a, got %#x", got)
+	}
+	if got := bytesHash([]byte("abc")); got != orderedHash("abc") {
+		t.Errorf("Expected byte slices to hash like strings, got %#x", got)
+	}
+	if got := orderedHash(42); got != 42 {
+		t.Errorf("Expected 42 to hash to 42, got %d", got)
+	}
+
+	type id int
+	if orderedHash(id(-7)) != orderedHash(-7) {
+		t.Errorf("Expected a named type to hash like its underlying type")
+	}
+	if orderedHash(math.Copysign(0, -1)) != orderedHash(0.0) {
+		t.Errorf("Expected -0 and 0 to share a hash")
+	}
+	if orderedHash(math.NaN()) != orderedHash(-math.NaN()) {
+		t.Errorf("Expected all NaNs to share a hash")
+	}
+}
+
+// TestOrderedTreeFunc tests struct keys ordered by a compare function
+func TestOrderedTreeFunc(t *testing.T) {
+	type point struct{ X, Y int }
+	tree := NewOrderedTreeFunc(4, func(a, b point) int {
+		return cmp.Or(cmp.Compare(a.X, b.X), cmp.Compare(a.Y, b.Y))
+	})
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 10; y++ {
+			tree.Insert(point{x, y})
+		}
+	}
+
+	if !tree.Contains(point{3, 7}) || tree.Contains(point{3, 10}) {
+		t.Errorf("Unexpected Contains result for struct keys")
+	}
+	got := tree.RangeQuery(point{4, 8}, point{5, 1})
+	want := []point{{4, 8}, {4, 9}, {5, 0}, {5, 1}}
+	if !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+// TestOrderedSet tests sets built with NewOrderedSet and NewOrderedSetFunc
+func TestOrderedSet(t *testing.T) {
+	set := NewOrderedSet[float32](4)
+	for _, f := range []float32{3, 1, 2, 1} {
+		set.Add(f)
+	}
+	if set.Size() != 3 || !set.Contains(2) {
+		t.Errorf("Unexpected set contents %v", set.SortedSlice())
+	}
+	set.Clear()
+	set.Add(5)
+	if !set.Contains(5) || set.Size() != 1 {
+		t.Errorf("Expected a usable set after Clear")
+	}
+
+	reversed := NewOrderedSetFunc(4, func(a, b string) int { return cmp.Compare(b, a) })
+	for _, s := range []string{"a", "c", "b"} {
+		reversed.Add(s)
+	}
+	if got := reversed.SortedSlice(); !slices.Equal(got, []string{"c", "b", "a"}) {
+		t.Errorf("Expected [c b a], got %v", got)
+	}
+}
+
+// TestOrderedTreeSearchMatchesCompare tests that the specialized search of
+// NewOrderedTree gives the same tree as searching with the compare function
+func TestOrderedTreeSearchMatchesCompare(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	fast := NewOrderedTree[int](6)
+	slow := NewGenericBPlusTreeWithCompare(6, cmp.Compare[int], func(v int) uint64 { return uint64(v) })
+
+	for i := 0; i < 5000; i++ {
+		key := rng.Intn(1000)
+		if rng.Intn(3) == 0 {
+			if fast.Delete(key) != slow.Delete(key) {
+				t.Fatalf("Delete(%d) differs", key)
+			}
+		} else if fast.Insert(key) != slow.Insert(key) {
+			t.Fatalf("Insert(%d) differs", key)
+		}
+	}
+	checkTreeInvariants(t, fast)
+
+	if !slices.Equal(fast.RangeQuery(100, 400), slow.RangeQuery(100, 400)) {
+		t.Errorf("RangeQuery differs")
+	}
+	for key := -1; key <= 1000; key++ {
+		if fast.Contains(key) != slow.Contains(key) {
+			t.Fatalf("Contains(%d) differs", key)
+		}
+	}
+}
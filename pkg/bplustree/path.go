@@ -0,0 +1,85 @@
+package bplustree
+
+// pathFrame is one step of a descent: a branch and the index of the child taken.
+type pathFrame[K any] struct {
+	node  *node[K]
+	index int
+}
+
+// treePath records the way from the root to a leaf, so that inserts and deletes
+// can split or rebalance nodes bottom-up without descending the tree again.
+// A tree keeps one treePath and reuses its stack for every descent.
+type treePath[K any] struct {
+	frames []pathFrame[K] // Branches from the root down, with the child taken in each
+	leaf   *node[K]       // Leaf the descent reached
+}
+
+// reset empties the path, keeping the stack for the next descent.
+// Time complexity: O(h) where h is the height of the tree.
+func (p *treePath[K]) reset() {
+	clear(p.frames)
+	p.frames = p.frames[:0]
+	p.leaf = nil
+}
+
+// push records that the descent took the child at index of node.
+// Time complexity: O(1) amortized.
+func (p *treePath[K]) push(node *node[K], index int) {
+	p.frames = append(p.frames, pathFrame[K]{node: node, index: index})
+}
+
+// pop removes and returns the lowest branch on the path.
+// Returns false if the path holds no branches, which is the case at the root.
+// Time complexity: O(1)
+func (p *treePath[K]) pop() (pathFrame[K], bool) {
+	if len(p.frames) == 0 {
+		return pathFrame[K]{}, false
+	}
+	frame := p.frames[len(p.frames)-1]
+	p.frames[len(p.frames)-1] = pathFrame[K]{}
+	p.frames = p.frames[:len(p.frames)-1]
+	return frame, true
+}
+
+// descend walks from the root to the leaf that should contain key and records
+// the way in the tree's path. Returns nil if the tree is malformed.
+// Time complexity: O(log n) where n is the number of keys in the tree.
+func (t *GenericBPlusTree[K]) descend(key K) *treePath[K] {
+	path := &t.path
+	path.reset()
+
+	n := t.root
+	for n.kind == Branch {
+		// Find the child that should contain the key
+		childIndex := n.FindChildIndex(key, t.search)
+
+		// Safety check to avoid index out of range
+		if childIndex >= len(n.children) {
+			return nil
+		}
+
+		path.push(n, childIndex)
+		n = n.children[childIndex]
+	}
+
+	path.leaf = n
+	return path
+}
+
+// descendRightmost walks from the root to the rightmost leaf, always taking
+// the last child, and records the way in the tree's path.
+// Time complexity: O(log n) where n is the number of keys in the tree, without comparisons.
+func (t *GenericBPlusTree[K]) descendRightmost() *treePath[K] {
+	path := &t.path
+	path.reset()
+
+	n := t.root
+	for n.kind == Branch {
+		childIndex := len(n.children) - 1
+		path.push(n, childIndex)
+		n = n.children[childIndex]
+	}
+
+	path.leaf = n
+	return path
+}
@@ -0,0 +1,391 @@
+package bplustree
+
+import "strings"
+
+// RangeFilter answers whether a range of keys might hold any keys, so range scans
+// over empty ranges can be skipped without touching the leaves.
+// Like a Bloom filter it can report false positives but never false negatives.
+type RangeFilter[K any] interface {
+	// Add adds a key to the filter.
+	Add(key K)
+
+	// MayContainRange returns true if a key in [start, end] might be in the set.
+	// Returns false if the range is definitely empty.
+	MayContainRange(start, end K) bool
+
+	// Clear resets the filter, removing all keys.
+	Clear()
+
+	// SetValid marks the filter as valid.
+	// A valid filter has been properly initialized with all keys.
+	SetValid()
+
+	// IsValid returns true if the filter is valid.
+	IsValid() bool
+}
+
+// growableRangeFilter is a range filter that knows the number of keys it was
+// sized for and can create an empty filter of its kind for more keys.
+// The tree grows such filters along with its keys.
+type growableRangeFilter[K any] interface {
+	RangeFilter[K]
+
+	// Capacity returns the number of keys the filter was sized for.
+	Capacity() int
+
+	// resized returns an empty, invalid filter of the same kind and
+	// false-positive rate, sized for expectedKeys.
+	resized(expectedKeys int) RangeFilter[K]
+}
+
+// Range filter parameters.
+const (
+	rangeFilterLevelBits    = 4                          // Bits of a uint64 key per prefix level
+	rangeFilterLevels       = 64 / rangeFilterLevelBits  // Prefix levels stored per uint64 key
+	rangeFilterFanout       = 1 << rangeFilterLevelBits  // Children of each uint64 prefix
+	rangeFilterMaxPrefixLen = 8                          // Longest string prefix stored
+	rangeFilterProbeBudget  = 256                        // Probes per query before answering "maybe"
+	rangeFilterLevelSeed    = uint64(0x9e3779b97f4a7c15) // Separates the prefix levels in the bitset
+)
+
+// Uint64RangeFilter is a range filter for uint64 keys built from a prefix Bloom filter.
+// Each key is added at 16 levels, as its top 4, 8, ..., 64 bits. A query walks
+// down from the shortest prefixes, and only descends into prefixes the Bloom
+// filter reports present, until it finds a prefix inside the range or runs out of
+// prefixes. Ranges that are empty are therefore usually rejected after a few probes.
+type Uint64RangeFilter struct {
+	bits              *BlockedBloomFilter // Prefixes of all levels
+	capacity          int                 // Number of keys the filter was sized for
+	falsePositiveRate float64             // False-positive rate of each prefix probe
+}
+
+// NewUint64RangeFilter creates a new range filter for uint64 keys.
+// Parameters:
+//   - expectedKeys: The number of keys the filter is sized for.
+//   - falsePositiveRate: The false-positive rate of each prefix probe.
+//
+// The filter uses about rangeFilterLevels times the memory of a point Bloom filter.
+func NewUint64RangeFilter(expectedKeys int, falsePositiveRate float64) *Uint64RangeFilter {
+	size, hashFunctions := OptimalBloomFilterSize(expectedKeys*rangeFilterLevels, falsePositiveRate)
+	return &Uint64RangeFilter{
+		bits:              NewBlockedBloomFilter(size, hashFunctions),
+		capacity:          expectedKeys,
+		falsePositiveRate: falsePositiveRate,
+	}
+}
+
+// Capacity returns the number of keys the filter was sized for.
+// Time complexity: O(1)
+func (f *Uint64RangeFilter) Capacity() int {
+	return f.capacity
+}
+
+// resized returns an empty filter like f, sized for expectedKeys.
+// Time complexity: O(m) where m is the size of the new filter.
+func (f *Uint64RangeFilter) resized(expectedKeys int) RangeFilter[uint64] {
+	return NewUint64RangeFilter(expectedKeys, f.falsePositiveRate)
+}
+
+// Add adds a key to the filter by adding all of its prefixes.
+// Time complexity: O(L*k) where L is the number of levels and k is the number of hash functions.
+func (f *Uint64RangeFilter) Add(key uint64) {
+	for level := 0; level < rangeFilterLevels; level++ {
+		f.bits.Add(uint64PrefixEntry(key>>(level*rangeFilterLevelBits), level))
+	}
+}
+
+// MayContainRange returns true if a key in [start, end] might be in the set.
+// Time complexity: O(P*k) where P is the probe budget and k is the number of hash functions.
+func (f *Uint64RangeFilter) MayContainRange(start, end uint64) bool {
+	if end < start {
+		return false
+	}
+	budget := rangeFilterProbeBudget
+	return f.search(0, rangeFilterLevels, start, end, &budget)
+}
+
+// search returns true if a child of prefix at the given level might hold a key in [start, end].
+// The prefix itself is known to be present; level rangeFilterLevels is the empty prefix.
+func (f *Uint64RangeFilter) search(prefix uint64, level int, start, end uint64, budget *int) bool {
+	childLevel := level - 1
+	shift := uint(childLevel * rangeFilterLevelBits)
+
+	for digit := uint64(0); digit < rangeFilterFanout; digit++ {
+		child := prefix<<rangeFilterLevelBits | digit
+		low := child << shift
+		high := low | (1<<shift - 1)
+		if high < start || low > end {
+			continue
+		}
+
+		// Give up on refining once the budget is spent
+		if *budget == 0 {
+			return true
+		}
+		*budget--
+
+		if !f.bits.Contains(uint64PrefixEntry(child, childLevel)) {
+			continue
+		}
+		if childLevel == 0 || (start <= low && high <= end) {
+			return true
+		}
+		if f.search(child, childLevel, start, end, budget) {
+			return true
+		}
+	}
+	return false
+}
+
+// Clear resets the filter and marks it as invalid.
+// Time complexity: O(m) where m is the size of the filter.
+func (f *Uint64RangeFilter) Clear() {
+	f.bits.Clear()
+}
+
+// SetValid marks the filter as valid.
+// Time complexity: O(1)
+func (f *Uint64RangeFilter) SetValid() {
+	f.bits.SetValid()
+}
+
+// IsValid returns true if the filter is valid.
+// Time complexity: O(1)
+func (f *Uint64RangeFilter) IsValid() bool {
+	return f.bits.IsValid()
+}
+
+// uint64PrefixEntry returns the bitset entry for a key prefix at a level.
+// Time complexity: O(1)
+func uint64PrefixEntry(prefix uint64, level int) uint64 {
+	return prefix ^ uint64(level)*rangeFilterLevelSeed
+}
+
+// StringRangeFilter is a range filter for string keys built from a prefix Bloom filter.
+// Each key is added as its prefixes of 1 to 8 bytes, and keys of at most 8 bytes
+// are also added as whole keys. A query walks down the byte prefixes the way
+// Uint64RangeFilter walks bit prefixes. Ranges whose bounds share a prefix longer
+// than 8 bytes cannot be refined and are reported as possibly non-empty if the
+// 8-byte prefix is present.
+type StringRangeFilter struct {
+	bits              *BlockedBloomFilter // Prefixes and short whole keys
+	capacity          int                 // Number of keys the filter was sized for
+	falsePositiveRate float64             // False-positive rate of each prefix probe
+}
+
+// NewStringRangeFilter creates a new range filter for string keys.
+// Parameters:
+//   - expectedKeys: The number of keys the filter is sized for.
+//   - falsePositiveRate: The false-positive rate of each prefix probe.
+func NewStringRangeFilter(expectedKeys int, falsePositiveRate float64) *StringRangeFilter {
+	size, hashFunctions := OptimalBloomFilterSize(expectedKeys*(rangeFilterMaxPrefixLen+1), falsePositiveRate)
+	return &StringRangeFilter{
+		bits:              NewBlockedBloomFilter(size, hashFunctions),
+		capacity:          expectedKeys,
+		falsePositiveRate: falsePositiveRate,
+	}
+}
+
+// Capacity returns the number of keys the filter was sized for.
+// Time complexity: O(1)
+func (f *StringRangeFilter) Capacity() int {
+	return f.capacity
+}
+
+// resized returns an empty filter like f, sized for expectedKeys.
+// Time complexity: O(m) where m is the size of the new filter.
+func (f *StringRangeFilter) resized(expectedKeys int) RangeFilter[string] {
+	return NewStringRangeFilter(expectedKeys, f.falsePositiveRate)
+}
+
+// Add adds a key to the filter by adding its prefixes.
+// Time complexity: O(L*k) where L is the longest stored prefix and k is the number of hash functions.
+func (f *StringRangeFilter) Add(key string) {
+	for n := 1; n <= len(key) && n <= rangeFilterMaxPrefixLen; n++ {
+		f.bits.Add(stringPrefixEntry(key[:n], false))
+	}
+	if len(key) <= rangeFilterMaxPrefixLen {
+		f.bits.Add(stringPrefixEntry(key, true))
+	}
+}
+
+// MayContainRange returns true if a key in [start, end] might be in the set.
+// Time complexity: O(P*k) where P is the probe budget and k is the number of hash functions.
+func (f *StringRangeFilter) MayContainRange(start, end string) bool {
+	if end < start {
+		return false
+	}
+	budget := rangeFilterProbeBudget
+	return f.search("", start, end, &budget)
+}
+
+// search returns true if a key starting with prefix might be in [start, end].
+// The prefix itself is known to be present, and some string starting with it is in the range.
+func (f *StringRangeFilter) search(prefix, start, end string, budget *int) bool {
+	// The prefix may itself be a key
+	if start <= prefix && prefix <= end {
+		if *budget == 0 {
+			return true
+		}
+		*budget--
+		if f.bits.Contains(stringPrefixEntry(prefix, true)) {
+			return true
+		}
+	}
+
+	// Find the next bytes that keep the prefix inside the range
+	n := len(prefix)
+	lowByte, highByte := 0, 255
+	if strings.HasPrefix(start, prefix) && len(start) > n {
+		lowByte = int(start[n])
+	}
+	if strings.HasPrefix(end, prefix) {
+		if len(end) == n {
+			return false // Only the prefix itself is in range
+		}
+		highByte = int(end[n])
+	}
+
+	// Longer keys are not stored by prefix, so they cannot be ruled out
+	if n == rangeFilterMaxPrefixLen {
+		return true
+	}
+
+	for b := lowByte; b <= highByte; b++ {
+		if *budget == 0 {
+			return true
+		}
+		*budget--
+
+		child := prefix + string([]byte{byte(b)})
+		if !f.bits.Contains(stringPrefixEntry(child, false)) {
+			continue
+		}
+
+		// Every string starting with child is in range
+		if start <= child && len(end) > n && end[:n+1] > child {
+			return true
+		}
+		if f.search(child, start, end, budget) {
+			return true
+		}
+	}
+	return false
+}
+
+// Clear resets the filter and marks it as invalid.
+// Time complexity: O(m) where m is the size of the filter.
+func (f *StringRangeFilter) Clear() {
+	f.bits.Clear()
+}
+
+// SetValid marks the filter as valid.
+// Time complexity: O(1)
+func (f *StringRangeFilter) SetValid() {
+	f.bits.SetValid()
+}
+
+// IsValid returns true if the filter is valid.
+// Time complexity: O(1)
+func (f *StringRangeFilter) IsValid() bool {
+	return f.bits.IsValid()
+}
+
+// stringPrefixEntry returns the bitset entry for a string prefix using FNV-1a.
+// Whole keys are kept apart from prefixes of the same bytes.
+// Time complexity: O(n) where n is the length of the prefix.
+func stringPrefixEntry(prefix string, whole bool) uint64 {
+	hash := uint64(14695981039346656037)
+	for i := 0; i < len(prefix); i++ {
+		hash ^= uint64(prefix[i])
+		hash *= 1099511628211
+	}
+	if whole {
+		hash ^= rangeFilterLevelSeed
+	}
+	return hash
+}
+
+// SetRangeFilter makes RangeQuery and CountRange consult filter before scanning,
+// and returns empty results without touching the leaves when it reports a range empty.
+// The filter is filled with the tree's keys now and kept up to date by Insert.
+// Range filters cannot remove keys, so deleted keys stay in the filter, which
+// still never misses a non-empty range. Once more keys have been deleted than
+// the tree holds, the filter is recomputed by the next range query. Filters
+// from NewUint64RangeFilter and NewStringRangeFilter are also replaced by larger
+// ones as the tree grows past the number of keys they were sized for.
+// Passing nil removes the range filter.
+// Time complexity: O(n) where n is the number of keys in the tree.
+func (t *GenericBPlusTree[K]) SetRangeFilter(filter RangeFilter[K]) {
+	t.rangeFilter = filter
+	if filter != nil {
+		t.recomputeRangeFilter()
+	}
+}
+
+// updateRangeFilter adds a key to the range filter if there is a valid one.
+// Time complexity: O(1) for the filters in this package.
+func (t *GenericBPlusTree[K]) updateRangeFilter(key K) {
+	if t.rangeFilter != nil && t.rangeFilter.IsValid() {
+		t.rangeFilter.Add(key)
+	}
+}
+
+// invalidateRangeFilter clears the range filter, if any, so the next range
+// query recomputes it.
+// Time complexity: O(m) where m is the size of the filter.
+func (t *GenericBPlusTree[K]) invalidateRangeFilter() {
+	if t.rangeFilter != nil && t.rangeFilter.IsValid() {
+		t.rangeFilter.Clear()
+	}
+}
+
+// recordRangeFilterDelete notes that a key was deleted. The key stays in the
+// range filter, which remains a superset of the tree's keys, until more keys
+// have been deleted than the tree holds; then the filter is invalidated, so
+// recomputing it costs O(1) amortized per delete.
+// Time complexity: O(1) amortized
+func (t *GenericBPlusTree[K]) recordRangeFilterDelete() {
+	if t.rangeFilter == nil || !t.rangeFilter.IsValid() {
+		return
+	}
+	t.rangeFilterDeletes++
+	if t.rangeFilterDeletes > t.size {
+		t.invalidateRangeFilter()
+	}
+}
+
+// growRangeFilter replaces a growable range filter with one sized for twice the
+// current number of keys once the tree holds more keys than the filter was sized
+// for. Like the bloom filter, the new filter is filled by the next range query.
+// Time complexity: O(1) amortized
+func (t *GenericBPlusTree[K]) growRangeFilter() {
+	filter, ok := t.rangeFilter.(growableRangeFilter[K])
+	if ok && t.size > filter.Capacity() {
+		t.rangeFilter = filter.resized(2 * t.size)
+	}
+}
+
+// recomputeRangeFilter recomputes the range filter from all keys in the tree.
+// Time complexity: O(n) where n is the number of keys in the tree.
+func (t *GenericBPlusTree[K]) recomputeRangeFilter() {
+	t.Flush()
+	t.rangeFilter.Clear()
+	t.traverseTree(t.root, t.rangeFilter.Add)
+	t.rangeFilter.SetValid()
+	t.rangeFilterDeletes = 0
+}
+
+// mayContainRange returns false if the range filter says [start, end] holds no keys.
+// Without a range filter every range might hold keys.
+// Time complexity: O(1) for the filters in this package, plus O(n) if the
+// filter has to be recomputed first.
+func (t *GenericBPlusTree[K]) mayContainRange(start, end K) bool {
+	if t.rangeFilter == nil {
+		return true
+	}
+	if !t.rangeFilter.IsValid() {
+		t.recomputeRangeFilter()
+	}
+	return t.rangeFilter.MayContainRange(start, end)
+}
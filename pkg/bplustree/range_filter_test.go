@@ -0,0 +1,206 @@
+package bplustree
+
+import (
+	"math/rand"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// TestUint64RangeFilter tests that the filter never misses a non-empty range and rejects most empty ones
+func TestUint64RangeFilter(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	filter := NewUint64RangeFilter(10000, 0.01)
+
+	// Keys about 1700 apart, so ranges of up to 2000 are empty about half the time
+	keys := make([]uint64, 10000)
+	for i := range keys {
+		keys[i] = rng.Uint64() >> 40
+		filter.Add(keys[i])
+	}
+	slices.Sort(keys)
+
+	empty, rejected := 0, 0
+	for i := 0; i < 20000; i++ {
+		start := rng.Uint64() >> 40
+		end := start + uint64(rng.Intn(2000))
+
+		j := sort.Search(len(keys), func(j int) bool { return keys[j] >= start })
+		nonEmpty := j < len(keys) && keys[j] <= end
+		got := filter.MayContainRange(start, end)
+		if nonEmpty && !got {
+			t.Fatalf("False negative for [%d, %d]", start, end)
+		}
+		if !nonEmpty {
+			empty++
+			if !got {
+				rejected++
+			}
+		}
+	}
+
+	t.Logf("Rejected %d of %d empty ranges", rejected, empty)
+	if rejected < empty*9/10 {
+		t.Errorf("Expected most empty ranges to be rejected, got %d of %d", rejected, empty)
+	}
+
+	if filter.MayContainRange(10, 5) {
+		t.Errorf("Expected an inverted range to be empty")
+	}
+	if !filter.MayContainRange(0, ^uint64(0)) || !filter.MayContainRange(keys[0], keys[0]) {
+		t.Errorf("Expected ranges holding keys to be reported")
+	}
+}
+
+// TestStringRangeFilter tests the string filter, including keys that are prefixes of other keys
+func TestStringRangeFilter(t *testing.T) {
+	keys := []string{"", "a", "apple", "applesauce", "apricot", "banana", "band", "zebra-crossing-long-key"}
+	filter := NewStringRangeFilter(len(keys), 0.01)
+	for _, key := range keys {
+		filter.Add(key)
+	}
+
+	cases := []struct {
+		start, end string
+		nonEmpty   bool
+	}{
+		{"", "", true},
+		{"a", "a", true},
+		{"apple", "apple", true},
+		{"applf", "apq", true},  // apricot
+		{"appm", "apqz", false}, // Between apple* and apricot
+		{"b", "banana", true},   // banana is the end
+		{"banb", "banc", false}, // Between banana and band
+		{"c", "y", false},       // Nothing from c to y
+		{"zebra-crossing-long", "zebra-crossing-long-key", true},
+		{"\x00", "\x00\xff", false},
+	}
+	for _, c := range cases {
+		got := filter.MayContainRange(c.start, c.end)
+		if c.nonEmpty && !got {
+			t.Errorf("False negative for [%q, %q]", c.start, c.end)
+		}
+		if !c.nonEmpty && got {
+			t.Logf("False positive for [%q, %q]", c.start, c.end)
+		}
+	}
+
+	// With random keys, check against the sorted keys
+	rng := rand.New(rand.NewSource(2))
+	random := NewStringRangeFilter(5000, 0.01)
+	words := make([]string, 5000)
+	for i := range words {
+		words[i] = randomStringFrom(rng, 1+rng.Intn(12))
+		random.Add(words[i])
+	}
+	slices.Sort(words)
+
+	empty, rejected := 0, 0
+	for i := 0; i < 5000; i++ {
+		start := randomStringFrom(rng, 1+rng.Intn(6))
+		end := start + randomStringFrom(rng, 2)
+		j := sort.SearchStrings(words, start)
+		nonEmpty := j < len(words) && words[j] <= end
+		got := random.MayContainRange(start, end)
+		if nonEmpty && !got {
+			t.Fatalf("False negative for [%q, %q]", start, end)
+		}
+		if !nonEmpty {
+			empty++
+			if !got {
+				rejected++
+			}
+		}
+	}
+	t.Logf("Rejected %d of %d empty ranges", rejected, empty)
+	if rejected < empty*8/10 {
+		t.Errorf("Expected most empty ranges to be rejected, got %d of %d", rejected, empty)
+	}
+}
+
+// randomStringFrom returns a random lowercase string of the given length
+func randomStringFrom(rng *rand.Rand, length int) string {
+	b := make([]byte, length)
+	for i := range b {
+		b[i] = byte('a' + rng.Intn(26))
+	}
+	return string(b)
+}
+
+// TestTreeWithRangeFilter tests RangeQuery and CountRange with a range filter through inserts and deletes
+func TestTreeWithRangeFilter(t *testing.T) {
+	tree := NewBPlusTree(16)
+	for key := uint64(0); key < 1000; key++ {
+		tree.Insert(key * 1000)
+	}
+	tree.SetRangeFilter(NewUint64RangeFilter(2000, 0.01))
+
+	if got := tree.CountRange(1, 999); got != 0 {
+		t.Errorf("Expected an empty range, got %d keys", got)
+	}
+	if got := tree.RangeQuery(500, 2500); !slices.Equal(got, []uint64{1000, 2000}) {
+		t.Errorf("Unexpected range %v", got)
+	}
+
+	// Keys inserted after the filter is set are found
+	tree.Insert(1500)
+	if got := tree.CountRange(1001, 1999); got != 1 {
+		t.Errorf("Expected 1 key after insert, got %d", got)
+	}
+
+	// Deleted keys stop being found once the filter is recomputed
+	tree.Delete(1500)
+	tree.Delete(2000)
+	if got := tree.RangeQuery(1001, 2999); len(got) != 0 {
+		t.Errorf("Expected an empty range after deletes, got %v", got)
+	}
+	if got := tree.CountRange(0, 999999); got != 999 {
+		t.Errorf("Expected 999 keys, got %d", got)
+	}
+
+	tree.SetRangeFilter(nil)
+	if got := tree.CountRange(2000, 3000); got != 1 {
+		t.Errorf("Expected 1 key without a filter, got %d", got)
+	}
+}
+
+// TestTreeRangeFilterDeletesAndGrowth tests that deletes keep the range filter
+// until more keys are deleted than remain, and that it grows with the tree
+func TestTreeRangeFilterDeletesAndGrowth(t *testing.T) {
+	tree := NewBPlusTree(16)
+	tree.SetRangeFilter(NewUint64RangeFilter(100, 0.01))
+	for key := uint64(0); key < 1000; key++ {
+		tree.Insert(key * 10)
+	}
+
+	// The filter was replaced by a larger one, filled by the next query
+	if got := tree.CountRange(0, 10000); got != 1000 {
+		t.Errorf("Expected 1000 keys, got %d", got)
+	}
+	filter := tree.rangeFilter.(*Uint64RangeFilter)
+	if !filter.IsValid() || filter.Capacity() < tree.Size() {
+		t.Fatalf("Expected a valid filter sized for %d keys, got capacity %d", tree.Size(), filter.Capacity())
+	}
+
+	// Deleted keys stay in the filter, which still answers correctly
+	for key := uint64(0); key < 400; key++ {
+		tree.Delete(key * 10)
+	}
+	if !tree.rangeFilter.IsValid() {
+		t.Errorf("Expected the filter to survive 400 deletes")
+	}
+	if got := tree.CountRange(0, 3990); got != 0 {
+		t.Errorf("Expected the deleted range to be empty, got %d keys", got)
+	}
+
+	// Deleting more keys than remain invalidates it
+	for key := uint64(400); key < 750; key++ {
+		tree.Delete(key * 10)
+	}
+	if tree.rangeFilter.IsValid() {
+		t.Errorf("Expected the filter to be invalidated after 750 of 1000 keys were deleted")
+	}
+	if got := tree.CountRange(0, 10000); got != 250 || !tree.rangeFilter.IsValid() {
+		t.Errorf("Expected 250 keys from a recomputed filter, got %d", got)
+	}
+}
@@ -0,0 +1,95 @@
+package bplustree
+
+// SplitPolicy decides how a node that overflows during an insert is divided.
+type SplitPolicy int
+
+const (
+	// SplitMidpoint divides nodes in half. It suits keys inserted in random order.
+	SplitMidpoint SplitPolicy = iota
+	// SplitRightHeavy keeps 90% of the keys in the left node and moves 10% to the
+	// new node on its right. Ascending keys, such as timestamps or auto-increment
+	// IDs, then leave leaves 90% full instead of half full.
+	SplitRightHeavy
+	// SplitAdaptive splits like SplitRightHeavy while keys are being inserted in
+	// ascending order, mirrors it (10% left, 90% right) while they are being
+	// inserted in descending order, and divides nodes in half otherwise.
+	SplitAdaptive
+)
+
+const (
+	// sequentialInsertRun is the number of inserts in a row in one direction
+	// after which SplitAdaptive treats the insert stream as sequential.
+	sequentialInsertRun = 16
+
+	// heavySplitTenths is the share of keys, in tenths, that stays on the
+	// heavy side of a right- or left-heavy split.
+	heavySplitTenths = 9
+)
+
+// SetSplitPolicy sets how nodes are divided when inserts overflow them.
+// The default is SplitMidpoint.
+//
+// Right- and left-heavy splits leave the lighter node below the minimum number of
+// keys, on the side the insert stream is moving towards, where later inserts fill it.
+// Deletes rebalance such nodes as usual.
+// Time complexity: O(1)
+func (t *GenericBPlusTree[K]) SetSplitPolicy(policy SplitPolicy) {
+	t.splitPolicy = policy
+	t.insertRun = 0
+}
+
+// SplitPolicy returns how nodes are divided when inserts overflow them.
+// Time complexity: O(1)
+func (t *GenericBPlusTree[K]) SplitPolicy() SplitPolicy {
+	return t.splitPolicy
+}
+
+// recordInsert notes the direction of the insert stream for SplitAdaptive.
+// insertRun counts the latest inserts in a row that were larger (positive)
+// or smaller (negative) than the one before.
+// Time complexity: O(1), plus one comparison.
+func (t *GenericBPlusTree[K]) recordInsert(key K) {
+	if t.splitPolicy != SplitAdaptive {
+		return
+	}
+
+	if t.size > 0 {
+		switch c := t.compare(key, t.lastInsert); {
+		case c > 0:
+			t.insertRun = min(max(t.insertRun, 0)+1, sequentialInsertRun)
+		case c < 0:
+			t.insertRun = max(min(t.insertRun, 0)-1, -sequentialInsertRun)
+		}
+	}
+	t.lastInsert = key
+}
+
+// splitIndex returns where splitChild divides a node holding count keys:
+// a leaf keeps the keys before the index, and a branch keeps the keys before
+// it and moves the key at it up to the parent. Both halves keep at least one key.
+// Time complexity: O(1)
+func (t *GenericBPlusTree[K]) splitIndex(count int, isLeaf bool) int {
+	index := count / 2
+	switch {
+	case t.splitPolicy == SplitRightHeavy,
+		t.splitPolicy == SplitAdaptive && t.insertRun >= sequentialInsertRun:
+		index = count * heavySplitTenths / 10
+	case t.splitPolicy == SplitAdaptive && t.insertRun <= -sequentialInsertRun:
+		index = count - count*heavySplitTenths/10
+	}
+
+	// A branch also gives up the key at index, so it can keep one key less
+	last := count - 1
+	if !isLeaf {
+		last--
+	}
+	return min(max(index, 1), last)
+}
+
+// appendsToRightmost returns true if key is larger than every key in the tree,
+// so that it belongs at the end of the rightmost leaf.
+// Time complexity: O(1), plus one comparison.
+func (t *GenericBPlusTree[K]) appendsToRightmost(key K) bool {
+	keys := t.rightmost.keys
+	return len(keys) > 0 && t.compare(key, keys[len(keys)-1]) > 0
+}
@@ -0,0 +1,106 @@
+package bplustree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// leafFill returns the average fraction of a full leaf that the tree's leaves hold
+func leafFill[K any](tree *GenericBPlusTree[K]) float64 {
+	leaves := 0
+	for leaf := tree.firstLeaf(); leaf != nil; leaf = leaf.next {
+		leaves++
+	}
+	return float64(tree.Size()) / float64(leaves*tree.branchingFactor)
+}
+
+// TestSplitPolicyAscendingInserts tests that right-heavy and adaptive splits
+// fill leaves when keys are inserted in ascending order
+func TestSplitPolicyAscendingInserts(t *testing.T) {
+	for _, tc := range []struct {
+		policy  SplitPolicy
+		minFill float64
+		maxFill float64
+	}{
+		{SplitMidpoint, 0.45, 0.55},
+		{SplitRightHeavy, 0.85, 1},
+		{SplitAdaptive, 0.85, 1},
+	} {
+		tree := NewBPlusTree(64)
+		tree.SetSplitPolicy(tc.policy)
+		for i := 0; i < 10000; i++ {
+			tree.Insert(uint64(i))
+		}
+		checkTreeInvariants(t, tree)
+
+		if fill := leafFill(tree); fill < tc.minFill || fill > tc.maxFill {
+			t.Errorf("Expected policy %d to fill leaves between %.2f and %.2f, got %.2f", tc.policy, tc.minFill, tc.maxFill, fill)
+		}
+	}
+}
+
+// TestSplitAdaptiveDescendingInserts tests that adaptive splits also fill
+// leaves when keys are inserted in descending order
+func TestSplitAdaptiveDescendingInserts(t *testing.T) {
+	tree := NewBPlusTree(64)
+	tree.SetSplitPolicy(SplitAdaptive)
+	for i := 10000; i > 0; i-- {
+		tree.Insert(uint64(i))
+	}
+	checkTreeInvariants(t, tree)
+
+	if fill := leafFill(tree); fill < 0.85 {
+		t.Errorf("Expected descending inserts to fill leaves to at least 0.85, got %.2f", fill)
+	}
+}
+
+// TestSplitAdaptiveRandomInserts tests that adaptive splits divide nodes in
+// half for keys inserted in random order, and that deletes still rebalance
+func TestSplitAdaptiveRandomInserts(t *testing.T) {
+	tree := NewBPlusTree(8)
+	tree.SetSplitPolicy(SplitAdaptive)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		tree.Insert(uint64(r.Intn(100000)))
+	}
+	if tree.insertRun >= sequentialInsertRun || tree.insertRun <= -sequentialInsertRun {
+		t.Errorf("Expected random inserts not to look sequential, got a run of %d", tree.insertRun)
+	}
+
+	for _, key := range tree.GetAllKeys() {
+		if r.Intn(2) == 0 {
+			tree.Delete(key)
+		}
+	}
+	checkTreeInvariants(t, tree)
+}
+
+// TestRightmostLeafHint tests that the rightmost leaf hint follows splits,
+// merges and Clear, so appends land in the right leaf
+func TestRightmostLeafHint(t *testing.T) {
+	tree := NewBPlusTree(4)
+	for i := 0; i < 200; i++ {
+		tree.Insert(uint64(i))
+	}
+	checkTreeInvariants(t, tree)
+
+	// Delete from the end so the rightmost leaf is merged away
+	for i := 199; i >= 100; i-- {
+		tree.Delete(uint64(i))
+	}
+	checkTreeInvariants(t, tree)
+
+	// Appends after the merges still go to the last leaf
+	for i := 300; i < 400; i++ {
+		tree.Insert(uint64(i))
+	}
+	checkTreeInvariants(t, tree)
+	if !tree.Contains(350) || tree.Contains(150) {
+		t.Errorf("Expected appended keys to be found and deleted keys not")
+	}
+
+	tree.Clear()
+	tree.Insert(1)
+	tree.Insert(2)
+	checkTreeInvariants(t, tree)
+}